@@ -0,0 +1,49 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/triggermesh/brokers/pkg/backend/impl/kafka"
+	"github.com/triggermesh/brokers/pkg/broker"
+	pkgcmd "github.com/triggermesh/brokers/pkg/broker/cmd"
+)
+
+type DeadLetterCmd struct {
+	Kafka kafka.KafkaArgs `embed:"" prefix:"kafka." envprefix:"KAFKA_"`
+
+	pkgcmd.DeadLetterArgs `embed:""`
+}
+
+func (c *DeadLetterCmd) Validate() error {
+	if err := c.Kafka.Validate(); err != nil {
+		return err
+	}
+	return c.DeadLetterArgs.Validate()
+}
+
+func (c *DeadLetterCmd) Run(globals *pkgcmd.Globals) error {
+	c.Kafka.Instance = globals.BrokerName
+	backend := kafka.New(&c.Kafka, globals.Logger.Named("kafka"))
+
+	return broker.RunDeadLetter(globals.Context, &c.DeadLetterArgs, backend, os.Stdout)
+}
+
+type ReplayLostCmd struct {
+	Kafka kafka.KafkaArgs `embed:"" prefix:"kafka." envprefix:"KAFKA_"`
+
+	pkgcmd.ReplayLostArgs `embed:""`
+}
+
+func (c *ReplayLostCmd) Validate() error {
+	return c.Kafka.Validate()
+}
+
+func (c *ReplayLostCmd) Run(globals *pkgcmd.Globals) error {
+	c.Kafka.Instance = globals.BrokerName
+	backend := kafka.New(&c.Kafka, globals.Logger.Named("kafka"))
+
+	return broker.RunReplayLost(globals.Context, &c.ReplayLostArgs, globals.LostEventsSpoolDir, backend, os.Stdout)
+}
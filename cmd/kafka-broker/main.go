@@ -18,7 +18,9 @@ import (
 type cli struct {
 	pkgcmd.Globals
 
-	Start cmd.StartCmd `cmd:"" help:"Starts the TriggerMesh broker."`
+	Start      cmd.StartCmd      `cmd:"" help:"Starts the TriggerMesh broker."`
+	DeadLetter cmd.DeadLetterCmd `cmd:"" name:"deadletter" help:"Lists, inspects and re-drives events at the backend dead letter storage."`
+	ReplayLost cmd.ReplayLostCmd `cmd:"" name:"replay-lost" help:"Produces the events at the lost events spool back to the broker."`
 }
 
 func main() {
@@ -0,0 +1,49 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/triggermesh/brokers/pkg/backend/impl/redis"
+	"github.com/triggermesh/brokers/pkg/broker"
+	pkgcmd "github.com/triggermesh/brokers/pkg/broker/cmd"
+)
+
+type DeadLetterCmd struct {
+	Redis redis.RedisArgs `embed:"" prefix:"redis." envprefix:"REDIS_"`
+
+	pkgcmd.DeadLetterArgs `embed:""`
+}
+
+func (c *DeadLetterCmd) Validate() error {
+	if err := c.Redis.Validate(); err != nil {
+		return err
+	}
+	return c.DeadLetterArgs.Validate()
+}
+
+func (c *DeadLetterCmd) Run(globals *pkgcmd.Globals) error {
+	c.Redis.Instance = globals.BrokerName
+	backend := redis.New(&c.Redis, globals.Logger.Named("redis"))
+
+	return broker.RunDeadLetter(globals.Context, &c.DeadLetterArgs, backend, os.Stdout)
+}
+
+type ReplayLostCmd struct {
+	Redis redis.RedisArgs `embed:"" prefix:"redis." envprefix:"REDIS_"`
+
+	pkgcmd.ReplayLostArgs `embed:""`
+}
+
+func (c *ReplayLostCmd) Validate() error {
+	return c.Redis.Validate()
+}
+
+func (c *ReplayLostCmd) Run(globals *pkgcmd.Globals) error {
+	c.Redis.Instance = globals.BrokerName
+	backend := redis.New(&c.Redis, globals.Logger.Named("redis"))
+
+	return broker.RunReplayLost(globals.Context, &c.ReplayLostArgs, globals.LostEventsSpoolDir, backend, os.Stdout)
+}
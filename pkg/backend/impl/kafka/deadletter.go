@@ -0,0 +1,266 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+)
+
+const (
+	// Maximum duration for reading dead letter records.
+	deadLetterReadTimeout = 10 * time.Second
+)
+
+// clientOpts returns the connection options along with the
+// extra options informed.
+func (s *kafka) clientOpts(opts ...kgo.Opt) []kgo.Opt {
+	o := make([]kgo.Opt, 0, len(s.connOpts)+len(opts))
+	o = append(o, s.connOpts...)
+	return append(o, opts...)
+}
+
+// deadLetterTopic returns the Kafka topic that stores events that could
+// not be delivered for a subscription, prefixed by the broker topic name.
+func (s *kafka) deadLetterTopic(subscription string) string {
+	return s.args.Topic + ".deadletter." + subscription
+}
+
+// ensureDeadLetterTopic does its best to create the dead letter topic
+// the first time it is used.
+func (s *kafka) ensureDeadLetterTopic(ctx context.Context, topic string) {
+	if _, ok := s.dlTopics.Load(topic); ok {
+		return
+	}
+
+	if _, err := kadm.NewClient(s.client).CreateTopic(ctx, -1, -1, nil, topic); err != nil && err != kerr.TopicAlreadyExists {
+		s.logger.Warnw("Could not ensure that dead letter topic exists. We will continue under the premise that it is already provided.",
+			zap.String("topic", topic), zap.Error(err))
+		return
+	}
+
+	s.dlTopics.Store(topic, struct{}{})
+}
+
+// ProduceDeadLetter adds the event to the subscription's dead letter topic.
+func (s *kafka) ProduceDeadLetter(ctx context.Context, subscription string, event *cloudevents.Event) error {
+	b, err := event.MarshalJSON()
+	if err != nil {
+		return fmt.Errorf("could not serialize CloudEvent: %w", err)
+	}
+
+	topic := s.deadLetterTopic(subscription)
+	s.ensureDeadLetterTopic(ctx, topic)
+
+	if err := s.client.ProduceSync(ctx, &kgo.Record{
+		Topic: topic,
+		Value: b,
+	}).FirstErr(); err != nil {
+		return fmt.Errorf("could not produce CloudEvent to Kafka dead letter topic %q: %w", topic, err)
+	}
+
+	return nil
+}
+
+// ListDeadLetters reads the records between the start and end offsets of
+// each partition at the dead letter topic.
+func (s *kafka) ListDeadLetters(ctx context.Context, subscription string, count int) ([]backend.DeadLetter, error) {
+	topic := s.deadLetterTopic(subscription)
+	adm := kadm.NewClient(s.client)
+
+	starts, err := adm.ListStartOffsets(ctx, topic)
+	if err != nil {
+		return nil, fmt.Errorf("could not list dead letter topic start offsets: %w", err)
+	}
+	ends, err := adm.ListEndOffsets(ctx, topic)
+	if err != nil {
+		return nil, fmt.Errorf("could not list dead letter topic end offsets: %w", err)
+	}
+
+	// Only read partitions that contain records.
+	pending := make(map[int32]int64)
+	offsets := make(map[int32]kgo.Offset)
+	starts.Each(func(lo kadm.ListedOffset) {
+		end, ok := ends.Lookup(lo.Topic, lo.Partition)
+		if lo.Err != nil || !ok || end.Err != nil || end.Offset <= lo.Offset {
+			return
+		}
+		pending[lo.Partition] = end.Offset
+		offsets[lo.Partition] = kgo.NewOffset().At(lo.Offset)
+	})
+
+	if len(pending) == 0 {
+		return []backend.DeadLetter{}, nil
+	}
+
+	records, err := s.readDeadLetters(ctx, topic, offsets, func(r *kgo.Record) bool {
+		if r.Offset+1 >= pending[r.Partition] {
+			delete(pending, r.Partition)
+		}
+		return len(pending) == 0
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Records are returned in timestamp order across partitions.
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Timestamp.Before(records[j].Timestamp)
+	})
+
+	if count > 0 && len(records) > count {
+		records = records[:count]
+	}
+
+	dls := make([]backend.DeadLetter, 0, len(records))
+	for _, r := range records {
+		dl, err := deadLetterFromRecord(r)
+		if err != nil {
+			return nil, err
+		}
+		dls = append(dls, *dl)
+	}
+
+	return dls, nil
+}
+
+func (s *kafka) GetDeadLetter(ctx context.Context, subscription, id string) (*backend.DeadLetter, error) {
+	partition, offset, err := parseDeadLetterID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	topic := s.deadLetterTopic(subscription)
+	records, err := s.readDeadLetters(ctx, topic,
+		map[int32]kgo.Offset{partition: kgo.NewOffset().At(offset)},
+		func(*kgo.Record) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 || records[0].Offset != offset {
+		return nil, fmt.Errorf("dead letter %s not found", id)
+	}
+
+	return deadLetterFromRecord(records[0])
+}
+
+// DeleteDeadLetter removes a record from the dead letter topic. Kafka can
+// only delete records from the start of a partition, the record must be
+// the oldest at its partition.
+func (s *kafka) DeleteDeadLetter(ctx context.Context, subscription, id string) error {
+	partition, offset, err := parseDeadLetterID(id)
+	if err != nil {
+		return err
+	}
+
+	topic := s.deadLetterTopic(subscription)
+	adm := kadm.NewClient(s.client)
+
+	starts, err := adm.ListStartOffsets(ctx, topic)
+	if err != nil {
+		return fmt.Errorf("could not list dead letter topic start offsets: %w", err)
+	}
+
+	start, ok := starts.Lookup(topic, partition)
+	switch {
+	case !ok || start.Err != nil || start.Offset > offset:
+		return fmt.Errorf("dead letter %s not found", id)
+	case start.Offset < offset:
+		return fmt.Errorf("dead letter %s cannot be deleted before the oldest record at the partition, %s",
+			id, deadLetterID(partition, start.Offset))
+	}
+
+	var dos kadm.Offsets
+	dos.Add(kadm.Offset{Topic: topic, Partition: partition, At: offset + 1})
+	res, err := adm.DeleteRecords(ctx, dos)
+	if err != nil {
+		return fmt.Errorf("could not delete from dead letter topic: %w", err)
+	}
+	if r, ok := res.Lookup(topic, partition); ok && r.Err != nil {
+		return fmt.Errorf("could not delete from dead letter topic: %w", r.Err)
+	}
+
+	return nil
+}
+
+// readDeadLetters consumes the dead letter topic partitions from the informed
+// offsets until the done function returns true or the read times out.
+func (s *kafka) readDeadLetters(ctx context.Context, topic string, offsets map[int32]kgo.Offset, done func(*kgo.Record) bool) ([]*kgo.Record, error) {
+	client, err := kgo.NewClient(s.clientOpts(
+		kgo.ConsumePartitions(map[string]map[int32]kgo.Offset{topic: offsets}))...)
+	if err != nil {
+		return nil, fmt.Errorf("could not create kafka client for dead letter topic: %w", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, deadLetterReadTimeout)
+	defer cancel()
+
+	records := []*kgo.Record{}
+	for {
+		fetches := client.PollFetches(ctx)
+		if ctx.Err() != nil {
+			return records, nil
+		}
+		if err := fetches.Err(); err != nil {
+			return nil, fmt.Errorf("could not read dead letter topic: %w", err)
+		}
+
+		for _, r := range fetches.Records() {
+			records = append(records, r)
+			if done(r) {
+				return records, nil
+			}
+		}
+	}
+}
+
+func deadLetterFromRecord(r *kgo.Record) (*backend.DeadLetter, error) {
+	id := deadLetterID(r.Partition, r.Offset)
+
+	ce := &cloudevents.Event{}
+	if err := ce.UnmarshalJSON(r.Value); err != nil {
+		return nil, fmt.Errorf("dead letter %s: could not unmarshal CloudEvent: %w", id, err)
+	}
+
+	return &backend.DeadLetter{ID: id, Event: ce}, nil
+}
+
+// deadLetterID identifies a record at the dead letter topic
+// using the partition and offset.
+func deadLetterID(partition int32, offset int64) string {
+	return fmt.Sprintf("%d-%d", partition, offset)
+}
+
+func parseDeadLetterID(id string) (int32, int64, error) {
+	ps, ofs, ok := strings.Cut(id, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("dead letter ID %q must use the <partition>-<offset> format", id)
+	}
+
+	partition, err := strconv.ParseInt(ps, 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("dead letter ID %q partition is not valid: %w", id, err)
+	}
+
+	offset, err := strconv.ParseInt(ofs, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("dead letter ID %q offset is not valid: %w", id, err)
+	}
+
+	return int32(partition), offset, nil
+}
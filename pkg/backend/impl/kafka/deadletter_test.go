@@ -0,0 +1,55 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package kafka
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDeadLetterID(t *testing.T) {
+	testCases := map[string]struct {
+		id string
+
+		expectedPartition int32
+		expectedOffset    int64
+		expectedError     string
+	}{
+		"valid": {
+			id:                "2-1034",
+			expectedPartition: 2,
+			expectedOffset:    1034,
+		},
+		"missing offset": {
+			id:            "2",
+			expectedError: `dead letter ID "2" must use the <partition>-<offset> format`,
+		},
+		"partition not valid": {
+			id:            "a-1034",
+			expectedError: `dead letter ID "a-1034" partition is not valid`,
+		},
+		"offset not valid": {
+			id:            "2-1034-0",
+			expectedError: `dead letter ID "2-1034-0" offset is not valid`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			partition, offset, err := parseDeadLetterID(tc.id)
+			if tc.expectedError != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedPartition, partition)
+			assert.Equal(t, tc.expectedOffset, offset)
+			assert.Equal(t, tc.id, deadLetterID(partition, offset))
+		})
+	}
+}
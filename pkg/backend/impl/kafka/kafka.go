@@ -46,6 +46,10 @@ func New(args *KafkaArgs, logger *zap.SugaredLogger) backend.Interface {
 type kafka struct {
 	args *KafkaArgs
 
+	// Client options for connecting to Kafka, used for
+	// clients that do not consume the broker topic.
+	connOpts []kgo.Opt
+
 	// Client options for creating subscriptions
 	kopts []kgo.Opt
 
@@ -55,6 +59,9 @@ type kafka struct {
 	// subscription list indexed by the name.
 	subs map[string]*subscription
 
+	// dead letter topics that have been ensured to exist.
+	dlTopics sync.Map
+
 	// Waitgroup that should be used to wait for subscribers
 	// before disconnecting.
 	wgSubs sync.WaitGroup
@@ -76,11 +83,8 @@ func (s *kafka) Info() *backend.Info {
 
 func (s *kafka) Init(ctx context.Context) error {
 
-	s.kopts = []kgo.Opt{
+	s.connOpts = []kgo.Opt{
 		kgo.SeedBrokers(s.args.Addresses...),
-
-		kgo.ConsumeTopics(s.args.Topic),
-		kgo.InstanceID(s.args.Instance),
 	}
 
 	if ok, _ := s.args.IsGSSAPI(); ok {
@@ -94,7 +98,7 @@ func (s *kafka) Init(ctx context.Context) error {
 			return fmt.Errorf("could not load kerberos config file: %w", err)
 		}
 
-		s.kopts = append(s.kopts,
+		s.connOpts = append(s.connOpts,
 			kgo.SASL(
 				kerberos.Auth{
 					Client: krbclient.NewWithKeytab(
@@ -108,6 +112,11 @@ func (s *kafka) Init(ctx context.Context) error {
 			))
 	}
 
+	s.kopts = s.clientOpts(
+		kgo.ConsumeTopics(s.args.Topic),
+		kgo.InstanceID(s.args.Instance),
+	)
+
 	client, err := kgo.NewClient(s.kopts...)
 	if err != nil {
 		return fmt.Errorf("could not create kafka client: %w", err)
@@ -187,9 +196,54 @@ func (s *kafka) Produce(ctx context.Context, event *cloudevents.Event) error {
 	return nil
 }
 
+// ProduceBatch produces all events as records using a single
+// synchronous produce call.
+func (s *kafka) ProduceBatch(ctx context.Context, events []*cloudevents.Event) []error {
+	errs := make([]error, len(events))
+
+	// Produce results are not guaranteed to be returned in order,
+	// keep track of the position of each record.
+	records := make([]*kgo.Record, 0, len(events))
+	positions := make(map[*kgo.Record]int, len(events))
+
+	for i, event := range events {
+		b, err := event.MarshalJSON()
+		if err != nil {
+			errs[i] = fmt.Errorf("could not serialize CloudEvent: %w", err)
+			continue
+		}
+
+		r := &kgo.Record{
+			Topic: s.args.Topic,
+			Value: b,
+		}
+		records = append(records, r)
+		positions[r] = i
+	}
+
+	if len(records) == 0 {
+		return errs
+	}
+
+	for _, res := range s.client.ProduceSync(ctx, records...) {
+		i := positions[res.Record]
+		if res.Err != nil {
+			errs[i] = fmt.Errorf("could not produce CloudEvent to Kafka topic %q: %w", s.args.Topic, res.Err)
+			continue
+		}
+
+		s.logger.Debug(fmt.Sprintf("CloudEvent %s/%s produced to the backend as %d",
+			events[i].Context.GetSource(),
+			events[i].Context.GetID(),
+			res.Record.Offset))
+	}
+
+	return errs
+}
+
 // SubscribeBounded is a variant of the Subscribe function that supports bounded subscriptions.
 // It adds the option of using a startId and endId for the replay feature.
-func (s *kafka) Subscribe(name string, bounds *broker.TriggerBounds, ccb backend.ConsumerDispatcher, scb backend.SubscriptionStatusChange) error {
+func (s *kafka) Subscribe(name string, bounds *broker.TriggerBounds, ccb backend.ConsumerDispatcher, scb backend.SubscriptionStatusChange, opts ...backend.SubscribeOption) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -208,11 +262,21 @@ func (s *kafka) Subscribe(name string, bounds *broker.TriggerBounds, ccb backend
 		return fmt.Errorf("subscription bounds could not be resolved: %w", err)
 	}
 
+	so := backend.NewSubscribeOptions(opts...)
+	group := s.args.ConsumerGroupPrefix + "." + name
+
 	kopts := append(s.kopts,
 		kgo.ConsumeResetOffset(startOpt),
-		kgo.ConsumerGroup(s.args.ConsumerGroupPrefix+"."+name),
+		kgo.ConsumerGroup(group),
 		kgo.DisableAutoCommit())
 
+	// The reset offset only applies when the group has no committed offsets,
+	// existing positions are moved to the start bound when requested.
+	if so.ResetPosition && bounds != nil && (bounds.ByID.GetStart() != "" || bounds.ByDate.GetStart() != "") {
+		kopts = append(kopts, kgo.AdjustFetchOffsetsFn(newOffsetReset(startOpt).adjust))
+		s.logger.Infow("Consumer group position will be reset", zap.String("group", group), zap.Stringer("offset", startOpt))
+	}
+
 	client, err := kgo.NewClient(kopts...)
 	if err != nil {
 		return fmt.Errorf("client for subscription could not be created: %w", err)
@@ -244,6 +308,9 @@ func (s *kafka) Subscribe(name string, bounds *broker.TriggerBounds, ccb backend
 		// stoppedCh signals when a subscription has completely finished.
 		stoppedCh: make(chan struct{}),
 
+		// settings informed by the caller.
+		options: so,
+
 		client: client,
 		logger: s.logger,
 	}
@@ -285,7 +352,9 @@ func (s *kafka) unsubscribe(name string) {
 			zap.String("name", name))
 	}
 
-	s.client.Close()
+	// Close the subscription's consumer client, the backend client
+	// is still used for producing.
+	sub.client.Close()
 	delete(s.subs, name)
 	s.wgSubs.Done()
 }
@@ -0,0 +1,65 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package kafka
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+	"github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/status"
+)
+
+func TestResubscribeKeepsProducer(t *testing.T) {
+	// No Kafka is listening, clients are only used to
+	// tell whether they have been closed.
+	opts := []kgo.Opt{
+		kgo.SeedBrokers("127.0.0.1:1"),
+		kgo.RetryTimeout(100 * time.Millisecond),
+	}
+
+	producer, err := kgo.NewClient(opts...)
+	require.NoError(t, err)
+	defer producer.Close()
+
+	s := New(&KafkaArgs{Topic: "triggermesh", ConsumerGroupPrefix: "test"}, zaptest.NewLogger(t).Sugar()).(*kafka)
+	s.client = producer
+	s.kopts = opts
+
+	ccb := func(*cloudevents.Event) {}
+	scb := func(*status.SubscriptionStatus) {}
+
+	start1, start2 := "1", "2"
+	require.NoError(t, s.Subscribe("trigger1", &broker.TriggerBounds{ByID: &broker.Bounds{Start: &start1}}, ccb, scb))
+	consumer := s.subs["trigger1"].client
+
+	// Bounds change re-subscribes the trigger.
+	s.Unsubscribe("trigger1")
+	require.NoError(t, s.Subscribe("trigger1", &broker.TriggerBounds{ByID: &broker.Bounds{Start: &start2}}, ccb, scb,
+		backend.WithResetPosition()))
+	defer s.Unsubscribe("trigger1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	assert.True(t, consumer.PollFetches(ctx).IsClientClosed(), "Previous subscription client was not closed")
+	err = s.Produce(ctx, newEvent())
+	assert.NotErrorIs(t, err, kgo.ErrClientClosed, "Producer client must not be closed when unsubscribing")
+}
+
+func newEvent() *cloudevents.Event {
+	e := cloudevents.NewEvent()
+	e.SetID("1")
+	e.SetType("test.type")
+	e.SetSource("test.source")
+	return &e
+}
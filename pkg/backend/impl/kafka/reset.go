@@ -0,0 +1,58 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package kafka
+
+import (
+	"context"
+	"sync"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// offsetReset moves the consumer group position to the start bound. Committed
+// offsets cannot be altered from outside the group while other broker replicas
+// are consuming, instead the subscription replaces the fetched offsets for
+// each partition the first time it is assigned to it.
+type offsetReset struct {
+	start kgo.Offset
+
+	// done contains the partitions that were already
+	// reset, indexed by topic.
+	done map[string]map[int32]struct{}
+	m    sync.Mutex
+}
+
+func newOffsetReset(start kgo.Offset) *offsetReset {
+	return &offsetReset{
+		// Epoch is cleared to avoid data loss detection
+		// when moving the position.
+		start: start.WithEpoch(-1),
+		done:  make(map[string]map[int32]struct{}),
+	}
+}
+
+// adjust is called by the consumer group with the offsets fetched for the
+// assigned partitions before consumption begins.
+func (r *offsetReset) adjust(_ context.Context, offsets map[string]map[int32]kgo.Offset) (map[string]map[int32]kgo.Offset, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	for topic, partitions := range offsets {
+		done, ok := r.done[topic]
+		if !ok {
+			done = make(map[int32]struct{})
+			r.done[topic] = done
+		}
+
+		for p := range partitions {
+			if _, ok := done[p]; ok {
+				continue
+			}
+			partitions[p] = r.start
+			done[p] = struct{}{}
+		}
+	}
+
+	return offsets, nil
+}
@@ -0,0 +1,33 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestOffsetReset(t *testing.T) {
+	start := kgo.NewOffset().At(10)
+	committed := kgo.NewOffset().At(50)
+	r := newOffsetReset(start)
+
+	offsets, err := r.adjust(context.Background(), map[string]map[int32]kgo.Offset{
+		"topic": {0: committed},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, start.WithEpoch(-1), offsets["topic"][0], "Assigned partition was not reset")
+
+	// After a rebalance only partitions that were not reset yet are moved.
+	offsets, err = r.adjust(context.Background(), map[string]map[int32]kgo.Offset{
+		"topic": {0: committed, 1: committed},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, committed, offsets["topic"][0], "Partition was reset more than once")
+	assert.Equal(t, start.WithEpoch(-1), offsets["topic"][1], "Newly assigned partition was not reset")
+}
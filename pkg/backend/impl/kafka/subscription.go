@@ -67,6 +67,9 @@ type subscription struct {
 	// caller's callback for subscription status changes
 	scb backend.SubscriptionStatusChange
 
+	// options informed by the caller when subscribing.
+	options *backend.SubscribeOptions
+
 	// cancel function let us control when the subscription loop should exit.
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -106,6 +109,12 @@ func (s *subscription) start() {
 				break
 			}
 
+			// The caller might pause consumption for the subscription,
+			// waiting returns an error when the context is done.
+			if err := s.options.WaitGate(s.ctx); err != nil {
+				break
+			}
+
 			// Although this call is blocking it will yield when the context is done,
 			// the exit loop flag above will be triggered almost immediately if no
 			// data has been read.
@@ -128,6 +137,16 @@ func (s *subscription) start() {
 			})
 
 			fetches.EachRecord(func(record *kgo.Record) {
+				// The caller might throttle consumption for the subscription,
+				// each polled record is processed only after the dispatch is
+				// accepted. The release function is called once the record is
+				// dispatched or discarded.
+				release, err := s.options.Acquire(s.ctx)
+				if err != nil {
+					exitLoop = true
+					return
+				}
+
 				defer func() {
 					if err := s.client.CommitUncommittedOffsets(s.ctx); err != nil {
 						s.logger.Errorw("Could not commit offsets", zap.Error(err))
@@ -137,12 +156,14 @@ func (s *subscription) start() {
 				ce := &cloudevents.Event{}
 				if err := ce.UnmarshalJSON(record.Value); err != nil {
 					s.logger.Errorw("Could not unmarshal CloudEvent from Kafka", zap.Error(err))
+					release()
 					return
 				}
 
 				// If there was no valid CE in the message ACK so that we do not receive it again.
 				if err := ce.Validate(); err != nil {
 					s.logger.Warn(fmt.Sprintf("Removing non CloudEvent message from backend: %v", record.Offset))
+					release()
 					return
 				}
 
@@ -154,6 +175,7 @@ func (s *subscription) start() {
 						s.scb(&status.SubscriptionStatus{
 							Status: status.SubscriptionStatusComplete,
 						})
+						release()
 						return
 					}
 				}
@@ -167,6 +189,7 @@ func (s *subscription) start() {
 
 				go func(rs *kgo.Record) {
 					s.ccbDispatch(ce)
+					release()
 					if err := s.client.CommitRecords(s.ctx, rs); err != nil {
 						s.logger.Errorw(fmt.Sprintf("could not commit the Kafka offset %d containing CloudEvent %s", rs.Offset, ce.Context.GetID()),
 							zap.Error(err))
@@ -14,6 +14,8 @@ import (
 type MemoryArgs struct {
 	BufferSize     int    `help:"Number of events that can be hosted in the backend." env:"BUFFER_SIZE" default:"10000"`
 	ProduceTimeout string `help:"Maximum wait time for producing an event to the backend." env:"PRODUCE_TIMEOUT" default:"PT5S"`
+	DedupCacheSize int    `help:"Maximum number of events tracked for ingest deduplication." env:"DEDUP_CACHE_SIZE" default:"10000"`
+	HighWaterMark  int    `help:"Percentage of the buffer in use above which ingest rejects events." env:"HIGH_WATER_MARK" default:"90"`
 
 	ProduceTimeoutDuration time.Duration `kong:"-"`
 }
@@ -40,6 +42,14 @@ func (ma *MemoryArgs) Validate() error {
 		}
 	}
 
+	if ma.DedupCacheSize < 1 {
+		msg = append(msg, "Deduplication cache size must be greater than 0.")
+	}
+
+	if ma.HighWaterMark < 1 || ma.HighWaterMark > 100 {
+		msg = append(msg, "High-water mark must be a percentage between 1 and 100.")
+	}
+
 	if len(msg) == 0 {
 		return nil
 	}
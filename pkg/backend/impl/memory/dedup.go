@@ -0,0 +1,82 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+type dedupEntry struct {
+	key     string
+	expires time.Time
+}
+
+// dedupCache is an LRU cache with expiring entries used to
+// keep track of ingested events.
+type dedupCache struct {
+	size    int
+	entries map[string]*list.Element
+	// order of entries, the most recently used at the front.
+	order *list.List
+
+	m sync.Mutex
+}
+
+func newDedupCache(size int) *dedupCache {
+	return &dedupCache{
+		size:    size,
+		entries: make(map[string]*list.Element, size),
+		order:   list.New(),
+	}
+}
+
+func (c *dedupCache) seen(key string, window time.Duration, now time.Time) bool {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		e := el.Value.(*dedupEntry)
+		if now.Before(e.expires) {
+			c.order.MoveToFront(el)
+			return true
+		}
+
+		// Expired entry is renewed.
+		e.expires = now.Add(window)
+		c.order.MoveToFront(el)
+		return false
+	}
+
+	c.entries[key] = c.order.PushFront(&dedupEntry{key: key, expires: now.Add(window)})
+
+	for c.order.Len() > c.size {
+		el := c.order.Back()
+		c.order.Remove(el)
+		delete(c.entries, el.Value.(*dedupEntry).key)
+	}
+
+	return false
+}
+
+func (c *dedupCache) forget(key string) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.order.Remove(el)
+		delete(c.entries, key)
+	}
+}
+
+func (s *memory) Seen(ctx context.Context, key string, window time.Duration) (bool, error) {
+	return s.dedup.seen(key, window, time.Now()), nil
+}
+
+func (s *memory) Forget(ctx context.Context, key string) error {
+	s.dedup.forget(key)
+	return nil
+}
@@ -0,0 +1,30 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDedupCache(t *testing.T) {
+	now := time.Now()
+	window := time.Minute
+	c := newDedupCache(2)
+
+	assert.False(t, c.seen("a", window, now), "first occurrence must not be seen")
+	assert.True(t, c.seen("a", window, now.Add(time.Second)), "second occurrence within window must be seen")
+	assert.False(t, c.seen("a", window, now.Add(2*window)), "occurrence after the window must not be seen")
+
+	// Fill the cache, b is the least recently used and will be evicted.
+	assert.False(t, c.seen("b", window, now))
+	assert.True(t, c.seen("a", window, now))
+	assert.False(t, c.seen("c", window, now))
+	assert.False(t, c.seen("b", window, now), "evicted entry must not be seen")
+
+	c.forget("b")
+	assert.False(t, c.seen("b", window, now), "forgotten entry must not be seen")
+}
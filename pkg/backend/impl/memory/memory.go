@@ -20,7 +20,9 @@ import (
 func New(args *MemoryArgs, logger *zap.SugaredLogger) backend.Interface {
 	return &memory{
 		ccbs:    make(map[string]backend.ConsumerDispatcher),
+		opts:    make(map[string]*backend.SubscribeOptions),
 		closing: false,
+		dedup:   newDedupCache(args.DedupCacheSize),
 		args:    args,
 		logger:  logger,
 	}
@@ -30,15 +32,18 @@ type memory struct {
 	args *MemoryArgs
 
 	ccbs    map[string]backend.ConsumerDispatcher
+	opts    map[string]*backend.SubscribeOptions
 	closing bool
 	buffer  chan *cloudevents.Event
+	dedup   *dedupCache
 	logger  *zap.SugaredLogger
 	m       sync.RWMutex
 }
 
 func (s *memory) Info() *backend.Info {
 	return &backend.Info{
-		Name: "Memory",
+		Name:           "Memory",
+		SharedDispatch: true,
 	}
 }
 
@@ -60,7 +65,23 @@ func (s *memory) Produce(ctx context.Context, event *cloudevents.Event) error {
 	return nil
 }
 
-func (s *memory) Subscribe(name string, bounds *broker.TriggerBounds, ccb backend.ConsumerDispatcher, scb backend.SubscriptionStatusChange) error {
+func (s *memory) ProduceBatch(ctx context.Context, events []*cloudevents.Event) []error {
+	errs := make([]error, len(events))
+	for i := range events {
+		errs[i] = s.Produce(ctx, events[i])
+	}
+	return errs
+}
+
+// Overloaded returns true when the buffer usage is above the high-water mark.
+func (s *memory) Overloaded() bool {
+	if s.buffer == nil {
+		return false
+	}
+	return len(s.buffer)*100 >= cap(s.buffer)*s.args.HighWaterMark
+}
+
+func (s *memory) Subscribe(name string, bounds *broker.TriggerBounds, ccb backend.ConsumerDispatcher, scb backend.SubscriptionStatusChange, opts ...backend.SubscribeOption) error {
 	if bounds != nil {
 		return errors.New("bounds not supported for memory broker")
 	}
@@ -68,6 +89,7 @@ func (s *memory) Subscribe(name string, bounds *broker.TriggerBounds, ccb backen
 	s.m.Lock()
 	defer s.m.Unlock()
 	s.ccbs[name] = ccb
+	s.opts[name] = backend.NewSubscribeOptions(opts...)
 
 	return nil
 }
@@ -76,6 +98,7 @@ func (s *memory) Unsubscribe(name string) {
 	s.m.Lock()
 	defer s.m.Unlock()
 	delete(s.ccbs, name)
+	delete(s.opts, name)
 }
 
 func (s *memory) Start(ctx context.Context) error {
@@ -112,8 +135,18 @@ func (s *memory) Start(ctx context.Context) error {
 func (s *memory) fanOut(event *cloudevents.Event) {
 	s.m.RLock()
 	defer s.m.RUnlock()
-	for _, ccb := range s.ccbs {
+	for name, ccb := range s.ccbs {
+		// Events are dispatched synchronously, throttling a
+		// subscription delays the dispatch for the rest. The
+		// consumer gate is not applied, paused subscriptions
+		// do not wait when dispatching (see SharedDispatch).
+		release, err := s.opts[name].Acquire(context.Background())
+		if err != nil {
+			s.logger.Errorw("Could not acquire dispatch for subscription", zap.String("name", name), zap.Error(err))
+			continue
+		}
 		ccb(event)
+		release()
 	}
 }
 
@@ -0,0 +1,156 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// groupName returns the consumer group for a subscription.
+func (s *redis) groupName(subscription string) string {
+	return s.args.Group + "." + subscription
+}
+
+// RemoveSubscription schedules destroying the consumer group and the dead
+// letter stream of a deleted subscription after the grace period, when
+// group cleanup is enabled.
+func (s *redis) RemoveSubscription(name string) {
+	if !s.args.GroupCleanup {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.scheduleGroupCleanup(name)
+}
+
+// RemoveOrphanedSubscriptions looks for consumer groups at the streams and
+// dead letter streams that do not belong to any of the subscriptions,
+// scheduling them to be destroyed after the grace period, when group cleanup
+// is enabled.
+func (s *redis) RemoveOrphanedSubscriptions(ctx context.Context, names []string) error {
+	if !s.args.GroupCleanup {
+		return nil
+	}
+
+	configured := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		configured[name] = struct{}{}
+	}
+
+	prefix := s.groupName("")
+	orphaned := make(map[string]struct{})
+	for _, stream := range s.streams() {
+		groups, err := s.client.XInfoGroups(ctx, stream).Result()
+		if err != nil {
+			// Streams that do not exist yet have no groups.
+			if strings.Contains(err.Error(), "no such key") {
+				continue
+			}
+			return fmt.Errorf("could not list consumer groups for stream %s: %w", stream, err)
+		}
+
+		for _, g := range groups {
+			if !strings.HasPrefix(g.Name, prefix) {
+				continue
+			}
+			name := strings.TrimPrefix(g.Name, prefix)
+			if _, ok := configured[name]; !ok {
+				orphaned[name] = struct{}{}
+			}
+		}
+	}
+
+	dls, err := s.deadLetterSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+	for _, name := range dls {
+		if _, ok := configured[name]; !ok {
+			orphaned[name] = struct{}{}
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for name := range orphaned {
+		if _, ok := s.subs[name]; ok {
+			continue
+		}
+		s.scheduleGroupCleanup(name)
+	}
+
+	return nil
+}
+
+// scheduleGroupCleanup must be called with the lock held.
+func (s *redis) scheduleGroupCleanup(name string) {
+	if _, ok := s.cleanups[name]; ok {
+		return
+	}
+
+	s.logger.Infow("Scheduling consumer group removal",
+		zap.String("group", s.groupName(name)),
+		zap.Duration("gracePeriod", s.args.GroupCleanupGracePeriodDuration))
+
+	s.cleanups[name] = time.AfterFunc(s.args.GroupCleanupGracePeriodDuration, func() {
+		s.destroyGroup(name)
+	})
+}
+
+// cancelGroupCleanup must be called with the lock held.
+func (s *redis) cancelGroupCleanup(name string) {
+	t, ok := s.cleanups[name]
+	if !ok {
+		return
+	}
+
+	t.Stop()
+	delete(s.cleanups, name)
+	s.logger.Infow("Cancelled consumer group removal", zap.String("group", s.groupName(name)))
+}
+
+// destroyGroup removes the subscription's consumer group from all streams
+// and its dead letter stream, unless the removal has been cancelled.
+func (s *redis) destroyGroup(name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.cleanups[name]; !ok {
+		return
+	}
+	delete(s.cleanups, name)
+
+	if _, ok := s.subs[name]; ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), unsubscribeTimeout)
+	defer cancel()
+
+	group := s.groupName(name)
+	for _, stream := range s.streams() {
+		if err := s.client.XGroupDestroy(ctx, stream, group).Err(); err != nil {
+			s.logger.Errorw("Could not destroy consumer group", zap.String("group", group),
+				zap.String("stream", stream), zap.Error(err))
+			continue
+		}
+		s.logger.Infow("Destroyed consumer group", zap.String("group", group), zap.String("stream", stream))
+	}
+
+	dl := s.deadLetterStream(name)
+	n, err := s.client.Del(ctx, dl).Result()
+	if err != nil {
+		s.logger.Errorw("Could not delete dead letter stream", zap.String("stream", dl), zap.Error(err))
+		return
+	}
+	if n != 0 {
+		s.logger.Infow("Deleted dead letter stream", zap.String("stream", dl))
+	}
+}
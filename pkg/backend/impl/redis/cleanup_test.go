@@ -0,0 +1,174 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func newCleanupBackend(t *testing.T, subscriptions ...string) (*redis, *goredis.Client) {
+	mr := miniredis.RunT(t)
+	client := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	s := New(&RedisArgs{
+		Stream:                          "triggermesh",
+		Partitions:                      2,
+		Group:                           "default",
+		GroupCleanup:                    true,
+		GroupCleanupGracePeriodDuration: 10 * time.Millisecond,
+	}, zaptest.NewLogger(t).Sugar()).(*redis)
+	s.client = client
+
+	for _, stream := range s.streams() {
+		for _, name := range subscriptions {
+			require.NoError(t, client.XGroupCreateMkStream(context.Background(), stream, s.groupName(name), "$").Err())
+		}
+	}
+
+	return s, client
+}
+
+// groups returns the sorted consumer groups for each stream.
+func groups(t *testing.T, s *redis, client *goredis.Client) map[string][]string {
+	res := make(map[string][]string)
+	for _, stream := range s.streams() {
+		gs, err := client.XInfoGroups(context.Background(), stream).Result()
+		require.NoError(t, err)
+
+		res[stream] = []string{}
+		for _, g := range gs {
+			res[stream] = append(res[stream], g.Name)
+		}
+		sort.Strings(res[stream])
+	}
+	return res
+}
+
+func TestRemoveSubscription(t *testing.T) {
+	s, client := newCleanupBackend(t, "trigger1", "trigger2")
+
+	s.RemoveSubscription("trigger1")
+
+	expected := map[string][]string{
+		"triggermesh.0": {"default.trigger2"},
+		"triggermesh.1": {"default.trigger2"},
+	}
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual(expected, groups(t, s, client))
+	}, time.Second, 10*time.Millisecond, "Consumer group was not destroyed at all streams")
+}
+
+func TestRemoveSubscriptionDeadLetter(t *testing.T) {
+	s, client := newCleanupBackend(t, "trigger1", "trigger2")
+
+	event := cloudevents.NewEvent()
+	event.SetID("1")
+	event.SetType("test.type")
+	event.SetSource("test.source")
+	for _, name := range []string{"trigger1", "trigger2"} {
+		require.NoError(t, s.ProduceDeadLetter(context.Background(), name, &event))
+	}
+
+	s.RemoveSubscription("trigger1")
+
+	assert.Eventually(t, func() bool {
+		n, err := client.Exists(context.Background(), s.deadLetterStream("trigger1")).Result()
+		return err == nil && n == 0
+	}, time.Second, 10*time.Millisecond, "Dead letter stream was not deleted")
+
+	n, err := client.XLen(context.Background(), s.deadLetterStream("trigger2")).Result()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n, "Dead letter stream of a configured trigger was modified")
+}
+
+func TestRemoveSubscriptionCancelled(t *testing.T) {
+	s, client := newCleanupBackend(t, "trigger1")
+	s.args.GroupCleanupGracePeriodDuration = 50 * time.Millisecond
+
+	s.RemoveSubscription("trigger1")
+
+	// Subscribing again within the grace period keeps the group.
+	s.mutex.Lock()
+	s.cancelGroupCleanup("trigger1")
+	s.mutex.Unlock()
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, map[string][]string{
+		"triggermesh.0": {"default.trigger1"},
+		"triggermesh.1": {"default.trigger1"},
+	}, groups(t, s, client))
+}
+
+func TestRemoveOrphanedSubscriptions(t *testing.T) {
+	s, client := newCleanupBackend(t, "trigger1", "orphaned")
+
+	// Groups that do not belong to the broker are kept.
+	for _, stream := range s.streams() {
+		require.NoError(t, client.XGroupCreate(context.Background(), stream, "other", "$").Err())
+	}
+
+	// Dead letter streams are considered even when the groups are gone.
+	event := cloudevents.NewEvent()
+	event.SetID("1")
+	event.SetType("test.type")
+	event.SetSource("test.source")
+	for _, name := range []string{"trigger1", "orphaned", "deleted"} {
+		require.NoError(t, s.ProduceDeadLetter(context.Background(), name, &event))
+	}
+
+	require.NoError(t, s.RemoveOrphanedSubscriptions(context.Background(), []string{"trigger1"}))
+
+	expected := map[string][]string{
+		"triggermesh.0": {"default.trigger1", "other"},
+		"triggermesh.1": {"default.trigger1", "other"},
+	}
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual(expected, groups(t, s, client))
+	}, time.Second, 10*time.Millisecond, "Orphaned consumer group was not destroyed at all streams")
+
+	assert.Eventually(t, func() bool {
+		keys, err := client.Keys(context.Background(), s.deadLetterStream("*")).Result()
+		return err == nil && assert.ObjectsAreEqual([]string{s.deadLetterStream("trigger1")}, keys)
+	}, time.Second, 10*time.Millisecond, "Orphaned dead letter streams were not deleted")
+}
+
+func TestRemoveOrphanedSubscriptionsNoStreams(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	s := New(&RedisArgs{Stream: "triggermesh", Partitions: 2, GroupCleanup: true}, zaptest.NewLogger(t).Sugar()).(*redis)
+	s.client = client
+
+	assert.NoError(t, s.RemoveOrphanedSubscriptions(context.Background(), []string{"trigger1"}))
+}
+
+func TestProduceDeadLetterMaxLen(t *testing.T) {
+	s, client := newCleanupBackend(t)
+	s.args.StreamMaxLen = 2
+
+	for i := 0; i < 5; i++ {
+		event := cloudevents.NewEvent()
+		event.SetID(strconv.Itoa(i))
+		event.SetType("test.type")
+		event.SetSource("test.source")
+		require.NoError(t, s.ProduceDeadLetter(context.Background(), "trigger1", &event))
+	}
+
+	n, err := client.XLen(context.Background(), s.deadLetterStream("trigger1")).Result()
+	require.NoError(t, err)
+	assert.LessOrEqual(t, n, int64(2), "Dead letter stream was not trimmed")
+}
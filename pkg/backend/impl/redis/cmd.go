@@ -6,6 +6,9 @@ package redis
 import (
 	"fmt"
 	"strings"
+	"time"
+
+	"github.com/rickb777/date/period"
 )
 
 type RedisArgs struct {
@@ -21,13 +24,27 @@ type RedisArgs struct {
 	TLSKey           string `help:"TLS Certificate key to connect to Redis." env:"TLS_KEY"`
 	TLSCACertificate string `help:"CA Certificate to connect to Redis." name:"tls-ca-certificate" env:"TLS_CA_CERTIFICATE"`
 
-	Stream string `help:"Stream name that stores the broker's CloudEvents." env:"STREAM" default:"triggermesh"`
-	Group  string `help:"Redis stream consumer group name." env:"GROUP" default:"default"`
+	Stream     string `help:"Stream name that stores the broker's CloudEvents." env:"STREAM" default:"triggermesh"`
+	Partitions int    `help:"Number of streams the broker's CloudEvents are spread across, named after the stream suffixed with the partition ordinal." env:"PARTITIONS" default:"1"`
+	Group      string `help:"Redis stream consumer group name." env:"GROUP" default:"default"`
 	// Instance at the Redis stream consumer group. Copied from the InstanceName at the global args.
 	Instance string `kong:"-"`
 
-	StreamMaxLen      int  `help:"Limit the number of items in a stream by trimming it. Set to 0 for unlimited." env:"STREAM_MAX_LEN" default:"1000"`
+	GroupCleanup            bool   `help:"Destroy the consumer groups and dead letter streams for deleted triggers, and for triggers that are not configured at startup." env:"GROUP_CLEANUP" default:"false"`
+	GroupCleanupGracePeriod string `help:"Wait time before destroying the consumer group of a deleted trigger, using ISO8601." env:"GROUP_CLEANUP_GRACE_PERIOD" default:"PT1H"`
+
+	GroupCleanupGracePeriodDuration time.Duration `kong:"-"`
+
+	StreamMaxLen      int  `help:"Limit the number of items in a stream by trimming it, applied to each partition stream. Set to 0 for unlimited." env:"STREAM_MAX_LEN" default:"1000"`
 	TrackingIDEnabled bool `help:"Enables adding Redis ID as a CloudEvent attribute." env:"TRACKING_ID_ENABLED" default:"false"`
+	OrderedDelivery   bool `help:"Deliver events that share the partitionkey extension, or the subject when not present, in order." env:"ORDERED_DELIVERY" default:"false"`
+
+	Encoding             string `help:"Encoding for events stored at streams: json stores the whole event at a single field, compact stores attributes as separate fields and data as raw bytes." env:"ENCODING" enum:"json,compact" default:"json"`
+	Compression          string `help:"Compression for the data of compact encoded events: none, gzip or zstd." env:"COMPRESSION" enum:"none,gzip,zstd" default:"none"`
+	CompressionThreshold int    `help:"Minimum data size in bytes for compact encoded events to be compressed." env:"COMPRESSION_THRESHOLD" default:"1024"`
+
+	ReadCount   int `help:"Maximum number of messages read from the stream at once for each subscription." env:"READ_COUNT" default:"1"`
+	MaxInFlight int `help:"Maximum number of messages being dispatched at once for each subscription. Set to 0 for unlimited." env:"MAX_IN_FLIGHT" default:"1000"`
 }
 
 func (ra *RedisArgs) Validate() error {
@@ -49,6 +66,31 @@ func (ra *RedisArgs) Validate() error {
 		msg = append(msg, "TLS authentication requires Certificate and Key to be informed")
 	}
 
+	if ra.Compression != "" && ra.Compression != CompressionNone && ra.Encoding != EncodingCompact {
+		msg = append(msg, "compression requires compact encoding.")
+	}
+
+	if ra.GroupCleanup {
+		p, err := period.Parse(ra.GroupCleanupGracePeriod)
+		if err != nil {
+			msg = append(msg, fmt.Sprintf("Group cleanup grace period is not an ISO8601 duration: %v.", err))
+		} else {
+			ra.GroupCleanupGracePeriodDuration = p.DurationApprox()
+		}
+	}
+
+	if ra.Partitions < 1 {
+		msg = append(msg, "partitions must be greater than 0.")
+	}
+
+	if ra.ReadCount < 1 {
+		msg = append(msg, "read count must be greater than 0.")
+	}
+
+	if ra.MaxInFlight < 0 {
+		msg = append(msg, "max in flight cannot be negative.")
+	}
+
 	if len(msg) == 0 {
 		return nil
 	}
@@ -0,0 +1,132 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+)
+
+// deadLetterStream returns the Redis stream that stores events that could
+// not be delivered for a subscription, prefixed by the broker stream name.
+func (s *redis) deadLetterStream(subscription string) string {
+	return s.args.Stream + ".deadletter." + subscription
+}
+
+// deadLetterSubscriptions returns the subscriptions that have a dead letter
+// stream, scanning all master nodes when using a cluster.
+func (s *redis) deadLetterSubscriptions(ctx context.Context) ([]string, error) {
+	prefix := s.deadLetterStream("")
+
+	var m sync.Mutex
+	var names []string
+	scan := func(ctx context.Context, c goredis.Cmdable) error {
+		iter := c.Scan(ctx, 0, prefix+"*", 0).Iterator()
+		for iter.Next(ctx) {
+			if !strings.HasPrefix(iter.Val(), prefix) {
+				continue
+			}
+			m.Lock()
+			names = append(names, strings.TrimPrefix(iter.Val(), prefix))
+			m.Unlock()
+		}
+		return iter.Err()
+	}
+
+	var err error
+	if cc, ok := s.client.(*goredis.ClusterClient); ok {
+		err = cc.ForEachMaster(ctx, func(ctx context.Context, c *goredis.Client) error {
+			return scan(ctx, c)
+		})
+	} else {
+		err = scan(ctx, s.client)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not list dead letter streams: %w", err)
+	}
+
+	return names, nil
+}
+
+// ProduceDeadLetter adds the event to the subscription's dead letter
+// stream. Dead letter streams are trimmed using the same maximum length
+// as the broker streams, entries are also removed when re-driven or
+// deleted.
+func (s *redis) ProduceDeadLetter(ctx context.Context, subscription string, event *cloudevents.Event) error {
+	values, err := s.encoder.encode(event)
+	if err != nil {
+		return err
+	}
+
+	args := &goredis.XAddArgs{
+		Stream: s.deadLetterStream(subscription),
+		Values: values,
+	}
+	if s.args.StreamMaxLen != 0 {
+		args.MaxLen = int64(s.args.StreamMaxLen)
+		args.Approx = true
+	}
+
+	if err := s.client.XAdd(ctx, args).Err(); err != nil {
+		return fmt.Errorf("could not produce CloudEvent to dead letter stream: %w", err)
+	}
+
+	return nil
+}
+
+func (s *redis) ListDeadLetters(ctx context.Context, subscription string, count int) ([]backend.DeadLetter, error) {
+	msgs, err := s.client.XRangeN(ctx, s.deadLetterStream(subscription), "-", "+", int64(count)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("could not read dead letter stream: %w", err)
+	}
+
+	dls := make([]backend.DeadLetter, 0, len(msgs))
+	for _, msg := range msgs {
+		ce, err := eventFromMessage(msg)
+		if err != nil {
+			return nil, fmt.Errorf("dead letter %s: %w", msg.ID, err)
+		}
+		dls = append(dls, backend.DeadLetter{ID: msg.ID, Event: ce})
+	}
+
+	return dls, nil
+}
+
+func (s *redis) GetDeadLetter(ctx context.Context, subscription, id string) (*backend.DeadLetter, error) {
+	msgs, err := s.client.XRangeN(ctx, s.deadLetterStream(subscription), id, id, 1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("could not read dead letter stream: %w", err)
+	}
+
+	if len(msgs) == 0 {
+		return nil, fmt.Errorf("dead letter %s not found", id)
+	}
+
+	ce, err := eventFromMessage(msgs[0])
+	if err != nil {
+		return nil, fmt.Errorf("dead letter %s: %w", id, err)
+	}
+
+	return &backend.DeadLetter{ID: id, Event: ce}, nil
+}
+
+func (s *redis) DeleteDeadLetter(ctx context.Context, subscription, id string) error {
+	n, err := s.client.XDel(ctx, s.deadLetterStream(subscription), id).Result()
+	if err != nil {
+		return fmt.Errorf("could not delete from dead letter stream: %w", err)
+	}
+
+	if n == 0 {
+		return fmt.Errorf("dead letter %s not found", id)
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// dedupKey returns the Redis key used to track ingested events,
+// prefixed by the stream name so that brokers sharing a Redis instance
+// do not collide.
+func (s *redis) dedupKey(key string) string {
+	return s.args.Stream + ".dedup." + key
+}
+
+// Seen uses Redis SET NX EX so that deduplication works across replicas.
+func (s *redis) Seen(ctx context.Context, key string, window time.Duration) (bool, error) {
+	set, err := s.client.SetNX(ctx, s.dedupKey(key), 1, window).Result()
+	if err != nil {
+		return false, fmt.Errorf("could not register deduplication key: %w", err)
+	}
+
+	return !set, nil
+}
+
+func (s *redis) Forget(ctx context.Context, key string) error {
+	if err := s.client.Del(ctx, s.dedupKey(key)).Err(); err != nil {
+		return fmt.Errorf("could not remove deduplication key: %w", err)
+	}
+
+	return nil
+}
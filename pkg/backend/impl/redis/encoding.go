@@ -0,0 +1,232 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding/spec"
+	"github.com/cloudevents/sdk-go/v2/types"
+	"github.com/klauspost/compress/zstd"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const (
+	EncodingJSON    = "json"
+	EncodingCompact = "compact"
+
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+const (
+	// Prefix for the compact encoding fields that contain
+	// the CloudEvent attributes and extensions.
+	attributePrefix = "ce_"
+	// Compact encoding field that contains the raw CloudEvent data.
+	dataKey = "data"
+	// Compact encoding field that informs the data compression.
+	dataEncodingKey = "data_encoding"
+)
+
+var (
+	compactVersions = spec.WithPrefix(attributePrefix)
+
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+)
+
+// encoder serializes CloudEvents into Redis stream message values.
+type encoder struct {
+	compact     bool
+	compression string
+	threshold   int
+}
+
+func newEncoder(args *RedisArgs) *encoder {
+	return &encoder{
+		compact:     args.Encoding == EncodingCompact,
+		compression: args.Compression,
+		threshold:   args.CompressionThreshold,
+	}
+}
+
+// encode returns the values for the stream message. JSON encoding stores the
+// event under a single field, while compact encoding stores each attribute
+// at its own field and the data as raw bytes, compressed if configured and
+// the size is over the threshold.
+func (e *encoder) encode(event *cloudevents.Event) (map[string]interface{}, error) {
+	if !e.compact {
+		b, err := event.MarshalJSON()
+		if err != nil {
+			return nil, fmt.Errorf("could not serialize CloudEvent: %w", err)
+		}
+		return map[string]interface{}{ceKey: b}, nil
+	}
+
+	version := compactVersions.Version(event.SpecVersion())
+	if version == nil {
+		return nil, fmt.Errorf("unsupported CloudEvents spec version %q", event.SpecVersion())
+	}
+
+	values := make(map[string]interface{})
+	for _, a := range version.Attributes() {
+		v := a.Get(event.Context)
+		if v == nil {
+			continue
+		}
+		s, err := types.Format(v)
+		if err != nil {
+			return nil, fmt.Errorf("could not format attribute %s: %w", a.Name(), err)
+		}
+		values[a.PrefixedName()] = s
+	}
+
+	for k, v := range event.Extensions() {
+		s, err := types.Format(v)
+		if err != nil {
+			return nil, fmt.Errorf("could not format extension %s: %w", k, err)
+		}
+		values[attributePrefix+k] = s
+	}
+
+	data := event.Data()
+	if len(data) == 0 {
+		return values, nil
+	}
+
+	if e.compression != "" && e.compression != CompressionNone && len(data) >= e.threshold {
+		cd, err := compress(e.compression, data)
+		if err != nil {
+			return nil, fmt.Errorf("could not compress CloudEvent data: %w", err)
+		}
+		data = cd
+		values[dataEncodingKey] = e.compression
+	}
+	values[dataKey] = data
+
+	return values, nil
+}
+
+// eventFromMessage parses the CloudEvent stored at a Redis stream message,
+// either JSON or compact encoded.
+func eventFromMessage(msg goredis.XMessage) (*cloudevents.Event, error) {
+	if v, ok := msg.Values[ceKey]; ok {
+		str, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected CloudEvent value type %T", v)
+		}
+
+		ce := &cloudevents.Event{}
+		if err := ce.UnmarshalJSON([]byte(str)); err != nil {
+			return nil, fmt.Errorf("could not unmarshal CloudEvent: %w", err)
+		}
+		return ce, nil
+	}
+
+	sv, ok := msg.Values[compactVersions.PrefixedSpecVersionName()]
+	if !ok {
+		return nil, errors.New("message does not contain a CloudEvent")
+	}
+
+	svs, _ := sv.(string)
+	version := compactVersions.Version(svs)
+	if version == nil {
+		return nil, fmt.Errorf("unsupported CloudEvents spec version %q", svs)
+	}
+
+	ce := &cloudevents.Event{Context: version.NewContext()}
+	for k, v := range msg.Values {
+		if !strings.HasPrefix(k, attributePrefix) || k == compactVersions.PrefixedSpecVersionName() {
+			continue
+		}
+		if err := version.SetAttribute(ce.Context, k, v); err != nil {
+			return nil, fmt.Errorf("could not set attribute %s: %w", strings.TrimPrefix(k, attributePrefix), err)
+		}
+	}
+
+	if v, ok := msg.Values[dataKey]; ok {
+		str, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected CloudEvent data type %T", v)
+		}
+
+		data := []byte(str)
+		if enc, ok := msg.Values[dataEncodingKey]; ok {
+			encs, _ := enc.(string)
+			var err error
+			if data, err = decompress(encs, data); err != nil {
+				return nil, fmt.Errorf("could not decompress CloudEvent data: %w", err)
+			}
+		}
+		ce.DataEncoded = data
+	}
+
+	return ce, nil
+}
+
+func compress(codec string, data []byte) ([]byte, error) {
+	switch codec {
+	case CompressionGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+
+	case CompressionZstd:
+		if err := initZstd(); err != nil {
+			return nil, err
+		}
+		return zstdEncoder.EncodeAll(data, nil), nil
+	}
+
+	return nil, fmt.Errorf("unknown compression %q", codec)
+}
+
+func decompress(codec string, data []byte) ([]byte, error) {
+	switch codec {
+	case CompressionGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+
+	case CompressionZstd:
+		if err := initZstd(); err != nil {
+			return nil, err
+		}
+		return zstdDecoder.DecodeAll(data, nil)
+	}
+
+	return nil, fmt.Errorf("unknown compression %q", codec)
+}
+
+// initZstd creates the zstd encoder and decoder the first time
+// they are needed, both are safe for concurrent use.
+func initZstd() error {
+	zstdOnce.Do(func() {
+		if zstdEncoder, zstdErr = zstd.NewWriter(nil); zstdErr != nil {
+			return
+		}
+		zstdDecoder, zstdErr = zstd.NewReader(nil)
+	})
+	return zstdErr
+}
@@ -0,0 +1,106 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncoding(t *testing.T) {
+	largeData := []byte(`{"items":"` + string(bytes.Repeat([]byte("a"), 2048)) + `"}`)
+
+	testCases := map[string]struct {
+		args RedisArgs
+		data []byte
+
+		expectedKeys         []string
+		expectedDataEncoding string
+	}{
+		"json": {
+			args:         RedisArgs{Encoding: EncodingJSON},
+			data:         []byte(`{"hello":"world"}`),
+			expectedKeys: []string{ceKey},
+		},
+		"compact": {
+			args:         RedisArgs{Encoding: EncodingCompact},
+			data:         []byte(`{"hello":"world"}`),
+			expectedKeys: []string{"ce_specversion", "ce_id", "ce_type", "ce_source", "ce_time", "ce_datacontenttype", "ce_ext1", dataKey},
+		},
+		"compact without data": {
+			args:         RedisArgs{Encoding: EncodingCompact},
+			expectedKeys: []string{"ce_specversion", "ce_id", "ce_type", "ce_source", "ce_time", "ce_ext1"},
+		},
+		"compact under the compression threshold": {
+			args:         RedisArgs{Encoding: EncodingCompact, Compression: CompressionGzip, CompressionThreshold: 1024},
+			data:         []byte(`{"hello":"world"}`),
+			expectedKeys: []string{"ce_specversion", "ce_id", "ce_type", "ce_source", "ce_time", "ce_datacontenttype", "ce_ext1", dataKey},
+		},
+		"compact gzip": {
+			args:                 RedisArgs{Encoding: EncodingCompact, Compression: CompressionGzip, CompressionThreshold: 1024},
+			data:                 largeData,
+			expectedKeys:         []string{"ce_specversion", "ce_id", "ce_type", "ce_source", "ce_time", "ce_datacontenttype", "ce_ext1", dataKey, dataEncodingKey},
+			expectedDataEncoding: CompressionGzip,
+		},
+		"compact zstd": {
+			args:                 RedisArgs{Encoding: EncodingCompact, Compression: CompressionZstd, CompressionThreshold: 1024},
+			data:                 largeData,
+			expectedKeys:         []string{"ce_specversion", "ce_id", "ce_type", "ce_source", "ce_time", "ce_datacontenttype", "ce_ext1", dataKey, dataEncodingKey},
+			expectedDataEncoding: CompressionZstd,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			event := cloudevents.NewEvent()
+			event.SetID("1")
+			event.SetType("test.type")
+			event.SetSource("test.source")
+			event.SetTime(time.Date(2023, 6, 1, 10, 0, 0, 0, time.UTC))
+			event.SetExtension("ext1", "value1")
+			if tc.data != nil {
+				require.NoError(t, event.SetData(cloudevents.ApplicationJSON, tc.data))
+			}
+
+			values, err := newEncoder(&tc.args).encode(&event)
+			require.NoError(t, err)
+
+			keys := make([]string, 0, len(values))
+			for k := range values {
+				keys = append(keys, k)
+			}
+			assert.ElementsMatch(t, tc.expectedKeys, keys)
+			if tc.expectedDataEncoding != "" {
+				assert.Equal(t, tc.expectedDataEncoding, values[dataEncodingKey])
+				assert.Less(t, len(values[dataKey].([]byte)), len(tc.data), "Data was not compressed")
+			}
+
+			// Values are read from Redis as strings.
+			msg := goredis.XMessage{ID: "1-0", Values: map[string]interface{}{}}
+			for k, v := range values {
+				msg.Values[k] = fmt.Sprintf("%s", v)
+			}
+
+			got, err := eventFromMessage(msg)
+			require.NoError(t, err)
+			assert.Equal(t, event.Context.String(), got.Context.String())
+			assert.Equal(t, event.Data(), got.Data())
+		})
+	}
+}
+
+func TestEventFromMessageNotValid(t *testing.T) {
+	_, err := eventFromMessage(goredis.XMessage{Values: map[string]interface{}{"foo": "bar"}})
+	assert.ErrorContains(t, err, "does not contain a CloudEvent")
+
+	_, err = eventFromMessage(goredis.XMessage{Values: map[string]interface{}{"ce_specversion": "9.9"}})
+	assert.ErrorContains(t, err, "unsupported CloudEvents spec version")
+}
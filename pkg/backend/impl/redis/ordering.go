@@ -0,0 +1,108 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"sync"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/types"
+)
+
+// PartitionKeyExtension is the CloudEvents partitioning extension
+// used to order events. When not present the subject is used.
+const PartitionKeyExtension = "partitionkey"
+
+// partitionKey returns the key that groups events that must be
+// delivered in order.
+func partitionKey(event *cloudevents.Event) string {
+	if v, ok := event.Extensions()[PartitionKeyExtension]; ok {
+		if key, err := types.ToString(v); err == nil && key != "" {
+			return key
+		}
+	}
+
+	return event.Subject()
+}
+
+// orderedDispatcher runs dispatches that share a key serially, in the
+// order they were informed, while dispatches for different keys run in
+// parallel. Dispatches without a key are not ordered.
+type orderedDispatcher struct {
+	// queues contains the pending dispatches for keys that
+	// are being processed, indexed by key.
+	queues map[string][]func()
+	m      sync.Mutex
+
+	// queued limits the number of dispatches waiting for
+	// previous dispatches of their key, nil when not limited.
+	queued chan struct{}
+}
+
+// newOrderedDispatcher returns a dispatcher that accepts up to limit
+// dispatches waiting in the queues, 0 for unlimited.
+func newOrderedDispatcher(limit int) *orderedDispatcher {
+	d := &orderedDispatcher{
+		queues: make(map[string][]func()),
+	}
+
+	if limit > 0 {
+		d.queued = make(chan struct{}, limit)
+	}
+
+	return d
+}
+
+// dispatch runs the function after all previous dispatches for
+// the key are done. It blocks while the queues are full, returning
+// an error if the context is done.
+func (d *orderedDispatcher) dispatch(ctx context.Context, key string, f func()) error {
+	if key == "" {
+		go f()
+		return nil
+	}
+
+	if d.queued != nil {
+		select {
+		case d.queued <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	d.m.Lock()
+	q, running := d.queues[key]
+	d.queues[key] = append(q, f)
+	d.m.Unlock()
+
+	if !running {
+		go d.run(key)
+	}
+
+	return nil
+}
+
+// run processes the queue for the key until it is empty.
+func (d *orderedDispatcher) run(key string) {
+	for {
+		d.m.Lock()
+		q := d.queues[key]
+		if len(q) == 0 {
+			delete(d.queues, key)
+			d.m.Unlock()
+			return
+		}
+		f := q[0]
+		q[0] = nil
+		d.queues[key] = q[1:]
+		d.m.Unlock()
+
+		if d.queued != nil {
+			<-d.queued
+		}
+
+		f()
+	}
+}
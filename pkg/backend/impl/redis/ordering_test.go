@@ -0,0 +1,118 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartitionKey(t *testing.T) {
+	testCases := map[string]struct {
+		extension interface{}
+		subject   string
+
+		expectedKey string
+	}{
+		"partition key extension": {
+			extension:   "order-1",
+			subject:     "customer-1",
+			expectedKey: "order-1",
+		},
+		"subject": {
+			subject:     "customer-1",
+			expectedKey: "customer-1",
+		},
+		"no key": {
+			expectedKey: "",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			event := cloudevents.NewEvent()
+			if tc.extension != nil {
+				event.SetExtension(PartitionKeyExtension, tc.extension)
+			}
+			if tc.subject != "" {
+				event.SetSubject(tc.subject)
+			}
+
+			assert.Equal(t, tc.expectedKey, partitionKey(&event))
+		})
+	}
+}
+
+func TestOrderedDispatcher(t *testing.T) {
+	d := newOrderedDispatcher(0)
+
+	var wg sync.WaitGroup
+	var m sync.Mutex
+	delivered := map[string][]int{}
+
+	// The first dispatch for key a blocks until key b has been
+	// dispatched, which is only possible if keys run in parallel.
+	bDone := make(chan struct{})
+
+	for i := 0; i < 10; i++ {
+		for _, key := range []string{"a", "b"} {
+			i, key := i, key
+			wg.Add(1)
+			err := d.dispatch(context.Background(), key, func() {
+				defer wg.Done()
+				if key == "a" && i == 0 {
+					select {
+					case <-bDone:
+					case <-time.After(5 * time.Second):
+						t.Error("Dispatches for different keys are not parallel")
+					}
+				}
+
+				m.Lock()
+				delivered[key] = append(delivered[key], i)
+				if key == "b" && len(delivered[key]) == 10 {
+					close(bDone)
+				}
+				m.Unlock()
+			})
+			require.NoError(t, err)
+		}
+	}
+
+	wg.Wait()
+
+	expected := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	assert.Equal(t, expected, delivered["a"], "Dispatches for the same key are not ordered")
+	assert.Equal(t, expected, delivered["b"], "Dispatches for the same key are not ordered")
+	assert.Eventually(t, func() bool {
+		d.m.Lock()
+		defer d.m.Unlock()
+		return len(d.queues) == 0
+	}, time.Second, 10*time.Millisecond, "Queues are not removed after processing")
+}
+
+func TestOrderedDispatcherLimit(t *testing.T) {
+	d := newOrderedDispatcher(1)
+
+	block := make(chan struct{})
+	defer close(block)
+
+	// The first dispatch runs and is not counted as queued.
+	require.NoError(t, d.dispatch(context.Background(), "a", func() { <-block }))
+	assert.Eventually(t, func() bool {
+		return len(d.queued) == 0
+	}, time.Second, 10*time.Millisecond, "Running dispatch is counted as queued")
+
+	require.NoError(t, d.dispatch(context.Background(), "a", func() {}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Error(t, d.dispatch(ctx, "b", func() {}), "Dispatch was queued over the limit")
+}
@@ -0,0 +1,76 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"hash/fnv"
+	"strconv"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// streams returns the names of the streams that store the broker's
+// events. When partitioned each stream is suffixed with its ordinal.
+func (s *redis) streams() []string {
+	if s.args.Partitions <= 1 {
+		return []string{s.args.Stream}
+	}
+
+	streams := make([]string, s.args.Partitions)
+	for i := range streams {
+		streams[i] = partitionStream(s.args.Stream, i)
+	}
+	return streams
+}
+
+// streamFor returns the stream where the event is stored. Events that share
+// the partition key are stored at the same stream, events without a key are
+// spread using their ID.
+func (s *redis) streamFor(event *cloudevents.Event) string {
+	if s.args.Partitions <= 1 {
+		return s.args.Stream
+	}
+
+	key := partitionKey(event)
+	if key == "" {
+		key = event.ID()
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return partitionStream(s.args.Stream, int(h.Sum32()%uint32(s.args.Partitions)))
+}
+
+func partitionStream(stream string, partition int) string {
+	return stream + "." + strconv.Itoa(partition)
+}
+
+// orphanedStreams returns the streams written using a different partitions
+// setting that still contain events, which are not read by subscriptions.
+//
+// Only the streams that reveal a change are checked: the non partitioned
+// stream when partitioned, the first partition when not partitioned, and
+// the partition that follows the last one when the count was reduced.
+func (s *redis) orphanedStreams(ctx context.Context) ([]string, error) {
+	var candidates []string
+	if s.args.Partitions <= 1 {
+		candidates = []string{partitionStream(s.args.Stream, 0)}
+	} else {
+		candidates = []string{s.args.Stream, partitionStream(s.args.Stream, s.args.Partitions)}
+	}
+
+	orphaned := []string{}
+	for _, stream := range candidates {
+		n, err := s.client.XLen(ctx, stream).Result()
+		if err != nil {
+			return nil, err
+		}
+		if n != 0 {
+			orphaned = append(orphaned, stream)
+		}
+	}
+
+	return orphaned, nil
+}
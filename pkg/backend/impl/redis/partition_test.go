@@ -0,0 +1,105 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamFor(t *testing.T) {
+	s := &redis{args: &RedisArgs{Stream: "triggermesh", Partitions: 4}}
+
+	assert.Equal(t, []string{"triggermesh.0", "triggermesh.1", "triggermesh.2", "triggermesh.3"}, s.streams())
+
+	used := map[string]struct{}{}
+	for i := 0; i < 100; i++ {
+		event := cloudevents.NewEvent()
+		event.SetID(strconv.Itoa(i))
+		stream := s.streamFor(&event)
+		assert.Contains(t, s.streams(), stream)
+		used[stream] = struct{}{}
+
+		// Events sharing the partition key use the same stream.
+		event.SetSubject("customer-1")
+		first := s.streamFor(&event)
+		event.SetID("other")
+		assert.Equal(t, first, s.streamFor(&event), "Events with the same key use different streams")
+	}
+	assert.Len(t, used, 4, "Events without a key are not spread across streams")
+
+	s.args.Partitions = 1
+	event := cloudevents.NewEvent()
+	assert.Equal(t, "triggermesh", s.streamFor(&event))
+	assert.Equal(t, []string{"triggermesh"}, s.streams())
+}
+
+func TestOrphanedStreams(t *testing.T) {
+	testCases := map[string]struct {
+		partitions int
+		existing   []string
+
+		expectedOrphaned []string
+	}{
+		"not partitioned": {
+			partitions:       1,
+			existing:         []string{"triggermesh"},
+			expectedOrphaned: []string{},
+		},
+		"partitioned": {
+			partitions:       2,
+			existing:         []string{"triggermesh.0", "triggermesh.1"},
+			expectedOrphaned: []string{},
+		},
+		"partitions added": {
+			partitions:       2,
+			existing:         []string{"triggermesh"},
+			expectedOrphaned: []string{"triggermesh"},
+		},
+		"partitions removed": {
+			partitions:       1,
+			existing:         []string{"triggermesh.0", "triggermesh.1"},
+			expectedOrphaned: []string{"triggermesh.0"},
+		},
+		"partitions reduced": {
+			partitions:       2,
+			existing:         []string{"triggermesh.0", "triggermesh.1", "triggermesh.2"},
+			expectedOrphaned: []string{"triggermesh.2"},
+		},
+		"partitions increased": {
+			partitions:       3,
+			existing:         []string{"triggermesh.0", "triggermesh.1"},
+			expectedOrphaned: []string{},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			client := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+			defer client.Close()
+
+			for _, stream := range tc.existing {
+				_, err := mr.XAdd(stream, "*", []string{ceKey, "{}"})
+				require.NoError(t, err)
+			}
+
+			s := &redis{
+				args:   &RedisArgs{Stream: "triggermesh", Partitions: tc.partitions},
+				client: client,
+			}
+
+			orphaned, err := s.orphanedStreams(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedOrphaned, orphaned)
+		})
+	}
+}
@@ -40,15 +40,20 @@ const (
 func New(args *RedisArgs, logger *zap.SugaredLogger) backend.Interface {
 	return &redis{
 		args:          args,
+		encoder:       newEncoder(args),
 		logger:        logger,
 		disconnecting: false,
 		subs:          make(map[string]subscription),
+		cleanups:      make(map[string]*time.Timer),
 	}
 }
 
 type redis struct {
 	args *RedisArgs
 
+	// encoder serializes events into stream messages.
+	encoder *encoder
+
 	client goredis.Cmdable
 	// Redis' Cmdable does not include the conneciton operation
 	// functions, we keep track of closing via this field.
@@ -60,6 +65,10 @@ type redis struct {
 	// before disconnecting.
 	wgSubs sync.WaitGroup
 
+	// cleanups contains the timers for consumer groups
+	// scheduled to be destroyed, indexed by subscription name.
+	cleanups map[string]*time.Timer
+
 	// disconnecting is set to avoid setting up new subscriptions
 	// when the broker is shutting down.
 	disconnecting bool
@@ -125,7 +134,22 @@ func (s *redis) Init(ctx context.Context) error {
 		s.client = client
 	}
 
-	return s.Probe(ctx)
+	if err := s.Probe(ctx); err != nil {
+		return err
+	}
+
+	// Changing the number of partitions leaves events at streams
+	// that are not read anymore.
+	orphaned, err := s.orphanedStreams(ctx)
+	switch {
+	case err != nil:
+		s.logger.Warnw("Could not check streams from previous partition settings", zap.Error(err))
+	case len(orphaned) != 0:
+		s.logger.Warnw("Streams from previous partition settings contain events that will not be delivered",
+			zap.Strings("streams", orphaned), zap.Int("partitions", s.args.Partitions))
+	}
+
+	return nil
 }
 
 func (s *redis) Start(ctx context.Context) error {
@@ -142,6 +166,13 @@ func (s *redis) Start(ctx context.Context) error {
 		s.unsubscribe(name)
 	}
 
+	// Pending consumer group cleanups are discarded, orphaned
+	// groups are found again at startup.
+	for name, t := range s.cleanups {
+		t.Stop()
+		delete(s.cleanups, name)
+	}
+
 	// wait for all subscriptions to finish
 	// before returning.
 	allSubsFinished := make(chan struct{})
@@ -162,19 +193,9 @@ func (s *redis) Start(ctx context.Context) error {
 }
 
 func (s *redis) Produce(ctx context.Context, event *cloudevents.Event) error {
-	b, err := event.MarshalJSON()
+	args, err := s.xaddArgs(event)
 	if err != nil {
-		return fmt.Errorf("could not serialize CloudEvent: %w", err)
-	}
-
-	args := &goredis.XAddArgs{
-		Stream: s.args.Stream,
-		Values: map[string]interface{}{ceKey: b},
-	}
-
-	if s.args.StreamMaxLen != 0 {
-		args.MaxLen = int64(s.args.StreamMaxLen)
-		args.Approx = true
+		return err
 	}
 
 	res := s.client.XAdd(ctx, args)
@@ -192,9 +213,71 @@ func (s *redis) Produce(ctx context.Context, event *cloudevents.Event) error {
 	return nil
 }
 
+// ProduceBatch uses a pipeline to send all events to Redis
+// in a single round trip.
+func (s *redis) ProduceBatch(ctx context.Context, events []*cloudevents.Event) []error {
+	errs := make([]error, len(events))
+	cmds := make([]*goredis.StringCmd, len(events))
+
+	pipe := s.client.Pipeline()
+	for i, event := range events {
+		args, err := s.xaddArgs(event)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		cmds[i] = pipe.XAdd(ctx, args)
+	}
+
+	if pipe.Len() == 0 {
+		return errs
+	}
+
+	// Errors are checked per command below.
+	_, _ = pipe.Exec(ctx)
+
+	for i, cmd := range cmds {
+		if cmd == nil {
+			continue
+		}
+
+		id, err := cmd.Result()
+		if err != nil {
+			errs[i] = fmt.Errorf("could not produce CloudEvent to backend: %w", err)
+			continue
+		}
+
+		s.logger.Debug(fmt.Sprintf("CloudEvent %s/%s produced to the backend as %s",
+			events[i].Context.GetSource(),
+			events[i].Context.GetID(),
+			id))
+	}
+
+	return errs
+}
+
+func (s *redis) xaddArgs(event *cloudevents.Event) (*goredis.XAddArgs, error) {
+	values, err := s.encoder.encode(event)
+	if err != nil {
+		return nil, err
+	}
+
+	args := &goredis.XAddArgs{
+		Stream: s.streamFor(event),
+		Values: values,
+	}
+
+	if s.args.StreamMaxLen != 0 {
+		args.MaxLen = int64(s.args.StreamMaxLen)
+		args.Approx = true
+	}
+
+	return args, nil
+}
+
 // SubscribeBounded is a variant of the Subscribe function that supports bounded subscriptions.
 // It adds the option of using a startId and endId for the replay feature.
-func (s *redis) Subscribe(name string, bounds *broker.TriggerBounds, ccb backend.ConsumerDispatcher, scb backend.SubscriptionStatusChange) error {
+func (s *redis) Subscribe(name string, bounds *broker.TriggerBounds, ccb backend.ConsumerDispatcher, scb backend.SubscriptionStatusChange, opts ...backend.SubscribeOption) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -217,16 +300,34 @@ func (s *redis) Subscribe(name string, bounds *broker.TriggerBounds, ccb backend
 		exceedBoundCheck = newExceedBounds(endID)
 	}
 
-	// Create the consumer group for this subscription.
-	group := s.args.Group + "." + name
-	res := s.client.XGroupCreateMkStream(s.ctx, s.args.Stream, group, startID)
-	_, err = res.Result()
-	if err != nil {
-		// Ignore errors when the group already exists.
-		if !strings.HasPrefix(err.Error(), "BUSYGROUP") {
-			return err
+	// A trigger that is configured again keeps its consumer group.
+	s.cancelGroupCleanup(name)
+
+	// Create the consumer group for this subscription, using the
+	// same name at all streams.
+	group := s.groupName(name)
+	streams := s.streams()
+	so := backend.NewSubscribeOptions(opts...)
+	for _, stream := range streams {
+		res := s.client.XGroupCreateMkStream(s.ctx, stream, group, startID)
+		_, err = res.Result()
+		if err != nil {
+			// Ignore errors when the group already exists.
+			if !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+				return err
+			}
+			s.logger.Debug("Consumer group already exists", zap.String("group", group), zap.String("stream", stream))
+
+			// Existing groups are moved to the start bound when requested, the
+			// default start is not applied to avoid skipping unread messages.
+			if so.ResetPosition && startID != defaultGroupStartID {
+				if err := s.client.XGroupSetID(s.ctx, stream, group, startID).Err(); err != nil {
+					return fmt.Errorf("could not reset consumer group position: %w", err)
+				}
+				s.logger.Infow("Consumer group position reset", zap.String("group", group),
+					zap.String("stream", stream), zap.String("id", startID))
+			}
 		}
-		s.logger.Debug("Consumer group already exists", zap.String("group", group))
 	}
 
 	// We don't use the parent context but create a new one so that we can control
@@ -236,12 +337,14 @@ func (s *redis) Subscribe(name string, bounds *broker.TriggerBounds, ccb backend
 
 	subs := subscription{
 		instance:            s.args.Instance,
-		stream:              s.args.Stream,
+		streams:             streams,
 		name:                name,
 		group:               group,
 		checkBoundsExceeded: exceedBoundCheck,
+		boundsPending:       int32(len(streams)),
 
 		trackingEnabled: s.args.TrackingIDEnabled,
+		readCount:       int64(s.args.ReadCount),
 
 		// caller's callback for dispatching events from Redis.
 		ccbDispatch: ccb,
@@ -253,12 +356,24 @@ func (s *redis) Subscribe(name string, bounds *broker.TriggerBounds, ccb backend
 		ctx:    ctx,
 		cancel: cancel,
 		// stoppedCh signals when a subscription has completely finished.
-		stoppedCh: make(chan struct{}),
+		stoppedCh:  make(chan struct{}),
+		dispatches: &sync.WaitGroup{},
+
+		// settings informed by the caller.
+		options: so,
 
 		client: s.client,
 		logger: s.logger,
 	}
 
+	if s.args.OrderedDelivery {
+		subs.ordering = newOrderedDispatcher(s.args.MaxInFlight)
+	}
+
+	if s.args.MaxInFlight > 0 {
+		subs.inflight = make(chan struct{}, s.args.MaxInFlight)
+	}
+
 	s.subs[name] = subs
 	s.wgSubs.Add(1)
 	subs.start()
@@ -8,9 +8,10 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	cloudevents "github.com/cloudevents/sdk-go/v2"
 	goredis "github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 
@@ -20,6 +21,11 @@ import (
 
 const (
 	BackendIDAttribute = "triggermeshbackendid"
+
+	// ackTimeout bounds acknowledging messages, which is not tied to the
+	// subscription context so that dispatched messages are acknowledged
+	// even when the subscription is being stopped.
+	ackTimeout = 10 * time.Second
 )
 
 type exceedBounds func(id string) bool
@@ -36,19 +42,39 @@ func newExceedBounds(offset string) exceedBounds {
 
 type subscription struct {
 	instance            string
-	stream              string
+	streams             []string
 	name                string
 	group               string
 	checkBoundsExceeded exceedBounds
 
+	// boundsPending is the number of streams that
+	// have not reached the bounds yet.
+	boundsPending int32
+
 	trackingEnabled bool
 
+	// ordering delivers events that share a partition key in
+	// order, nil when events are dispatched in parallel.
+	ordering *orderedDispatcher
+
 	// caller's callback for dispatching events from Redis.
 	ccbDispatch backend.ConsumerDispatcher
 
 	// caller's callback for subscription status changes
 	scb backend.SubscriptionStatusChange
 
+	// options informed by the caller when subscribing.
+	options *backend.SubscribeOptions
+
+	// readCount is the maximum number of messages read at once.
+	readCount int64
+	// inflight limits the number of messages being dispatched,
+	// nil when not limited.
+	inflight chan struct{}
+	// dispatches keeps track of the messages handed over for
+	// dispatching, including those queued by key.
+	dispatches *sync.WaitGroup
+
 	// cancel function let us control when the subscription loop should exit.
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -60,10 +86,38 @@ type subscription struct {
 }
 
 func (s *subscription) start() {
+	// Each stream is read by its own loop, the subscription is
+	// finished when all of them exit.
+	var wg sync.WaitGroup
+	for _, stream := range s.streams {
+		wg.Add(1)
+		go func(stream string) {
+			defer wg.Done()
+			s.read(stream)
+		}(stream)
+	}
+
+	go func() {
+		wg.Wait()
+
+		// Wait for in flight dispatches to be acknowledged, and for
+		// queued dispatches to be discarded, so that a new subscription
+		// does not read them again from the pending messages.
+		s.dispatches.Wait()
+
+		// Close stoppedCh to signal external viewers that processing for this
+		// subscription is no longer running.
+		close(s.stoppedCh)
+	}()
+}
+
+// read dispatches the events from a stream until the subscription
+// is done or its bounds are exceeded.
+func (s *subscription) read(stream string) {
 	s.logger.Infow("Starting Redis subscription",
 		zap.String("group", s.group),
 		zap.String("instance", s.instance),
-		zap.String("stream", s.stream))
+		zap.String("stream", stream))
 	// Start reading all pending messages
 	id := "0"
 
@@ -76,130 +130,204 @@ func (s *subscription) start() {
 		s.logger.Debugw("Waiting for last XReadGroup operation to finish before exiting subscription",
 			zap.String("group", s.group),
 			zap.String("instance", s.instance),
-			zap.String("stream", s.stream))
+			zap.String("stream", stream))
 		exitLoop = true
 	}()
 
-	go func() {
-		for {
-			// Check at the begining of each iteration if the exit loop flag has
-			// been signaled due to done context or because the endDate has been reached.
-			if exitLoop {
-				break
-			}
+	for {
+		// Check at the begining of each iteration if the exit loop flag has
+		// been signaled due to done context or because the endDate has been reached.
+		if exitLoop {
+			break
+		}
 
-			// Although this call is blocking it will yield when the context is done,
-			// the exit loop flag above will be triggered almost immediately if no
-			// data has been read.
-			streams, err := s.client.XReadGroup(s.ctx, &goredis.XReadGroupArgs{
-				Group:    s.group,
-				Consumer: s.instance,
-				Streams:  []string{s.stream, id},
-				Count:    1,
-				// Setting block low since cancelling the context
-				// does not force the read to finish, making the process slow
-				// to exit.
-				Block: 3 * time.Second,
-				NoAck: false,
-			}).Result()
+		// The caller might pause consumption for the subscription,
+		// waiting returns an error when the context is done.
+		if err := s.options.WaitGate(s.ctx); err != nil {
+			break
+		}
 
-			if err != nil {
-				// Ignore errors when the blocking period ends without
-				// receiving any event, and errors when the context is
-				// canceled
-				if !errors.Is(err, goredis.Nil) &&
-					!strings.HasSuffix(err.Error(), "i/o timeout") &&
-					err.Error() != "context canceled" {
-					s.logger.Errorw("Error reading CloudEvents from consumer group", zap.String("group", s.group), zap.Error(err))
-				}
-				continue
+		// Although this call is blocking it will yield when the context is done,
+		// the exit loop flag above will be triggered almost immediately if no
+		// data has been read.
+		streams, err := s.client.XReadGroup(s.ctx, &goredis.XReadGroupArgs{
+			Group:    s.group,
+			Consumer: s.instance,
+			Streams:  []string{stream, id},
+			Count:    s.readCount,
+			// Setting block low since cancelling the context
+			// does not force the read to finish, making the process slow
+			// to exit.
+			Block: 3 * time.Second,
+			NoAck: false,
+		}).Result()
+
+		if err != nil {
+			// Ignore errors when the blocking period ends without
+			// receiving any event, and errors when the context is
+			// canceled
+			if !errors.Is(err, goredis.Nil) &&
+				!strings.HasSuffix(err.Error(), "i/o timeout") &&
+				err.Error() != "context canceled" {
+				s.logger.Errorw("Error reading CloudEvents from consumer group", zap.String("group", s.group), zap.Error(err))
 			}
+			continue
+		}
 
-			if len(streams) != 1 {
-				s.logger.Errorw("unexpected number of streams read", zap.Any("streams", streams))
-				continue
-			}
+		if len(streams) != 1 {
+			s.logger.Errorw("unexpected number of streams read", zap.Any("streams", streams))
+			continue
+		}
 
-			// If we are processing pending messages from Redis and we reach
-			// EOF, switch to reading new messages.
-			if len(streams[0].Messages) == 0 && id != ">" {
+		// If we are processing pending messages from Redis and we reach
+		// EOF, switch to reading new messages.
+		if len(streams[0].Messages) == 0 {
+			if id != ">" {
 				id = ">"
 			}
+		}
 
-			for _, msg := range streams[0].Messages {
-				ce := &cloudevents.Event{}
-				for k, v := range msg.Values {
-					if k != ceKey {
-						s.logger.Debug(fmt.Sprintf("Ignoring non expected key at message from backend: %s", k))
-						continue
-					}
+		for _, msg := range streams[0].Messages {
+			ce, err := eventFromMessage(msg)
+			if err == nil {
+				err = ce.Validate()
+			}
 
-					if err = ce.UnmarshalJSON([]byte(v.(string))); err != nil {
-						s.logger.Errorw("Could not unmarshal CloudEvent from Redis", zap.Error(err))
-						continue
-					}
+			// If there was no valid CE in the message ACK so that we do not receive it again.
+			if err != nil {
+				s.logger.Warnw(fmt.Sprintf("Removing non CloudEvent message from backend: %s", msg.ID), zap.Error(err))
+				if err = s.ack(stream, msg.ID); err != nil {
+					s.logger.Errorw(fmt.Sprintf("could not ACK the Redis message %s containing a non valid CloudEvent", id),
+						zap.Error(err))
 				}
 
-				// If there was no valid CE in the message ACK so that we do not receive it again.
-				if err = ce.Validate(); err != nil {
-					s.logger.Warn(fmt.Sprintf("Removing non CloudEvent message from backend: %s", msg.ID))
-					if err = s.ack(msg.ID); err != nil {
-						s.logger.Errorw(fmt.Sprintf("could not ACK the Redis message %s containing a non valid CloudEvent", id),
-							zap.Error(err))
-					}
-
-					continue
-				}
+				continue
+			}
 
-				// If an end date has been specified, compare the current message ID
-				// with the end date. If the message ID is newer than the end date,
-				// exit the loop.
-				if s.checkBoundsExceeded != nil {
-					if exitLoop = s.checkBoundsExceeded(msg.ID); exitLoop {
+			// If an end date has been specified, compare the current message ID
+			// with the end date. If the message ID is newer than the end date,
+			// exit the loop.
+			if s.checkBoundsExceeded != nil {
+				if exitLoop = s.checkBoundsExceeded(msg.ID); exitLoop {
+					// The subscription is complete when all streams
+					// reach the bounds.
+					if atomic.AddInt32(&s.boundsPending, -1) == 0 {
 						s.scb(&status.SubscriptionStatus{
 							Status: status.SubscriptionStatusComplete,
 						})
-						break
 					}
+					break
 				}
+			}
 
-				if s.trackingEnabled {
-					if err = ce.Context.SetExtension(BackendIDAttribute, msg.ID); err != nil {
-						s.logger.Errorw(fmt.Sprintf("could not set %s attributes for the Redis message %s. Tracking will not be possible.", BackendIDAttribute, msg.ID),
-							zap.Error(err))
-					}
+			if s.trackingEnabled {
+				if err = ce.Context.SetExtension(BackendIDAttribute, msg.ID); err != nil {
+					s.logger.Errorw(fmt.Sprintf("could not set %s attributes for the Redis message %s. Tracking will not be possible.", BackendIDAttribute, msg.ID),
+						zap.Error(err))
 				}
+			}
 
-				go func(msgID string) {
-					s.ccbDispatch(ce)
-					if err := s.ack(msgID); err != nil {
-						s.logger.Errorw(fmt.Sprintf("could not ACK the Redis message %s containing CloudEvent %s", msgID, ce.Context.GetID()),
-							zap.Error(err))
-					}
-				}(msg.ID)
+			// Messages are acknowledged only after being dispatched, when
+			// ordering is enabled messages that are pending when the
+			// subscription restarts are read again in order.
+			dispatch := func(msgID string, release func()) {
+				defer s.dispatches.Done()
+				s.ccbDispatch(ce)
+				release()
+				if err := s.ack(stream, msgID); err != nil {
+					s.logger.Errorw(fmt.Sprintf("could not ACK the Redis message %s containing CloudEvent %s", msgID, ce.Context.GetID()),
+						zap.Error(err))
+				}
+			}
 
-				// If we are processing pending messages the ACK might take a
-				// while to be sent. We need to set the message ID so that the
-				// next requested element is not any of the pending being processed.
-				if id != ">" {
-					id = msg.ID
+			if s.ordering != nil {
+				// Ordered messages are queued by key first, and take the in
+				// flight slot once all previous messages for the key are done,
+				// so that a slow key does not hold the slots for other keys.
+				// Messages that are not dispatched because the subscription is
+				// done are kept pending at Redis, along with the rest of the
+				// messages queued for the key.
+				msgID := msg.ID
+				s.dispatches.Add(1)
+				if err := s.ordering.dispatch(s.ctx, partitionKey(ce), func() {
+					release, err := s.acquire()
+					if err != nil {
+						s.dispatches.Done()
+						return
+					}
+					dispatch(msgID, release)
+				}); err != nil {
+					s.dispatches.Done()
+					exitLoop = true
+					break
+				}
+			} else {
+				// Wait for a free in flight slot and for the caller, that might
+				// throttle consumption for the subscription, to accept the dispatch.
+				// The release function is called once the message is dispatched.
+				// Messages that are not dispatched are kept pending at Redis.
+				release, err := s.acquire()
+				if err != nil {
+					exitLoop = true
+					break
 				}
+				s.dispatches.Add(1)
+				go dispatch(msg.ID, release)
+			}
+
+			// If we are processing pending messages the ACK might take a
+			// while to be sent. We need to set the message ID so that the
+			// next requested element is not any of the pending being processed.
+			if id != ">" {
+				id = msg.ID
 			}
 		}
+	}
 
-		s.logger.Debugw("Exited Redis subscription",
-			zap.String("group", s.group),
-			zap.String("instance", s.instance),
-			zap.String("stream", s.stream))
+	s.logger.Debugw("Exited Redis subscription",
+		zap.String("group", s.group),
+		zap.String("instance", s.instance),
+		zap.String("stream", stream))
+}
 
-		// Close stoppedCh to signal external viewers that processing for this
-		// subscription is no longer running.
-		close(s.stoppedCh)
-	}()
+// acquire blocks until a message can be dispatched, returning the function
+// that must be called once the dispatch is done.
+func (s *subscription) acquire() (func(), error) {
+	// Do not dispatch more messages once the subscription is done,
+	// even if there are free slots.
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if s.inflight != nil {
+		select {
+		case s.inflight <- struct{}{}:
+		case <-s.ctx.Done():
+			return nil, s.ctx.Err()
+		}
+	}
+
+	release, err := s.options.Acquire(s.ctx)
+	if err != nil {
+		if s.inflight != nil {
+			<-s.inflight
+		}
+		return nil, err
+	}
+
+	return func() {
+		release()
+		if s.inflight != nil {
+			<-s.inflight
+		}
+	}, nil
 }
 
-func (s *subscription) ack(id string) error {
-	res := s.client.XAck(s.ctx, s.stream, s.group, id)
+func (s *subscription) ack(stream, id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ackTimeout)
+	defer cancel()
+
+	res := s.client.XAck(ctx, stream, s.group, id)
 	_, err := res.Result()
 	return err
 }
@@ -4,9 +4,22 @@
 package redis
 
 import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	goredis "github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+	"github.com/triggermesh/brokers/pkg/status"
 )
 
 func TestCompareStreamIDs(t *testing.T) {
@@ -51,3 +64,193 @@ func TestCompareStreamIDs(t *testing.T) {
 		})
 	}
 }
+
+func TestSubscriptionInFlight(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := &subscription{
+		options:  backend.NewSubscribeOptions(),
+		inflight: make(chan struct{}, 2),
+		ctx:      ctx,
+	}
+
+	r1, err := s.acquire()
+	require.NoError(t, err)
+	_, err = s.acquire()
+	require.NoError(t, err)
+
+	acquired := make(chan struct{})
+	go func() {
+		defer close(acquired)
+		_, err := s.acquire()
+		assert.NoError(t, err)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("Acquired a slot over the in flight limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	r1()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("Released slot was not acquired")
+	}
+
+	cancel()
+	_, err = s.acquire()
+	assert.Error(t, err, "Acquiring does not finish when the subscription is done")
+}
+
+func newSubscriptionBackend(t *testing.T, args *RedisArgs) (*redis, *goredis.Client) {
+	mr := miniredis.RunT(t)
+	client := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	args.Stream = "triggermesh"
+	args.Group = "default"
+	args.Instance = "instance1"
+	args.ReadCount = 10
+
+	s := New(args, zaptest.NewLogger(t).Sugar()).(*redis)
+	s.client = client
+	s.ctx = context.Background()
+
+	return s, client
+}
+
+func produceWithSubject(t *testing.T, s *redis, id, subject string) {
+	e := cloudevents.NewEvent()
+	e.SetID(id)
+	e.SetType("test.type")
+	e.SetSource("test.source")
+	e.SetSubject(subject)
+	require.NoError(t, s.Produce(context.Background(), &e))
+}
+
+func TestSubscriptionOrderedInFlight(t *testing.T) {
+	s, _ := newSubscriptionBackend(t, &RedisArgs{OrderedDelivery: true, MaxInFlight: 2})
+
+	bDelivered := make(chan struct{})
+	ccb := func(e *cloudevents.Event) {
+		switch e.ID() {
+		case "a1":
+			// Blocks until a different key is delivered, which is only possible
+			// if the queued message for the same key does not hold a slot.
+			select {
+			case <-bDelivered:
+			case <-time.After(5 * time.Second):
+				t.Error("Slow key is holding the in flight slots")
+			}
+		case "b1":
+			close(bDelivered)
+		}
+	}
+
+	require.NoError(t, s.Subscribe("trigger1", nil, ccb, func(*status.SubscriptionStatus) {}))
+	defer s.Unsubscribe("trigger1")
+
+	produceWithSubject(t, s, "a1", "a")
+	produceWithSubject(t, s, "a2", "a")
+	produceWithSubject(t, s, "b1", "b")
+
+	select {
+	case <-bDelivered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Event for a different key was not delivered")
+	}
+}
+
+func TestSubscriptionAckAfterUnsubscribe(t *testing.T) {
+	s, client := newSubscriptionBackend(t, &RedisArgs{OrderedDelivery: true})
+
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	ccb := func(e *cloudevents.Event) {
+		close(started)
+		<-finish
+	}
+
+	require.NoError(t, s.Subscribe("trigger1", nil, ccb, func(*status.SubscriptionStatus) {}))
+	produceWithSubject(t, s, "a1", "a")
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Event was not dispatched")
+	}
+
+	// The dispatch finishes while the subscription is being stopped.
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(finish)
+	}()
+	s.Unsubscribe("trigger1")
+
+	assert.Eventually(t, func() bool {
+		p, err := client.XPending(context.Background(), "triggermesh", s.groupName("trigger1")).Result()
+		return err == nil && p.Count == 0
+	}, 5*time.Second, 10*time.Millisecond, "Dispatched message was not acknowledged")
+}
+
+func TestSubscriptionUnsubscribeWaitsDispatch(t *testing.T) {
+	for _, ordered := range []bool{false, true} {
+		t.Run(fmt.Sprintf("ordered %t", ordered), func(t *testing.T) {
+			s, _ := newSubscriptionBackend(t, &RedisArgs{OrderedDelivery: ordered, MaxInFlight: 10})
+
+			var m sync.Mutex
+			delivered := map[string]int{}
+			started := make(chan struct{}, 1)
+			stopping := make(chan struct{})
+			var finished int32
+
+			ccb := func(e *cloudevents.Event) {
+				m.Lock()
+				delivered[e.ID()]++
+				m.Unlock()
+
+				if e.ID() == "a1" {
+					started <- struct{}{}
+					// Outlast the stream read that is blocked
+					// when the subscription is stopped.
+					<-stopping
+					time.Sleep(3500 * time.Millisecond)
+					atomic.StoreInt32(&finished, 1)
+				}
+			}
+			scb := func(*status.SubscriptionStatus) {}
+
+			require.NoError(t, s.Subscribe("trigger1", nil, ccb, scb))
+			produceWithSubject(t, s, "a1", "a")
+
+			select {
+			case <-started:
+			case <-time.After(5 * time.Second):
+				t.Fatal("Event was not dispatched")
+			}
+
+			close(stopping)
+			s.Unsubscribe("trigger1")
+			assert.Equal(t, int32(1), atomic.LoadInt32(&finished), "Unsubscribed before the dispatch finished")
+
+			// Subscribing again reads pending messages, the
+			// dispatched message must not be delivered again.
+			require.NoError(t, s.Subscribe("trigger1", nil, ccb, scb))
+			defer s.Unsubscribe("trigger1")
+			produceWithSubject(t, s, "b1", "b")
+
+			assert.Eventually(t, func() bool {
+				m.Lock()
+				defer m.Unlock()
+				return delivered["b1"] == 1
+			}, 5*time.Second, 10*time.Millisecond, "Event was not delivered after subscribing again")
+
+			m.Lock()
+			defer m.Unlock()
+			assert.Equal(t, 1, delivered["a1"], "Dispatched event was delivered again")
+		})
+	}
+}
@@ -5,6 +5,7 @@ package backend
 
 import (
 	"context"
+	"time"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
 	"github.com/triggermesh/brokers/pkg/config/broker"
@@ -14,6 +15,12 @@ import (
 type Info struct {
 	// Name of the backend implementation
 	Name string
+
+	// SharedDispatch is set for backends that dispatch events for all
+	// subscriptions from a single reader, not applying the consumer gate.
+	// Dispatches must not block while consumption is paused, since that
+	// would delay the rest of subscriptions.
+	SharedDispatch bool
 }
 
 // ConsumerDispatcher receives CloudEvents to be delivered to subscribers.
@@ -28,16 +35,154 @@ type SubscriptionStatusChange func(*status.SubscriptionStatus)
 type EventProducer interface {
 	// Ingest a new CloudEvents at the backend.
 	Produce(context.Context, *cloudevents.Event) error
+
+	// ProduceBatch ingests a set of CloudEvents at the backend. The returned
+	// slice contains an element for each incoming event at the same position,
+	// set to nil when the event was produced or to the produce error.
+	ProduceBatch(context.Context, []*cloudevents.Event) []error
+}
+
+// Deduplicator is an optional interface for backends that can keep track
+// of ingested events to avoid producing duplicates.
+type Deduplicator interface {
+	// Seen registers the key for the duration of the window, returning
+	// true if the key was already registered.
+	Seen(ctx context.Context, key string, window time.Duration) (bool, error)
+
+	// Forget removes a registered key. It is used when an event that was
+	// registered could not be produced.
+	Forget(ctx context.Context, key string) error
+}
+
+// LoadReporter is an optional interface for backends that can inform
+// when they are close to their capacity and should not receive events.
+type LoadReporter interface {
+	// Overloaded returns true when the backend is above its high-water mark.
+	Overloaded() bool
+}
+
+// DeadLetter is an event stored at the backend's dead letter storage.
+type DeadLetter struct {
+	// ID of the entry at the dead letter storage.
+	ID string
+	// Event that could not be delivered, including failure details.
+	Event *cloudevents.Event
 }
 
-type SubscribeOption func(Subscribable)
+// DeadLetterStore is an optional interface for backends that can keep
+// events that could not be delivered, using a separate storage for each
+// subscription.
+type DeadLetterStore interface {
+	// ProduceDeadLetter stores the event for the subscription.
+	ProduceDeadLetter(ctx context.Context, subscription string, event *cloudevents.Event) error
+
+	// ListDeadLetters returns up to count stored events for the
+	// subscription, oldest first.
+	ListDeadLetters(ctx context.Context, subscription string, count int) ([]DeadLetter, error)
+
+	// GetDeadLetter returns a stored event for the subscription.
+	GetDeadLetter(ctx context.Context, subscription, id string) (*DeadLetter, error)
+
+	// DeleteDeadLetter removes a stored event for the subscription.
+	DeleteDeadLetter(ctx context.Context, subscription, id string) error
+}
+
+// ConsumerGate is called by backends before reading events for a
+// subscription. It blocks while consumption is paused, returning an
+// error if the context is done before it resumes.
+type ConsumerGate func(ctx context.Context) error
+
+// ConsumerLimiter is called by backends before reading each event for a
+// subscription, blocking until the subscriber can accept it. The returned
+// function must be called when the event dispatch finishes.
+type ConsumerLimiter func(ctx context.Context) (release func(), err error)
+
+// SubscribeOptions are optional settings for a subscription.
+type SubscribeOptions struct {
+	// Gate, when set, must be called before reading events from
+	// the backend for the subscription.
+	Gate ConsumerGate
+
+	// Limiter, when set, must be called before reading each event
+	// from the backend for the subscription.
+	Limiter ConsumerLimiter
+
+	// ResetPosition moves an existing consumer position for the
+	// subscription to the start bound, if informed.
+	ResetPosition bool
+}
+
+type SubscribeOption func(*SubscribeOptions)
+
+// WithConsumerGate sets the function that pauses reading
+// events for the subscription.
+func WithConsumerGate(g ConsumerGate) SubscribeOption {
+	return func(so *SubscribeOptions) {
+		so.Gate = g
+	}
+}
+
+// WithConsumerLimiter sets the function that throttles reading
+// events for the subscription.
+func WithConsumerLimiter(l ConsumerLimiter) SubscribeOption {
+	return func(so *SubscribeOptions) {
+		so.Limiter = l
+	}
+}
+
+// WithResetPosition makes the subscription start reading at the start
+// bound even if the backend kept a position for it.
+func WithResetPosition() SubscribeOption {
+	return func(so *SubscribeOptions) {
+		so.ResetPosition = true
+	}
+}
+
+// NewSubscribeOptions returns the subscription settings after
+// applying the options.
+func NewSubscribeOptions(opts ...SubscribeOption) *SubscribeOptions {
+	so := &SubscribeOptions{}
+	for _, opt := range opts {
+		opt(so)
+	}
+	return so
+}
+
+// WaitGate blocks while the subscription gate, if any, is closed.
+func (so *SubscribeOptions) WaitGate(ctx context.Context) error {
+	if so == nil || so.Gate == nil {
+		return nil
+	}
+	return so.Gate(ctx)
+}
+
+// Acquire blocks until the subscription limiter, if any, accepts an event,
+// returning the function to be called when the event is dispatched.
+func (so *SubscribeOptions) Acquire(ctx context.Context) (func(), error) {
+	if so == nil || so.Limiter == nil {
+		return func() {}, nil
+	}
+	return so.Limiter(ctx)
+}
+
+// SubscriptionCleaner is an optional interface for backends that keep state
+// for subscriptions, like consumer positions, after unsubscribing.
+type SubscriptionCleaner interface {
+	// RemoveSubscription discards the state kept for a subscription
+	// whose trigger has been deleted.
+	RemoveSubscription(name string)
+
+	// RemoveOrphanedSubscriptions discards the state kept for subscriptions
+	// that are not in the list of names.
+	RemoveOrphanedSubscriptions(ctx context.Context, names []string) error
+}
 
 type Subscribable interface {
 	// Subscribe is a method that sets up a reader that will retrieve
 	// events from the backend and pass them to the consumer dispatcher.
 	// When the consumer dispatcher returns, the message is marked as
 	// processed and won't be delivered anymore.
-	Subscribe(name string, bounds *broker.TriggerBounds, ccb ConsumerDispatcher, scb SubscriptionStatusChange) error
+	Subscribe(name string, bounds *broker.TriggerBounds, ccb ConsumerDispatcher, scb SubscriptionStatusChange, opts ...SubscribeOption) error
 
 	// Unsubscribe is a method that removes a subscription referencing
 	// it by name, returning when all pending (already read) messages
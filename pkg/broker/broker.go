@@ -5,11 +5,14 @@ package broker
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"sync"
 	"syscall"
+	"time"
 
 	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
@@ -30,6 +33,7 @@ import (
 	"github.com/triggermesh/brokers/pkg/ingest/metrics"
 	"github.com/triggermesh/brokers/pkg/status"
 	"github.com/triggermesh/brokers/pkg/subscriptions"
+	"github.com/triggermesh/brokers/pkg/subscriptions/spool"
 )
 
 type Status string
@@ -41,6 +45,11 @@ const (
 	StatusStopping Status = "stopping"
 )
 
+const (
+	// Maximum duration for backend probes at readiness checks.
+	probeTimeout = 5 * time.Second
+)
+
 type Instance struct {
 	backend       backend.Interface
 	ingest        *ingest.Instance
@@ -54,6 +63,12 @@ type Instance struct {
 	statusManager status.Manager
 	status        Status
 
+	// dispatchTimeout is the maximum duration for a dispatch
+	// before the broker is considered not alive.
+	dispatchTimeout time.Duration
+	configLoaded    bool
+	m               sync.RWMutex
+
 	logger *zap.SugaredLogger
 }
 
@@ -97,7 +112,24 @@ func NewInstance(globals *cmd.Globals, b backend.Interface) (*Instance, error) {
 	}
 
 	// Create subscription manager.
-	sm, err := subscriptions.New(globals.Context, globals.Logger.Named("subs"), b, statusManager)
+	var smopts []subscriptions.ManagerOption
+	switch globals.LostEventsSink {
+	case cmd.LostEventsSinkSpool:
+		sp, err := spool.New(globals.LostEventsSpoolDir)
+		if err != nil {
+			return nil, err
+		}
+		smopts = append(smopts, subscriptions.WithLostEventSink(sp.StoreLost))
+
+	case cmd.LostEventsSinkBackend:
+		store, ok := b.(backend.DeadLetterStore)
+		if !ok {
+			return nil, fmt.Errorf("%s backend does not support dead letter storage for lost events", b.Info().Name)
+		}
+		smopts = append(smopts, subscriptions.WithLostEventSink(store.ProduceDeadLetter))
+	}
+
+	sm, err := subscriptions.New(globals.Context, globals.Logger.Named("subs"), b, statusManager, smopts...)
 	if err != nil {
 		return nil, err
 	}
@@ -109,10 +141,19 @@ func NewInstance(globals *cmd.Globals, b backend.Interface) (*Instance, error) {
 		return nil, err
 	}
 
-	i := ingest.NewInstance(ir, globals.Logger.Named("ingest"),
+	opts := []ingest.InstanceOption{
 		ingest.InstanceWithPort(globals.Port),
+		ingest.InstanceWithBrokerName(globals.BrokerName),
 		ingest.InstanceWithStatusManager(statusManager),
-	)
+	}
+
+	// Backends that support deduplication are used as the
+	// store for ingested events.
+	if d, ok := b.(backend.Deduplicator); ok {
+		opts = append(opts, ingest.InstanceWithDeduplicator(d))
+	}
+
+	i := ingest.NewInstance(ir, globals.Logger.Named("ingest"), opts...)
 
 	globals.Logger.Debug("Creating broker instance")
 	broker := &Instance{
@@ -122,6 +163,8 @@ func NewInstance(globals *cmd.Globals, b backend.Interface) (*Instance, error) {
 		statusManager: statusManager,
 		status:        StatusStopped,
 
+		dispatchTimeout: globals.DispatchTimeout,
+
 		logger: globals.Logger.Named("broker"),
 	}
 
@@ -183,6 +226,7 @@ func NewInstance(globals *cmd.Globals, b backend.Interface) (*Instance, error) {
 
 		km.AddSecretCallbackForBrokerConfig(i.UpdateFromConfig)
 		km.AddSecretCallbackForBrokerConfig(sm.UpdateFromConfig)
+		km.AddSecretCallbackForBrokerConfig(broker.markConfigLoaded)
 
 		if globals.KubernetesObservabilityConfigMapName != "" {
 			if err = km.AddConfigMapControllerForObservability(globals.KubernetesObservabilityConfigMapName); err != nil {
@@ -248,7 +292,8 @@ func NewInstance(globals *cmd.Globals, b backend.Interface) (*Instance, error) {
 func (i *Instance) Start(inctx context.Context) error {
 	i.logger.Debug("Starting broker instance")
 	i.status = StatusStarting
-	i.ingest.RegisterProbeHandler(i.ProbeHandler)
+	i.ingest.RegisterReadinessHandler(i.ReadinessChecks)
+	i.ingest.RegisterLivenessHandler(i.LivenessChecks)
 
 	sigctx, stop := signal.NotifyContext(inctx, os.Interrupt, syscall.SIGTERM)
 	defer func() {
@@ -297,6 +342,7 @@ func (i *Instance) Start(inctx context.Context) error {
 		i.logger.Debug("Adding config watcher callbacks")
 		i.bcw.AddCallback(i.ingest.UpdateFromConfig)
 		i.bcw.AddCallback(i.subscription.UpdateFromConfig)
+		i.bcw.AddCallback(i.markConfigLoaded)
 
 		// Start the configuration watcher for brokers.
 		// There is no need to add it to the wait group
@@ -319,6 +365,7 @@ func (i *Instance) Start(inctx context.Context) error {
 		i.logger.Debug("Adding config poller callbacks")
 		i.bcp.AddCallback(i.ingest.UpdateFromConfig)
 		i.bcp.AddCallback(i.subscription.UpdateFromConfig)
+		i.bcp.AddCallback(i.markConfigLoaded)
 
 		// Start the configuration poller for brokers.
 		// There is no need to add it to the wait group
@@ -350,12 +397,19 @@ func (i *Instance) Start(inctx context.Context) error {
 	if i.staticConfig != nil {
 		i.ingest.UpdateFromConfig(i.staticConfig)
 		i.subscription.UpdateFromConfig(i.staticConfig)
+		i.markConfigLoaded(i.staticConfig)
 	}
 
 	// Register producer function for received events at ingest.
 	i.ingest.RegisterCloudEventHandler(i.backend.Produce)
+	i.ingest.RegisterCloudEventBatchHandler(i.backend.ProduceBatch)
 
-	// TODO register probes at ingest
+	// Register backpressure handlers so that ingest rejects events when the
+	// backend is not available or close to its capacity.
+	i.ingest.RegisterBackendProbeHandler(i.backend.Probe)
+	if lr, ok := i.backend.(backend.LoadReporter); ok {
+		i.ingest.RegisterOverloadedHandler(lr.Overloaded)
+	}
 
 	// Start the server that ingests CloudEvents.
 	grp.Go(func() error {
@@ -372,7 +426,41 @@ func (i *Instance) GetStatus() Status {
 	return i.status
 }
 
-func (i *Instance) ProbeHandler() error {
-	// TODO check each service
-	return nil
+// ReadinessChecks informs whether the broker components are ready
+// to ingest and deliver events.
+func (i *Instance) ReadinessChecks(ctx context.Context) ingest.HealthChecks {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	checks := ingest.HealthChecks{
+		"backend":       i.backend.Probe(ctx),
+		"subscriptions": i.subscription.CheckFailures(),
+	}
+
+	i.m.RLock()
+	defer i.m.RUnlock()
+	if !i.configLoaded {
+		checks["config"] = errors.New("broker configuration has not been loaded")
+	} else {
+		checks["config"] = nil
+	}
+
+	return checks
+}
+
+// LivenessChecks informs whether the broker components are making progress.
+func (i *Instance) LivenessChecks(ctx context.Context) ingest.HealthChecks {
+	checks := ingest.HealthChecks{}
+
+	if i.dispatchTimeout != 0 {
+		checks["dispatch"] = i.subscription.CheckStalled(i.dispatchTimeout)
+	}
+
+	return checks
+}
+
+func (i *Instance) markConfigLoaded(_ *cfgbroker.Config) {
+	i.m.Lock()
+	defer i.m.Unlock()
+	i.configLoaded = true
 }
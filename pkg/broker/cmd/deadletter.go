@@ -0,0 +1,41 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"errors"
+)
+
+const (
+	DeadLetterActionList    = "list"
+	DeadLetterActionInspect = "inspect"
+	DeadLetterActionRedrive = "redrive"
+)
+
+// DeadLetterArgs manage the events stored at the backend dead
+// letter storage for a trigger.
+type DeadLetterArgs struct {
+	Action  string   `arg:"" enum:"list,inspect,redrive" help:"Dead letter operation: list, inspect or redrive."`
+	Trigger string   `arg:"" help:"Trigger name."`
+	IDs     []string `arg:"" optional:"" name:"id" help:"Dead letter IDs. When not informed, redrive uses the listed dead letters."`
+
+	Count int `help:"Maximum number of dead letters to list or re-drive." default:"100"`
+}
+
+func (a *DeadLetterArgs) Validate() error {
+	if a.Action == DeadLetterActionInspect && len(a.IDs) == 0 {
+		return errors.New("inspect requires at least one dead letter ID")
+	}
+
+	if a.Count < 1 {
+		return errors.New("count must be greater than 0")
+	}
+
+	return nil
+}
+
+// ReplayLostArgs push spooled lost events back to the broker.
+type ReplayLostArgs struct {
+	Triggers []string `arg:"" optional:"" name:"trigger" help:"Triggers whose spooled events are replayed. All triggers when not informed."`
+}
@@ -33,6 +33,12 @@ const (
 	defaultBrokerConfigPath = "/etc/triggermesh/broker.conf"
 )
 
+const (
+	LostEventsSinkNone    = "none"
+	LostEventsSinkSpool   = "spool"
+	LostEventsSinkBackend = "backend"
+)
+
 type ConfigMethod int
 
 const (
@@ -68,6 +74,12 @@ type Globals struct {
 
 	ObservabilityMetricsDomain string `help:"Domain to be used for some metrics reporters." env:"OBSERVABILITY_METRICS_DOMAIN" default:"triggermesh.io/eventing"`
 
+	LivenessDispatchTimeout string `help:"Maximum duration for an event dispatch before the broker is reported as not alive, using ISO8601. A zero duration disables the check." env:"LIVENESS_DISPATCH_TIMEOUT" default:"PT0S"`
+
+	// Last resort storage for events that could not be delivered.
+	LostEventsSink     string `help:"Storage for events that could not be delivered to the target nor the dead letter sink: none, spool or backend." env:"LOST_EVENTS_SINK" enum:"none,spool,backend" default:"none"`
+	LostEventsSpoolDir string `help:"Local directory where lost events are spooled." env:"LOST_EVENTS_SPOOL_DIR" default:"/var/spool/triggermesh"`
+
 	Context           context.Context    `kong:"-"`
 	Logger            *zap.SugaredLogger `kong:"-"`
 	LogLevel          zap.AtomicLevel    `kong:"-"`
@@ -75,6 +87,8 @@ type Globals struct {
 	ConfigMethod      ConfigMethod       `kong:"-"`
 	StatusCheckPeriod time.Duration      `kong:"-"`
 	StatusForcePeriod time.Duration      `kong:"-"`
+
+	DispatchTimeout time.Duration `kong:"-"`
 }
 
 func (s *Globals) Validate() error {
@@ -191,6 +205,19 @@ func (s *Globals) Validate() error {
 		s.StatusForcePeriod = p.DurationApprox()
 	}
 
+	if s.LivenessDispatchTimeout != "" {
+		p, err = period.Parse(s.LivenessDispatchTimeout)
+		if err != nil {
+			msg = append(msg, fmt.Sprintf("liveness dispatch timeout is not an ISO8601 duration: %v", err))
+		} else {
+			s.DispatchTimeout = p.DurationApprox()
+		}
+	}
+
+	if s.LostEventsSink == LostEventsSinkSpool && s.LostEventsSpoolDir == "" {
+		msg = append(msg, "Lost events spool directory must be informed when using the spool sink.")
+	}
+
 	if len(msg) != 0 {
 		s.ConfigMethod = ConfigMethodUnknown
 		return fmt.Errorf(strings.Join(msg, " "))
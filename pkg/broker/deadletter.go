@@ -0,0 +1,119 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+	"github.com/triggermesh/brokers/pkg/broker/cmd"
+	"github.com/triggermesh/brokers/pkg/subscriptions"
+)
+
+// RunDeadLetter executes an operation on the events stored at the
+// backend dead letter storage for a trigger, writing the outcome.
+func RunDeadLetter(ctx context.Context, args *cmd.DeadLetterArgs, b backend.Interface, out io.Writer) error {
+	store, ok := b.(backend.DeadLetterStore)
+	if !ok {
+		return fmt.Errorf("%s backend does not support dead letter storage", b.Info().Name)
+	}
+
+	if err := b.Init(ctx); err != nil {
+		return fmt.Errorf("could not initialize backend: %w", err)
+	}
+
+	switch args.Action {
+	case cmd.DeadLetterActionList:
+		dls, err := store.ListDeadLetters(ctx, args.Trigger, args.Count)
+		if err != nil {
+			return err
+		}
+		return writeDeadLetterList(out, dls)
+
+	case cmd.DeadLetterActionInspect:
+		for _, id := range args.IDs {
+			dl, err := store.GetDeadLetter(ctx, args.Trigger, id)
+			if err != nil {
+				return err
+			}
+
+			b, err := json.MarshalIndent(dl.Event, "", "  ")
+			if err != nil {
+				return fmt.Errorf("could not serialize dead letter %s: %w", id, err)
+			}
+			if _, err := fmt.Fprintf(out, "%s\n%s\n", id, b); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case cmd.DeadLetterActionRedrive:
+		return redriveDeadLetters(ctx, args, b, store, out)
+	}
+
+	return fmt.Errorf("unknown dead letter action %q", args.Action)
+}
+
+// redriveDeadLetters produces the dead letters to the backend routed to
+// the trigger, removing them from the dead letter storage.
+func redriveDeadLetters(ctx context.Context, args *cmd.DeadLetterArgs, b backend.Interface, store backend.DeadLetterStore, out io.Writer) error {
+	var dls []backend.DeadLetter
+	if len(args.IDs) == 0 {
+		var err error
+		if dls, err = store.ListDeadLetters(ctx, args.Trigger, args.Count); err != nil {
+			return err
+		}
+	} else {
+		for _, id := range args.IDs {
+			dl, err := store.GetDeadLetter(ctx, args.Trigger, id)
+			if err != nil {
+				return err
+			}
+			dls = append(dls, *dl)
+		}
+	}
+
+	for _, dl := range dls {
+		e, err := subscriptions.RedriveEvent(dl.Event, args.Trigger)
+		if err != nil {
+			return fmt.Errorf("dead letter %s: %w", dl.ID, err)
+		}
+
+		if err := b.Produce(ctx, e); err != nil {
+			return fmt.Errorf("could not re-drive dead letter %s: %w", dl.ID, err)
+		}
+
+		// The event was re-driven, failing to delete it from the
+		// storage would produce it again on the next re-drive.
+		if err := store.DeleteDeadLetter(ctx, args.Trigger, dl.ID); err != nil {
+			return fmt.Errorf("dead letter %s was re-driven but could not be deleted: %w", dl.ID, err)
+		}
+
+		if _, err := fmt.Fprintf(out, "Re-driven %s\n", dl.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeDeadLetterList(out io.Writer, dls []backend.DeadLetter) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTYPE\tSOURCE\tEVENT ID\tSTATUS CODE")
+
+	for _, dl := range dls {
+		code := "-"
+		if v, ok := dl.Event.Extensions()[subscriptions.ErrorCodeExtension]; ok {
+			code = fmt.Sprint(v)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", dl.ID, dl.Event.Type(), dl.Event.Source(), dl.Event.ID(), code)
+	}
+
+	return w.Flush()
+}
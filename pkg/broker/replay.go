@@ -0,0 +1,71 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package broker
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+	"github.com/triggermesh/brokers/pkg/broker/cmd"
+	"github.com/triggermesh/brokers/pkg/subscriptions"
+	"github.com/triggermesh/brokers/pkg/subscriptions/spool"
+)
+
+// RunReplayLost produces the events at the lost events spool back to the
+// backend, routed to the trigger that could not deliver them. Replayed
+// events are removed from the spool.
+func RunReplayLost(ctx context.Context, args *cmd.ReplayLostArgs, spoolDir string, b backend.Interface, out io.Writer) error {
+	sp, err := spool.New(spoolDir)
+	if err != nil {
+		return err
+	}
+
+	triggers := args.Triggers
+	if len(triggers) == 0 {
+		if triggers, err = sp.Triggers(); err != nil {
+			return err
+		}
+	}
+
+	if err := b.Init(ctx); err != nil {
+		return fmt.Errorf("could not initialize backend: %w", err)
+	}
+
+	for _, trigger := range triggers {
+		entries, err := sp.Entries(trigger)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			event, err := sp.Read(trigger, entry)
+			if err != nil {
+				return err
+			}
+
+			e, err := subscriptions.RedriveEvent(event, trigger)
+			if err != nil {
+				return fmt.Errorf("spooled event %s: %w", entry, err)
+			}
+
+			// Stop at the first failure, following events
+			// are kept in order at the spool.
+			if err := b.Produce(ctx, e); err != nil {
+				return fmt.Errorf("could not replay spooled event %s for trigger %q: %w", entry, trigger, err)
+			}
+
+			if err := sp.Remove(trigger, entry); err != nil {
+				return fmt.Errorf("spooled event %s was replayed but could not be removed: %w", entry, err)
+			}
+
+			if _, err := fmt.Fprintf(out, "Replayed %s for trigger %s\n", entry, trigger); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package hops keeps track of the number of times an event is re-ingested
+// by brokers, used to protect against event loops.
+package hops
+
+import (
+	"fmt"
+	"strings"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/types"
+)
+
+const (
+	// CountExtension is the number of times the event was re-ingested
+	// by brokers. Events that contain it are considered to be sent by
+	// a broker.
+	CountExtension = "triggermeshhops"
+	// TriggersExtension is the comma separated list of triggers that
+	// delivered the event or the events it was produced from.
+	TriggersExtension = "triggermeshhoptriggers"
+)
+
+// Count returns the event's hop count and whether it is informed.
+func Count(event *cloudevents.Event) (int, bool, error) {
+	v, ok := event.Extensions()[CountExtension]
+	if !ok {
+		return 0, false, nil
+	}
+
+	n, err := types.ToInteger(v)
+	if err != nil {
+		return 0, true, fmt.Errorf("not valid %s extension: %w", CountExtension, err)
+	}
+
+	return int(n), true, nil
+}
+
+// Triggers returns the triggers that processed the event.
+func Triggers(event *cloudevents.Event) []string {
+	v, ok := event.Extensions()[TriggersExtension]
+	if !ok {
+		return nil
+	}
+
+	s, err := types.ToString(v)
+	if err != nil || s == "" {
+		return nil
+	}
+
+	return strings.Split(s, ",")
+}
+
+// Set informs the hop count and triggers at the event.
+func Set(event *cloudevents.Event, count int, triggers []string) error {
+	if err := event.Context.SetExtension(CountExtension, count); err != nil {
+		return fmt.Errorf("could not set extension %s: %w", CountExtension, err)
+	}
+
+	if len(triggers) == 0 {
+		return nil
+	}
+
+	if err := event.Context.SetExtension(TriggersExtension, strings.Join(triggers, ",")); err != nil {
+		return fmt.Errorf("could not set extension %s: %w", TriggersExtension, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,191 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"knative.dev/pkg/apis"
+)
+
+// SecretValue is a value that can be informed inline or referenced from a
+// file, which keeps secrets out of the broker configuration. When parsed
+// from a string the value is considered inline.
+type SecretValue struct {
+	// Value informed inline.
+	Value *string `json:"value,omitempty"`
+	// File path that contains the value. The file is read every time it
+	// changes, leading and trailing white spaces are removed.
+	File *string `json:"file,omitempty"`
+}
+
+func (s *SecretValue) UnmarshalJSON(b []byte) error {
+	var v string
+	if err := json.Unmarshal(b, &v); err == nil {
+		s.Value = &v
+		s.File = nil
+		return nil
+	}
+
+	type secretValue SecretValue
+	return json.Unmarshal(b, (*secretValue)(s))
+}
+
+func (s *SecretValue) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if s == nil {
+		return
+	}
+
+	switch {
+	case s.Value == nil && s.File == nil:
+		errs = errs.Also(apis.ErrMissingOneOf("value", "file"))
+	case s.Value != nil && s.File != nil:
+		errs = errs.Also(apis.ErrMultipleOneOf("value", "file"))
+	case s.File != nil && *s.File == "":
+		errs = errs.Also(apis.ErrInvalidValue(*s.File, "file", "file path cannot be empty"))
+	}
+
+	return
+}
+
+// TargetAuth configures the authentication for requests sent to the target.
+// Only one of basic, bearer and oauth2 can be informed.
+type TargetAuth struct {
+	// Headers added to each request.
+	Headers map[string]SecretValue `json:"headers,omitempty"`
+
+	// Basic authentication credentials.
+	Basic *BasicAuth `json:"basic,omitempty"`
+
+	// Bearer is a static token sent at the Authorization header.
+	Bearer *SecretValue `json:"bearer,omitempty"`
+
+	// OAuth2 client credentials flow, tokens are cached until
+	// they expire.
+	OAuth2 *OAuth2ClientCredentials `json:"oauth2,omitempty"`
+}
+
+func (a *TargetAuth) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if a == nil {
+		return
+	}
+
+	set := []string{}
+	if a.Basic != nil {
+		set = append(set, "basic")
+	}
+	if a.Bearer != nil {
+		set = append(set, "bearer")
+	}
+	if a.OAuth2 != nil {
+		set = append(set, "oauth2")
+	}
+	if len(set) > 1 {
+		errs = errs.Also(apis.ErrMultipleOneOf(set...))
+	}
+
+	for k, v := range a.Headers {
+		if k == "" {
+			errs = errs.Also(apis.ErrInvalidKeyName(k, "headers", "header name cannot be empty"))
+			continue
+		}
+		if len(set) != 0 && http.CanonicalHeaderKey(k) == "Authorization" {
+			errs = errs.Also(apis.ErrInvalidKeyName(k, "headers", "Authorization header cannot be set along with "+set[0]))
+		}
+
+		v := v
+		errs = errs.Also(v.Validate(ctx).ViaFieldKey("headers", k))
+	}
+
+	return errs.Also(a.Basic.Validate(ctx).ViaField("basic")).
+		Also(a.Bearer.Validate(ctx).ViaField("bearer")).
+		Also(a.OAuth2.Validate(ctx).ViaField("oauth2"))
+}
+
+type BasicAuth struct {
+	User     string      `json:"user"`
+	Password SecretValue `json:"password"`
+}
+
+func (b *BasicAuth) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if b == nil {
+		return
+	}
+
+	if b.User == "" {
+		errs = errs.Also(apis.ErrMissingField("user"))
+	}
+
+	return errs.Also(b.Password.Validate(ctx).ViaField("password"))
+}
+
+type OAuth2ClientCredentials struct {
+	// TokenURL is the endpoint where tokens are requested.
+	TokenURL     string      `json:"tokenURL"`
+	ClientID     string      `json:"clientID"`
+	ClientSecret SecretValue `json:"clientSecret"`
+
+	// Scopes requested for the token.
+	Scopes []string `json:"scopes,omitempty"`
+	// EndpointParams are additional parameters for the token request,
+	// like the audience.
+	EndpointParams map[string]string `json:"endpointParams,omitempty"`
+}
+
+func (o *OAuth2ClientCredentials) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if o == nil {
+		return
+	}
+
+	if o.TokenURL == "" {
+		errs = errs.Also(apis.ErrMissingField("tokenURL"))
+	} else if _, err := url.ParseRequestURI(o.TokenURL); err != nil {
+		errs = errs.Also(apis.ErrInvalidValue(o.TokenURL, "tokenURL", err.Error()))
+	}
+
+	if o.ClientID == "" {
+		errs = errs.Also(apis.ErrMissingField("clientID"))
+	}
+
+	return errs.Also(o.ClientSecret.Validate(ctx).ViaField("clientSecret"))
+}
+
+// TargetTLS configures the TLS settings for connections to the target.
+// Certificate files are read again when they change.
+type TargetTLS struct {
+	// CAFile is the path to a PEM bundle used to verify the target
+	// certificate instead of the system pool.
+	CAFile string `json:"caFile,omitempty"`
+
+	// CertFile and KeyFile are the paths to the PEM client certificate
+	// and key used for mutual TLS.
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+
+	// ServerName used to verify the target certificate, defaults
+	// to the target host.
+	ServerName string `json:"serverName,omitempty"`
+
+	// InsecureSkipVerify disables the target certificate verification.
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+}
+
+func (t *TargetTLS) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if t == nil {
+		return
+	}
+
+	if t.CertFile != "" && t.KeyFile == "" {
+		errs = errs.Also(apis.ErrMissingField("keyFile"))
+	}
+
+	if t.KeyFile != "" && t.CertFile == "" {
+		errs = errs.Also(apis.ErrMissingField("certFile"))
+	}
+
+	return
+}
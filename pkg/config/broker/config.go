@@ -4,9 +4,19 @@
 package broker
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"net/url"
+	"strconv"
+	"text/template"
+	"time"
 
+	"github.com/rickb777/date/period"
+	"github.com/santhosh-tekuri/jsonschema/v5"
 	"sigs.k8s.io/yaml"
 
 	"knative.dev/pkg/apis"
@@ -15,6 +25,27 @@ import (
 type Ingest struct {
 	User     string `json:"user"`
 	Password string `json:"password"`
+
+	// Enrichment rules applied to events before they are produced
+	// to the backend.
+	Enrichment *Enrichment `json:"enrichment,omitempty"`
+
+	// Deduplication of incoming events by source and id.
+	Deduplication *Deduplication `json:"deduplication,omitempty"`
+
+	// RateLimit for incoming events.
+	RateLimit *RateLimit `json:"rateLimit,omitempty"`
+
+	// MaxEventSize is the maximum size in bytes for incoming events.
+	// Zero means no limit.
+	MaxEventSize int64 `json:"maxEventSize,omitempty"`
+
+	// MaxBatchSize is the maximum size in bytes for incoming
+	// batches. Zero means the default limit.
+	MaxBatchSize int64 `json:"maxBatchSize,omitempty"`
+
+	// Schemas used to validate the data of incoming events.
+	Schemas []Schema `json:"schemas,omitempty"`
 }
 
 func (i *Ingest) Validate(ctx context.Context) *apis.FieldError {
@@ -29,7 +60,217 @@ func (i *Ingest) Validate(ctx context.Context) *apis.FieldError {
 		}
 	}
 
-	return nil
+	errs := i.Enrichment.Validate(ctx).ViaField("enrichment").
+		Also(i.Deduplication.Validate(ctx).ViaField("deduplication")).
+		Also(i.RateLimit.Validate(ctx).ViaField("rateLimit"))
+
+	if i.MaxEventSize < 0 {
+		errs = errs.Also(apis.ErrInvalidValue(i.MaxEventSize, "maxEventSize", "maximum event size cannot be negative"))
+	}
+
+	if i.MaxBatchSize < 0 {
+		errs = errs.Also(apis.ErrInvalidValue(i.MaxBatchSize, "maxBatchSize", "maximum batch size cannot be negative"))
+	}
+
+	for n := range i.Schemas {
+		errs = errs.Also(i.Schemas[n].Validate(ctx).ViaFieldIndex("schemas", n))
+	}
+
+	return errs
+}
+
+// Schema is a JSON Schema that validates the data of the events
+// that match its type or dataschema.
+type Schema struct {
+	// Type of the events validated by this schema.
+	Type string `json:"type,omitempty"`
+	// DataSchema of the events validated by this schema.
+	DataSchema string `json:"dataschema,omitempty"`
+
+	// Schema is the JSON Schema document.
+	Schema json.RawMessage `json:"schema"`
+}
+
+// Compile parses the JSON Schema document.
+func (s *Schema) Compile() (*jsonschema.Schema, error) {
+	name := "schema.json"
+	c := jsonschema.NewCompiler()
+	if err := c.AddResource(name, bytes.NewReader(s.Schema)); err != nil {
+		return nil, err
+	}
+	return c.Compile(name)
+}
+
+func (s *Schema) Validate(ctx context.Context) (errs *apis.FieldError) {
+	switch {
+	case s.Type == "" && s.DataSchema == "":
+		errs = errs.Also(apis.ErrMissingOneOf("type", "dataschema"))
+	case s.Type != "" && s.DataSchema != "":
+		errs = errs.Also(apis.ErrMultipleOneOf("type", "dataschema"))
+	}
+
+	if len(s.Schema) == 0 {
+		return errs.Also(apis.ErrMissingField("schema"))
+	}
+
+	if _, err := s.Compile(); err != nil {
+		errs = errs.Also(apis.ErrInvalidValue(err.Error(), "schema", "not a valid JSON Schema"))
+	}
+
+	return errs
+}
+
+// RateLimit for incoming events using token buckets. Each of the
+// informed limits is applied, an event is accepted only when none
+// of them is exceeded.
+type RateLimit struct {
+	// Global limit for all incoming events.
+	Global *RateLimitRule `json:"global,omitempty"`
+	// PerSource limit, applied to each CloudEvents source.
+	PerSource *RateLimitRule `json:"perSource,omitempty"`
+	// PerIdentity limit, applied to requests authenticated with the ingest
+	// user, and to each client address for any other request.
+	PerIdentity *RateLimitRule `json:"perIdentity,omitempty"`
+}
+
+func (r *RateLimit) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if r == nil {
+		return
+	}
+
+	return r.Global.Validate(ctx).ViaField("global").
+		Also(r.PerSource.Validate(ctx).ViaField("perSource")).
+		Also(r.PerIdentity.Validate(ctx).ViaField("perIdentity"))
+}
+
+type RateLimitRule struct {
+	// EventsPerSecond is the rate at which the token bucket is refilled.
+	EventsPerSecond float64 `json:"eventsPerSecond"`
+	// Burst is the size of the token bucket. Defaults to the events per
+	// second rounded up.
+	Burst *int `json:"burst,omitempty"`
+}
+
+// GetBurst returns the size of the token bucket.
+func (r *RateLimitRule) GetBurst() int {
+	if r.Burst != nil {
+		return *r.Burst
+	}
+	return int(math.Ceil(r.EventsPerSecond))
+}
+
+func (r *RateLimitRule) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if r == nil {
+		return
+	}
+
+	if r.EventsPerSecond <= 0 {
+		errs = errs.Also(apis.ErrInvalidValue(r.EventsPerSecond, "eventsPerSecond", "events per second must be greater than 0"))
+	}
+
+	if r.Burst != nil && *r.Burst < 1 {
+		errs = errs.Also(apis.ErrInvalidValue(*r.Burst, "burst", "burst must be greater than 0"))
+	}
+
+	return
+}
+
+// Deduplication options for incoming events. Events whose source and
+// id have already been seen within the window are acknowledged but not
+// produced again to the backend.
+type Deduplication struct {
+	// Window is the duration an event is tracked to avoid duplicates.
+	// More information on Duration format:
+	//  - https://www.iso.org/iso-8601-date-and-time-format.html
+	//  - https://en.wikipedia.org/wiki/ISO_8601
+	Window string `json:"window"`
+}
+
+// GetWindow returns the deduplication window as a duration, zero
+// when deduplication is not configured.
+func (d *Deduplication) GetWindow() (time.Duration, error) {
+	if d == nil || d.Window == "" {
+		return 0, nil
+	}
+
+	p, err := period.Parse(d.Window)
+	if err != nil {
+		return 0, err
+	}
+
+	return p.DurationApprox(), nil
+}
+
+func (d *Deduplication) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if d == nil {
+		return
+	}
+
+	if _, err := d.GetWindow(); err != nil {
+		errs = errs.Also(&apis.FieldError{
+			Message: "deduplication window is not an ISO8601 duration",
+			Paths:   []string{"window"},
+			Details: err.Error(),
+		})
+	}
+
+	return
+}
+
+type TypeCasing string
+
+const (
+	TypeCasingLower TypeCasing = "lower"
+	TypeCasingUpper TypeCasing = "upper"
+)
+
+// Enrichment rules that the ingest applies to all incoming events so that
+// every trigger sees consistent events regardless of the producer.
+type Enrichment struct {
+	// ReceiveTimeExtension is the name of the extension where the time
+	// when the event was received by the broker will be written.
+	ReceiveTimeExtension *string `json:"receiveTimeExtension,omitempty"`
+
+	// BrokerNameExtension is the name of the extension where the broker
+	// name will be written.
+	BrokerNameExtension *string `json:"brokerNameExtension,omitempty"`
+
+	// DefaultSubject is set to events that do not inform the subject.
+	DefaultSubject *string `json:"defaultSubject,omitempty"`
+
+	// DefaultDataContentType is set to events that do not inform the data content type.
+	DefaultDataContentType *string `json:"defaultDataContentType,omitempty"`
+
+	// TypeCasing normalizes the event type casing, either lower or upper.
+	TypeCasing *TypeCasing `json:"typeCasing,omitempty"`
+}
+
+func (e *Enrichment) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if e == nil {
+		return
+	}
+
+	for field, ext := range map[string]*string{
+		"receiveTimeExtension": e.ReceiveTimeExtension,
+		"brokerNameExtension":  e.BrokerNameExtension,
+	} {
+		if ext == nil {
+			continue
+		}
+		if isSpecAttribute(*ext) || !validAttributeName.MatchString(*ext) {
+			errs = errs.Also(apis.ErrInvalidValue(*ext, field, "Extension name must start with a letter and can only contain lowercase alphanumeric"))
+		}
+	}
+
+	if e.TypeCasing != nil {
+		switch *e.TypeCasing {
+		case TypeCasingLower, TypeCasingUpper:
+		default:
+			errs = errs.Also(apis.ErrInvalidValue(*e.TypeCasing, "typeCasing", "Type casing must be either lower or upper"))
+		}
+	}
+
+	return
 }
 
 type BackoffPolicyType string
@@ -50,6 +291,37 @@ type DeliveryOptions struct {
 	//  - https://en.wikipedia.org/wiki/ISO_8601
 	BackoffDelay  *string `json:"backoffDelay,omitempty"`
 	DeadLetterURL *string `json:"deadLetterURL,omitempty"`
+
+	// DeadLetterErrorExtensions adds extensions with the delivery failure
+	// details to events sent to the dead letter sink.
+	DeadLetterErrorExtensions bool `json:"deadLetterErrorExtensions,omitempty"`
+
+	// DeadLetterBackend stores events that could not be delivered at a
+	// dead letter stream for the trigger inside the broker's backend,
+	// instead of sending them to a dead letter sink.
+	DeadLetterBackend bool `json:"deadLetterBackend,omitempty"`
+
+	// Timeout for each delivery request, using ISO8601 duration format.
+	Timeout *string `json:"timeout,omitempty"`
+
+	// RetryAfterMax caps the delay requested by targets using the Retry-After
+	// header at 429 and 503 responses, using ISO8601 duration format.
+	RetryAfterMax *string `json:"retryAfterMax,omitempty"`
+
+	// NonRetryableStatusCodes are response status codes that are not retried,
+	// sending the event to the dead letter sink right away.
+	NonRetryableStatusCodes []int `json:"nonRetryableStatusCodes,omitempty"`
+
+	// CircuitBreaker pauses consumption for the trigger when the
+	// target fails consecutively.
+	CircuitBreaker *CircuitBreaker `json:"circuitBreaker,omitempty"`
+
+	// MaxConcurrency is the maximum number of events being dispatched
+	// at the same time for the trigger.
+	MaxConcurrency *int `json:"maxConcurrency,omitempty"`
+
+	// RateLimit for events dispatched for the trigger.
+	RateLimit *RateLimitRule `json:"rateLimit,omitempty"`
 }
 
 func (d *DeliveryOptions) Validate(ctx context.Context) (errs *apis.FieldError) {
@@ -65,6 +337,63 @@ func (d *DeliveryOptions) Validate(ctx context.Context) (errs *apis.FieldError)
 				Details: err.Error(),
 			})
 		}
+
+		if d.DeadLetterBackend {
+			errs = errs.Also(apis.ErrMultipleOneOf("deadLetterURL", "deadLetterBackend"))
+		}
+	}
+
+	if d.Timeout != nil {
+		if _, err := period.Parse(*d.Timeout); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(*d.Timeout, "timeout", "not an ISO8601 duration: "+err.Error()))
+		}
+	}
+
+	if d.RetryAfterMax != nil {
+		if _, err := period.Parse(*d.RetryAfterMax); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(*d.RetryAfterMax, "retryAfterMax", "not an ISO8601 duration: "+err.Error()))
+		}
+	}
+
+	for n, code := range d.NonRetryableStatusCodes {
+		if code < 100 || code > 599 {
+			errs = errs.Also(apis.ErrInvalidArrayValue(code, "nonRetryableStatusCodes", n))
+		}
+	}
+
+	if d.MaxConcurrency != nil && *d.MaxConcurrency < 1 {
+		errs = errs.Also(apis.ErrInvalidValue(*d.MaxConcurrency, "maxConcurrency", "must be greater than 0"))
+	}
+
+	return errs.Also(d.CircuitBreaker.Validate(ctx).ViaField("circuitBreaker")).
+		Also(d.RateLimit.Validate(ctx).ViaField("rateLimit"))
+}
+
+// CircuitBreaker opens after a number of consecutive delivery failures,
+// pausing the trigger consumption. While open, a single event is sent
+// to the target every probe period, closing the breaker on success.
+type CircuitBreaker struct {
+	// ConsecutiveFailures that open the circuit breaker.
+	ConsecutiveFailures int `json:"consecutiveFailures"`
+
+	// ProbePeriod is the time to wait before probing the target while
+	// the breaker is open, using ISO8601 duration format.
+	ProbePeriod *string `json:"probePeriod,omitempty"`
+}
+
+func (c *CircuitBreaker) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if c == nil {
+		return
+	}
+
+	if c.ConsecutiveFailures < 1 {
+		errs = errs.Also(apis.ErrInvalidValue(c.ConsecutiveFailures, "consecutiveFailures", "must be greater than 0"))
+	}
+
+	if c.ProbePeriod != nil {
+		if _, err := period.Parse(*c.ProbePeriod); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(*c.ProbePeriod, "probePeriod", "not an ISO8601 duration: "+err.Error()))
+		}
 	}
 
 	return
@@ -74,6 +403,12 @@ type Target struct {
 	URL *string `json:"url,,omitempty"`
 	// Deprecated, use the trigger's Delivery options instead.
 	DeliveryOptions *DeliveryOptions `json:"deliveryOptions,omitempty"`
+
+	// Auth for requests sent to the target.
+	Auth *TargetAuth `json:"auth,omitempty"`
+
+	// TLS settings for connections to the target.
+	TLS *TargetTLS `json:"tls,omitempty"`
 }
 
 func (i *Target) Validate(ctx context.Context) (errs *apis.FieldError) {
@@ -91,7 +426,9 @@ func (i *Target) Validate(ctx context.Context) (errs *apis.FieldError) {
 		}
 	}
 
-	return errs.Also(i.DeliveryOptions.Validate(ctx))
+	return errs.Also(i.DeliveryOptions.Validate(ctx)).
+		Also(i.Auth.Validate(ctx).ViaField("auth")).
+		Also(i.TLS.Validate(ctx).ViaField("tls"))
 }
 
 type Filter struct {
@@ -141,6 +478,114 @@ type Filter struct {
 	//
 	// +optional
 	Suffix map[string]string `json:"suffix,omitempty"`
+
+	// Regex evaluates to true if the value of the matching CloudEvents
+	// attribute matches the regular expression specified. Regular expressions
+	// use the RE2 syntax and are validated when the configuration is parsed.
+	// Regex must contain exactly one property, where the key is the name of the
+	// CloudEvents attribute to be matched, and its value is the regular
+	// expression. The attribute name and value specified in the filter
+	// expression cannot be empty strings.
+	//
+	// +optional
+	Regex map[string]string `json:"regex,omitempty"`
+
+	// Range evaluates to true if the value of the matching CloudEvents
+	// attribute is within the bounds specified. Bounds can be either numbers
+	// or RFC3339 timestamps, but all bounds for an attribute must be of the
+	// same kind. Range must contain exactly one property, where the key is the
+	// name of the CloudEvents attribute to be matched, and its value are the
+	// range bounds.
+	//
+	// +optional
+	Range map[string]Range `json:"range,omitempty"`
+}
+
+// RangeValue is a range bound. It can be informed either as a JSON number or
+// as a string containing a number or an RFC3339 timestamp.
+type RangeValue string
+
+func (v *RangeValue) UnmarshalJSON(b []byte) error {
+	var n json.Number
+	if err := json.Unmarshal(b, &n); err == nil {
+		*v = RangeValue(n)
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("range value must be a number or a string: %w", err)
+	}
+	*v = RangeValue(s)
+
+	return nil
+}
+
+// Number returns the bound as a number, and whether it could be parsed.
+func (v RangeValue) Number() (float64, bool) {
+	f, err := strconv.ParseFloat(string(v), 64)
+	return f, err == nil
+}
+
+// Time returns the bound as a timestamp, and whether it could be parsed.
+func (v RangeValue) Time() (time.Time, bool) {
+	t, err := time.Parse(time.RFC3339Nano, string(v))
+	return t, err == nil
+}
+
+type RangeKind string
+
+const (
+	RangeKindNumber RangeKind = "number"
+	RangeKindTime   RangeKind = "time"
+)
+
+// Range bounds used to compare a CloudEvents attribute. At least one
+// bound must be informed, lower bounds are either exclusive (gt) or
+// inclusive (gte) but not both, the same applies to upper bounds.
+type Range struct {
+	GreaterThan        *RangeValue `json:"gt,omitempty"`
+	GreaterThanOrEqual *RangeValue `json:"gte,omitempty"`
+	LessThan           *RangeValue `json:"lt,omitempty"`
+	LessThanOrEqual    *RangeValue `json:"lte,omitempty"`
+}
+
+// Bounds returns the non empty bounds at the range.
+func (r *Range) Bounds() []RangeValue {
+	bs := []RangeValue{}
+	for _, b := range []*RangeValue{r.GreaterThan, r.GreaterThanOrEqual, r.LessThan, r.LessThanOrEqual} {
+		if b != nil {
+			bs = append(bs, *b)
+		}
+	}
+	return bs
+}
+
+// Kind returns the kind of values that the range compares. All bounds must
+// be of the same kind, otherwise an error is returned.
+func (r *Range) Kind() (RangeKind, error) {
+	var kind RangeKind
+	for _, b := range r.Bounds() {
+		var k RangeKind
+		if _, ok := b.Number(); ok {
+			k = RangeKindNumber
+		} else if _, ok := b.Time(); ok {
+			k = RangeKindTime
+		} else {
+			return "", fmt.Errorf("range value %q is neither a number nor an RFC3339 timestamp", b)
+		}
+
+		if kind != "" && kind != k {
+			return "", errors.New("all range values must be of the same kind")
+		}
+		kind = k
+	}
+
+	if kind == "" {
+		return "", errors.New("range must contain at least one bound")
+	}
+
+	return kind, nil
 }
 
 // Bounds applied to the trigger that mark the initial and final item to
@@ -171,11 +616,127 @@ type TriggerBounds struct {
 	ByDate *Bounds `json:"byDate,omitempty"`
 }
 
+// Transform operations applied to events after filtering and before
+// delivering them to the target.
+//
+// Operations are applied in this order: delete, rename, set, data. Templated
+// values at set are rendered using the attributes of the event before being
+// transformed.
+type Transform struct {
+	// Set attributes or extensions to the value provided. Values are Go
+	// templates that can reference existing attributes, for example:
+	// "{{.source}}/{{.subject}}"
+	Set map[string]string `json:"set,omitempty"`
+
+	// Rename attributes or extensions. The key is the existing attribute
+	// name and the value is the new name.
+	Rename map[string]string `json:"rename,omitempty"`
+
+	// Delete attributes or extensions.
+	Delete []string `json:"delete,omitempty"`
+
+	// Data reshapes the JSON data at the event.
+	Data *DataTransform `json:"data,omitempty"`
+}
+
+// DataTransform builds a new JSON object from the incoming event data.
+type DataTransform struct {
+	// Mapping is keyed by the dot separated path to write at the outgoing
+	// data, and the value is the dot separated path to read at the incoming
+	// data. Non existing incoming paths are ignored.
+	Mapping map[string]string `json:"mapping"`
+}
+
+var requiredAttributes = map[string]struct{}{
+	"specversion": {},
+	"id":          {},
+	"source":      {},
+	"type":        {},
+}
+
+var optionalAttributes = map[string]struct{}{
+	"subject":         {},
+	"time":            {},
+	"dataschema":      {},
+	"datacontenttype": {},
+}
+
+func isSpecAttribute(name string) bool {
+	if _, ok := requiredAttributes[name]; ok {
+		return true
+	}
+	_, ok := optionalAttributes[name]
+	return ok
+}
+
+func (t *Transform) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if t == nil {
+		return
+	}
+
+	for name, value := range t.Set {
+		if name == "specversion" {
+			errs = errs.Also(apis.ErrInvalidKeyName(name, "set", "specversion cannot be modified"))
+		}
+		if _, err := template.New(name).Parse(value); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(value, apis.CurrentField, err.Error()).ViaFieldKey("set", name))
+		}
+	}
+
+	// Renames are applied in no particular order, chained renames or
+	// renames to the same attribute would not produce consistent results.
+	targets := make(map[string]int, len(t.Rename))
+	for _, to := range t.Rename {
+		targets[to]++
+	}
+
+	for from, to := range t.Rename {
+		if _, ok := requiredAttributes[from]; ok {
+			errs = errs.Also(apis.ErrInvalidKeyName(from, "rename", "required attributes cannot be renamed"))
+		}
+		if _, ok := requiredAttributes[to]; ok || !validAttributeName.MatchString(to) {
+			errs = errs.Also(apis.ErrInvalidValue(to, apis.CurrentField, "rename target must be a valid extension name").ViaFieldKey("rename", from))
+		}
+		if _, ok := t.Rename[to]; ok {
+			errs = errs.Also(apis.ErrInvalidValue(to, apis.CurrentField, "rename target cannot be renamed").ViaFieldKey("rename", from))
+		}
+		if targets[to] > 1 {
+			errs = errs.Also(apis.ErrInvalidValue(to, apis.CurrentField, "rename target is used more than once").ViaFieldKey("rename", from))
+		}
+	}
+
+	for i, name := range t.Delete {
+		if _, ok := requiredAttributes[name]; ok {
+			errs = errs.Also(apis.ErrInvalidValue(name, apis.CurrentField, "required attributes cannot be deleted").ViaFieldIndex("delete", i))
+		}
+	}
+
+	errs = errs.Also(ValidateAttributesNames(t.Set).ViaField("set")).
+		Also(ValidateAttributesNames(t.Rename).ViaField("rename"))
+
+	if t.Data != nil {
+		if len(t.Data.Mapping) == 0 {
+			errs = errs.Also(apis.ErrMissingField("mapping").ViaField("data"))
+		}
+		for to, from := range t.Data.Mapping {
+			if to == "" || from == "" {
+				errs = errs.Also(apis.ErrInvalidKeyName(to, "mapping", "data mapping paths cannot be empty").ViaField("data"))
+			}
+		}
+	}
+
+	return
+}
+
 type Trigger struct {
 	Filters         []Filter         `json:"filters,omitempty"`
 	Target          Target           `json:"target"`
 	DeliveryOptions *DeliveryOptions `json:"deliveryOptions,omitempty"`
 	Bounds          *TriggerBounds   `json:"bounds,omitempty"`
+	Transform       *Transform       `json:"transform,omitempty"`
+
+	// Reply configures how events returned by the target are handled.
+	Reply *Reply `json:"reply,omitempty"`
 }
 
 // HACK temporary to make the Delivery options move smooth,
@@ -195,12 +756,127 @@ func (t *Trigger) Validate(ctx context.Context) *apis.FieldError {
 	}
 	return errs.Also(t.Target.Validate(ctx)).ViaField("target").
 		Also(t.DeliveryOptions.Validate(ctx).ViaField("deliveryOptions")).
-		Also(ValidateSubscriptionAPIFiltersList(ctx, t.Filters).ViaField("filters"))
+		Also(ValidateSubscriptionAPIFiltersList(ctx, t.Filters).ViaField("filters")).
+		Also(ValidateFilterExpressions(ctx, t.Filters).ViaField("filters")).
+		Also(t.Transform.Validate(ctx).ViaField("transform")).
+		Also(t.Reply.Validate(ctx).ViaField("reply"))
+}
+
+type ReplyPolicyType string
+
+const (
+	// ReplyPolicyIngest produces replies to the broker, failing the
+	// delivery when the reply cannot be produced.
+	ReplyPolicyIngest ReplyPolicyType = "ingest"
+	// ReplyPolicyIngestBestEffort produces replies to the broker, reply
+	// failures do not fail the delivery.
+	ReplyPolicyIngestBestEffort ReplyPolicyType = "ingest-best-effort"
+	// ReplyPolicyDiscard ignores replies.
+	ReplyPolicyDiscard ReplyPolicyType = "discard"
+	// ReplyPolicyForward sends replies to a URL, failing the delivery
+	// when the reply cannot be sent.
+	ReplyPolicyForward ReplyPolicyType = "forward"
+)
+
+type Reply struct {
+	// Policy for events returned by the target, defaults to ingest.
+	Policy ReplyPolicyType `json:"policy,omitempty"`
+
+	// URL where replies are sent when using the forward policy,
+	// which can be another broker.
+	URL *string `json:"url,omitempty"`
+}
+
+func (r *Reply) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if r == nil {
+		return
+	}
+
+	switch r.Policy {
+	case "", ReplyPolicyIngest, ReplyPolicyIngestBestEffort, ReplyPolicyDiscard:
+		if r.URL != nil {
+			errs = errs.Also(apis.ErrDisallowedFields("url"))
+		}
+
+	case ReplyPolicyForward:
+		if r.URL == nil || *r.URL == "" {
+			errs = errs.Also(apis.ErrMissingField("url"))
+		} else if _, err := url.Parse(*r.URL); err != nil {
+			errs = errs.Also(&apis.FieldError{
+				Message: "Reply URL cannot be parsed",
+				Paths:   []string{"url"},
+				Details: err.Error(),
+			})
+		}
+
+	default:
+		errs = errs.Also(apis.ErrInvalidValue(r.Policy, "policy",
+			"Reply policy must be one of ingest, ingest-best-effort, discard or forward"))
+	}
+
+	return
+}
+
+// GetPolicy returns the reply policy, defaulting to ingest.
+func (r *Reply) GetPolicy() ReplyPolicyType {
+	if r == nil || r.Policy == "" {
+		return ReplyPolicyIngest
+	}
+	return r.Policy
+}
+
+type LoopAction string
+
+const (
+	LoopActionDrop       LoopAction = "drop"
+	LoopActionDeadLetter LoopAction = "deadLetter"
+)
+
+// LoopProtection limits the number of times an event can be re-ingested
+// by brokers, either as a reply from a target or when received from
+// another broker.
+type LoopProtection struct {
+	// MaxHops is the maximum number of re-ingestions for an event.
+	MaxHops int `json:"maxHops"`
+
+	// Action for replies over the limit, defaults to drop. Events over
+	// the limit that are received at ingest are always dropped.
+	Action LoopAction `json:"action,omitempty"`
+}
+
+func (l *LoopProtection) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if l == nil {
+		return
+	}
+
+	if l.MaxHops < 1 {
+		errs = errs.Also(apis.ErrInvalidValue(l.MaxHops, "maxHops", "must be greater than 0"))
+	}
+
+	switch l.Action {
+	case "", LoopActionDrop, LoopActionDeadLetter:
+	default:
+		errs = errs.Also(apis.ErrInvalidValue(l.Action, "action", "Loop action must be either drop or deadLetter"))
+	}
+
+	return
+}
+
+// GetAction returns the action for replies over the limit,
+// defaulting to drop.
+func (l *LoopProtection) GetAction() LoopAction {
+	if l == nil || l.Action == "" {
+		return LoopActionDrop
+	}
+	return l.Action
 }
 
 type Config struct {
 	Ingest   *Ingest            `json:"ingest,omitempty"`
 	Triggers map[string]Trigger `json:"triggers"`
+
+	// LoopProtection is disabled when not informed.
+	LoopProtection *LoopProtection `json:"loopProtection,omitempty"`
 }
 
 func (c *Config) Validate(ctx context.Context) *apis.FieldError {
@@ -208,7 +884,8 @@ func (c *Config) Validate(ctx context.Context) *apis.FieldError {
 		return nil
 	}
 
-	errs := c.Ingest.Validate(ctx).ViaField("ingest")
+	errs := c.Ingest.Validate(ctx).ViaField("ingest").
+		Also(c.LoopProtection.Validate(ctx).ViaField("loopProtection"))
 
 	for k, t := range c.Triggers {
 		errs = errs.Also(t.Validate(ctx).ViaFieldKey("triggers", k))
@@ -6,6 +6,7 @@ package broker
 import (
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -21,6 +22,65 @@ triggers:
     fitlers:
     - exact:
         type: test.type
+`},
+		"regex and range": {
+			config: `
+triggers:
+  trigger1:
+    filters:
+    - regex:
+        type: ^com\.acme\.(order|invoice)\.
+    - range:
+        severity:
+          gte: 3
+    - range:
+        time:
+          gt: "2023-01-01T00:00:00Z"
+          lte: "2023-06-01T00:00:00Z"
+`},
+		"filters not validated by the feature": {
+			// Configurations accepted before the regex and range dialects
+			// were added must still be accepted.
+			config: `
+triggers:
+  trigger1:
+    filters:
+    - exact:
+        Type: test.type
+      prefix:
+        source: test
+`},
+		"transform": {
+			config: `
+triggers:
+  trigger1:
+    transform:
+      set:
+        subject: "{{.source}}/{{.id}}"
+      rename:
+        ext1: ext2
+      delete:
+      - ext3
+      data:
+        mapping:
+          order.id: id
+`},
+		"target auth": {
+			config: `
+triggers:
+  trigger1:
+    target:
+      url: http://localhost:9000
+      auth:
+        headers:
+          X-Static: value
+          X-Api-Key:
+            file: /etc/secrets/api-key
+        oauth2:
+          tokenURL: https://auth.example.com/token
+          clientID: broker
+          clientSecret:
+            file: /etc/secrets/client-secret
 `},
 	}
 
@@ -34,3 +94,220 @@ triggers:
 		})
 	}
 }
+
+func TestParseValidationError(t *testing.T) {
+	cases := map[string]struct {
+		config      string
+		expectedErr string
+	}{
+		"invalid regex": {
+			config: `
+triggers:
+  trigger1:
+    filters:
+    - regex:
+        type: ^com\.acme\.(order
+`,
+			expectedErr: "invalid value",
+		},
+		"rate limit without events per second": {
+			config: `
+ingest:
+  rateLimit:
+    perSource:
+      burst: 10
+`,
+			expectedErr: "events per second must be greater than 0",
+		},
+		"schema not valid": {
+			config: `
+ingest:
+  schemas:
+  - type: order.created
+    schema:
+      type: 12
+`,
+			expectedErr: "not a valid JSON Schema",
+		},
+		"target auth multiple methods": {
+			config: `
+triggers:
+  trigger1:
+    target:
+      auth:
+        bearer: t0k3n
+        basic:
+          user: user
+          password: p4ss
+`,
+			expectedErr: "expected exactly one, got both",
+		},
+		"delivery non retryable status code not valid": {
+			config: `
+triggers:
+  trigger1:
+    deliveryOptions:
+      timeout: PT10S
+      nonRetryableStatusCodes:
+      - 400
+      - 1000
+`,
+			expectedErr: "invalid value: 1000: triggers[trigger1].deliveryOptions.nonRetryableStatusCodes[1]",
+		},
+		"circuit breaker without failures": {
+			config: `
+triggers:
+  trigger1:
+    deliveryOptions:
+      circuitBreaker:
+        probePeriod: PT1M
+`,
+			expectedErr: "invalid value: 0: triggers[trigger1].deliveryOptions.circuitBreaker.consecutiveFailures",
+		},
+		"delivery max concurrency not valid": {
+			config: `
+triggers:
+  trigger1:
+    deliveryOptions:
+      maxConcurrency: 0
+      rateLimit:
+        eventsPerSecond: 10
+`,
+			expectedErr: "invalid value: 0: triggers[trigger1].deliveryOptions.maxConcurrency",
+		},
+		"transform chained rename": {
+			config: `
+triggers:
+  trigger1:
+    transform:
+      rename:
+        ext1: ext2
+        ext2: ext3
+`,
+			expectedErr: "invalid value: ext2: triggers[trigger1].transform.rename[ext1]",
+		},
+		"transform rename same target": {
+			config: `
+triggers:
+  trigger1:
+    transform:
+      rename:
+        ext1: ext3
+        ext2: ext3
+`,
+			expectedErr: "rename target is used more than once",
+		},
+		"reply forward without URL": {
+			config: `
+triggers:
+  trigger1:
+    reply:
+      policy: forward
+`,
+			expectedErr: "missing field(s): triggers[trigger1].reply.url",
+		},
+		"reply unknown policy": {
+			config: `
+triggers:
+  trigger1:
+    reply:
+      policy: drop
+`,
+			expectedErr: "invalid value: drop: triggers[trigger1].reply.policy",
+		},
+		"loop protection without max hops": {
+			config: `
+loopProtection:
+  action: deadLetter
+triggers:
+  trigger1:
+`,
+			expectedErr: "invalid value: 0: loopProtection.maxHops",
+		},
+		"dead letter URL and backend": {
+			config: `
+triggers:
+  trigger1:
+    deliveryOptions:
+      deadLetterURL: http://dls
+      deadLetterBackend: true
+`,
+			expectedErr: "expected exactly one, got both: triggers[trigger1].deliveryOptions.deadLetterBackend, triggers[trigger1].deliveryOptions.deadLetterURL",
+		},
+		"range mixed kinds": {
+			config: `
+triggers:
+  trigger1:
+    filters:
+    - range:
+        severity:
+          gte: 3
+          lt: "2023-06-01T00:00:00Z"
+`,
+			expectedErr: "all range values must be of the same kind",
+		},
+		"range not comparable": {
+			config: `
+triggers:
+  trigger1:
+    filters:
+    - range:
+        severity:
+          gte: high
+`,
+			expectedErr: "neither a number nor an RFC3339 timestamp",
+		},
+		"range with both lower bounds": {
+			config: `
+triggers:
+  trigger1:
+    filters:
+    - range:
+        severity:
+          gt: 1
+          gte: 3
+`,
+			expectedErr: "expected exactly one, got both",
+		},
+		"multiple dialects": {
+			config: `
+triggers:
+  trigger1:
+    filters:
+    - regex:
+        type: ^com\.acme\.
+      range:
+        severity:
+          gte: 3
+`,
+			expectedErr: "multiple dialects found",
+		},
+		"transform delete required attribute": {
+			config: `
+triggers:
+  trigger1:
+    transform:
+      delete:
+      - type
+`,
+			expectedErr: "required attributes cannot be deleted",
+		},
+		"transform invalid template": {
+			config: `
+triggers:
+  trigger1:
+    transform:
+      set:
+        subject: "{{.source"
+`,
+			expectedErr: "unclosed action",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse(tc.config)
+			assert.ErrorContains(t, err, tc.expectedErr)
+		})
+	}
+}
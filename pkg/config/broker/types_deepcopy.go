@@ -53,6 +53,20 @@ func (in *Filter) DeepCopyInto(out *Filter) {
 			(*out)[key] = val
 		}
 	}
+	if in.Regex != nil {
+		in, out := &in.Regex, &out.Regex
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Range != nil {
+		in, out := &in.Range, &out.Range
+		*out = make(map[string]Range, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
 	return
 }
 
@@ -65,3 +79,39 @@ func (in *Filter) DeepCopy() *Filter {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Range) DeepCopyInto(out *Range) {
+	*out = *in
+	if in.GreaterThan != nil {
+		in, out := &in.GreaterThan, &out.GreaterThan
+		*out = new(RangeValue)
+		**out = **in
+	}
+	if in.GreaterThanOrEqual != nil {
+		in, out := &in.GreaterThanOrEqual, &out.GreaterThanOrEqual
+		*out = new(RangeValue)
+		**out = **in
+	}
+	if in.LessThan != nil {
+		in, out := &in.LessThan, &out.LessThan
+		*out = new(RangeValue)
+		**out = **in
+	}
+	if in.LessThanOrEqual != nil {
+		in, out := &in.LessThanOrEqual, &out.LessThanOrEqual
+		*out = new(RangeValue)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Range.
+func (in *Range) DeepCopy() *Range {
+	if in == nil {
+		return nil
+	}
+	out := new(Range)
+	in.DeepCopyInto(out)
+	return out
+}
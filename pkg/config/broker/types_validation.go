@@ -75,6 +75,70 @@ func ValidateSubscriptionAPIFilter(ctx context.Context, filter *Filter) (errs *a
 	return errs
 }
 
+// ValidateFilterExpressions validates the regex and range dialects at the
+// filters list, including nested filters. Unlike the rest of the filters
+// validation it does not depend on the new trigger filters feature.
+func ValidateFilterExpressions(ctx context.Context, filters []Filter) (errs *apis.FieldError) {
+	for i := range filters {
+		errs = errs.Also(validateFilterExpressions(ctx, &filters[i]).ViaIndex(i))
+	}
+	return errs
+}
+
+func validateFilterExpressions(ctx context.Context, filter *Filter) (errs *apis.FieldError) {
+	if filter == nil {
+		return nil
+	}
+
+	// Dialects are already checked when the feature is enabled.
+	if (len(filter.Regex) > 0 || len(filter.Range) > 0) &&
+		!feature.FromContext(ctx).IsEnabled(feature.NewTriggerFilters) {
+		errs = ValidateOneOf(filter)
+	}
+
+	return errs.Also(
+		ValidateRegexExpressions(filter.Regex).ViaField("regex"),
+	).Also(
+		ValidateRangeExpressions(filter.Range).ViaField("range"),
+	).Also(
+		ValidateFilterExpressions(ctx, filter.All).ViaField("all"),
+	).Also(
+		ValidateFilterExpressions(ctx, filter.Any).ViaField("any"),
+	).Also(
+		validateFilterExpressions(ctx, filter.Not).ViaField("not"),
+	)
+}
+
+func ValidateRegexExpressions(exprs map[string]string) (errs *apis.FieldError) {
+	errs = ValidateAttributesNames(exprs)
+	for attr, expr := range exprs {
+		if _, err := regexp.Compile(expr); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(expr, apis.CurrentField, err.Error()).ViaKey(attr))
+		}
+	}
+	return errs
+}
+
+func ValidateRangeExpressions(ranges map[string]Range) (errs *apis.FieldError) {
+	for attr, r := range ranges {
+		if !validAttributeName.MatchString(attr) {
+			errs = errs.Also(apis.ErrInvalidKeyName(attr, apis.CurrentField, "Attribute name must start with a letter and can only contain lowercase alphanumeric").ViaKey(attr))
+		}
+
+		if r.GreaterThan != nil && r.GreaterThanOrEqual != nil {
+			errs = errs.Also(apis.ErrMultipleOneOf("gt", "gte").ViaKey(attr))
+		}
+		if r.LessThan != nil && r.LessThanOrEqual != nil {
+			errs = errs.Also(apis.ErrMultipleOneOf("lt", "lte").ViaKey(attr))
+		}
+
+		if _, err := r.Kind(); err != nil {
+			errs = errs.Also(apis.ErrGeneric(err.Error()).ViaKey(attr))
+		}
+	}
+	return errs
+}
+
 func ValidateOneOf(filter *Filter) (err *apis.FieldError) {
 	if filter != nil && hasMultipleDialects(filter) {
 		return apis.ErrGeneric("multiple dialects found, filters can have only one dialect set")
@@ -115,6 +179,20 @@ func hasMultipleDialects(filter *Filter) bool {
 			dialectFound = true
 		}
 	}
+	if len(filter.Regex) > 0 {
+		if dialectFound {
+			return true
+		} else {
+			dialectFound = true
+		}
+	}
+	if len(filter.Range) > 0 {
+		if dialectFound {
+			return true
+		} else {
+			dialectFound = true
+		}
+	}
 	if filter.Not != nil && dialectFound {
 		return true
 	}
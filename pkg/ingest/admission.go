@@ -0,0 +1,214 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// Period for checking the backend availability.
+	backendProbePeriod = 5 * time.Second
+
+	// Content type for the CloudEvents JSON structured format.
+	structuredContentType = "application/cloudevents+json"
+)
+
+var (
+	errRateLimited = errors.New("rate limit exceeded")
+)
+
+// admissionMiddleware rejects incoming events when the backend is not able
+// to receive them, or when the configured rate limits are exceeded.
+//
+// Batches are checked for backpressure here, while rate limits are applied
+// to each element at the batch handler.
+func (i *Instance) admissionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if err := i.backpressure(); err != nil {
+			i.reporter.ReportThrottledEvent()
+			i.logger.Debugw("Rejecting request due to backpressure", zap.Error(err))
+			w.Header().Set("Retry-After", retryAfterSeconds(backendProbePeriod))
+			i.writeErrorResponse(w, http.StatusServiceUnavailable, err)
+			return
+		}
+
+		mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mt == batchContentType {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if err := i.limitBody(r); err != nil {
+			i.reporter.ReportNonValidEvent()
+			i.writeErrorResponse(w, http.StatusRequestEntityTooLarge, err)
+			return
+		}
+
+		source, err := requestSource(r, mt)
+		if err != nil {
+			// Let the CloudEvents receiver deal with malformed requests.
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if ok, wait := i.admit(r, source); !ok {
+			i.reporter.ReportThrottledEvent()
+			w.Header().Set("Retry-After", retryAfterSeconds(wait))
+			i.writeErrorResponse(w, http.StatusTooManyRequests, errRateLimited)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// limitBody reads the request body making sure it does not exceed the
+// maximum event size. The body is restored for the next handlers.
+func (i *Instance) limitBody(r *http.Request) error {
+	i.m.RLock()
+	max := i.maxEventSize
+	i.m.RUnlock()
+
+	if max == 0 {
+		return nil
+	}
+
+	if r.ContentLength > max {
+		return eventSizeError(max)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, max+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(body)) > max {
+		return eventSizeError(max)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	return nil
+}
+
+func eventSizeError(max int64) error {
+	return fmt.Errorf("event size exceeds the maximum of %d bytes", max)
+}
+
+// backpressure returns an error when the backend should not
+// receive events.
+func (i *Instance) backpressure() error {
+	if i.overloadedHandler != nil && i.overloadedHandler() {
+		return errors.New("backend is above its high-water mark")
+	}
+
+	i.m.RLock()
+	defer i.m.RUnlock()
+	return i.backendErr
+}
+
+// admit checks the rate limits for an event with the informed source
+// received at the request.
+func (i *Instance) admit(r *http.Request, source string) (bool, time.Duration) {
+	i.m.RLock()
+	rl := i.rateLimiter
+	i.m.RUnlock()
+
+	if rl == nil {
+		return true, 0
+	}
+
+	return rl.allow(i.requestIdentity(r), source, time.Now())
+}
+
+// requestIdentity returns the key for per identity rate limits, which is the
+// user when the request credentials match the configured ones, or the client
+// address otherwise. Unverified user names are not used to prevent clients
+// from choosing a new key for each request.
+func (i *Instance) requestIdentity(r *http.Request) string {
+	i.m.RLock()
+	user, password := i.user, i.password
+	i.m.RUnlock()
+
+	if u, p, ok := r.BasicAuth(); ok && user != "" &&
+		subtle.ConstantTimeCompare([]byte(u), []byte(user)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(p), []byte(password)) == 1 {
+		return "user:" + u
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "addr:" + host
+}
+
+// probeBackend periodically checks the backend availability until
+// the context is done.
+func (i *Instance) probeBackend(ctx context.Context) {
+	t := time.NewTicker(backendProbePeriod)
+	defer t.Stop()
+
+	for {
+		err := i.backendProbeHandler(ctx)
+		if err != nil {
+			i.logger.Errorw("Backend probe failed, rejecting incoming events", zap.Error(err))
+		}
+
+		i.m.Lock()
+		i.backendErr = err
+		i.m.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// requestSource returns the CloudEvent source for binary and structured
+// requests, restoring the request body after reading it.
+func requestSource(r *http.Request, mediaType string) (string, error) {
+	if mediaType != structuredContentType {
+		return r.Header.Get("Ce-Source"), nil
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return "", err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	event := struct {
+		Source string `json:"source"`
+	}{}
+	if err := json.Unmarshal(body, &event); err != nil {
+		return "", err
+	}
+
+	return event.Source, nil
+}
+
+func retryAfterSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
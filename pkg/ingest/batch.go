@@ -0,0 +1,224 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"go.uber.org/zap"
+)
+
+const (
+	// Content type for the CloudEvents JSON batch format.
+	// https://github.com/cloudevents/spec/blob/v1.0.2/cloudevents/formats/json-format.md#4-json-batch-format
+	batchContentType = "application/cloudevents-batch+json"
+
+	// Maximum size in bytes for incoming batches when not configured.
+	defaultMaxBatchSize int64 = 10 << 20
+)
+
+// BatchEventResult informs about the processing outcome of each element
+// in an incoming batch, so that clients can retry only the failures.
+type BatchEventResult struct {
+	ID       string `json:"id,omitempty"`
+	Source   string `json:"source,omitempty"`
+	Accepted bool   `json:"accepted"`
+	Error    string `json:"error,omitempty"`
+}
+
+type BatchResponse struct {
+	Results []BatchEventResult `json:"results"`
+}
+
+// batchMiddleware intercepts requests using the CloudEvents batch content
+// type, letting any other request reach the CloudEvents receiver.
+func (i *Instance) batchMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mt != batchContentType {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		i.batchHandler(w, r)
+	})
+}
+
+func (i *Instance) batchHandler(w http.ResponseWriter, r *http.Request) {
+	if i.ceBatchHandler == nil {
+		i.logger.Errorw("CloudEvents batch lost due to no ingest handler configured")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	i.m.RLock()
+	maxBatchSize := i.maxBatchSize
+	i.m.RUnlock()
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBatchSize))
+	if err != nil {
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			i.reporter.ReportNonValidEvent()
+			i.writeBatchError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("batch size exceeds the maximum of %d bytes", mbe.Limit))
+			return
+		}
+		i.writeBatchError(w, http.StatusBadRequest, fmt.Errorf("could not read request: %w", err))
+		return
+	}
+
+	raw := []json.RawMessage{}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		i.reporter.ReportNonValidEvent()
+		i.writeBatchError(w, http.StatusBadRequest, fmt.Errorf("batch must be a JSON array of CloudEvents: %w", err))
+		return
+	}
+
+	start := time.Now()
+	ctx := r.Context()
+	results := make([]BatchEventResult, len(raw))
+
+	// Elements that pass validation and ingest rules are kept along with
+	// their position at the batch.
+	events := make([]*cloudevents.Event, 0, len(raw))
+	positions := make([]int, 0, len(raw))
+	dedupKeys := make([]string, 0, len(raw))
+
+	// Maximum wait time for elements rejected due to rate limits.
+	var retryAfter time.Duration
+
+	i.m.RLock()
+	maxEventSize := i.maxEventSize
+	i.m.RUnlock()
+
+	for n := range raw {
+		if maxEventSize != 0 && int64(len(raw[n])) > maxEventSize {
+			i.reporter.ReportNonValidEvent()
+			results[n].Error = eventSizeError(maxEventSize).Error()
+			continue
+		}
+
+		event := &cloudevents.Event{}
+		if err := event.UnmarshalJSON(raw[n]); err != nil {
+			i.reporter.ReportNonValidEvent()
+			results[n].Error = fmt.Sprintf("could not parse CloudEvent: %v", err)
+			continue
+		}
+
+		results[n].ID = event.ID()
+		results[n].Source = event.Source()
+
+		if err := event.Validate(); err != nil {
+			i.reporter.ReportNonValidEvent()
+			results[n].Error = fmt.Sprintf("not valid CloudEvent: %v", err)
+			continue
+		}
+
+		if err := i.validateSchema(event); err != nil {
+			i.reporter.ReportNonValidEvent()
+			results[n].Error = err.Error()
+			continue
+		}
+
+		if ok, wait := i.admit(r, event.Source()); !ok {
+			i.reporter.ReportThrottledEvent()
+			results[n].Error = errRateLimited.Error()
+			if wait > retryAfter {
+				retryAfter = wait
+			}
+			continue
+		}
+
+		i.logger.Debug(fmt.Sprintf("Received CloudEvent in batch: %v", event.String()))
+
+		produce, dedupKey, err := i.prepareEvent(ctx, event)
+		if err != nil {
+			results[n].Error = err.Error()
+			continue
+		}
+		if !produce {
+			results[n].Accepted = true
+			continue
+		}
+
+		events = append(events, event)
+		positions = append(positions, n)
+		dedupKeys = append(dedupKeys, dedupKey)
+	}
+
+	if len(events) != 0 {
+		errs := i.ceBatchHandler(ctx, events)
+		latency := float64(time.Since(start) / time.Millisecond)
+
+		for k, err := range errs {
+			n := positions[k]
+			if err != nil {
+				i.logger.Errorw("Could not produce CloudEvent to broker", zap.Error(err))
+				i.forgetEvent(ctx, dedupKeys[k])
+				results[n].Error = err.Error()
+			} else {
+				results[n].Accepted = true
+			}
+
+			i.reporter.ReportProcessedEvent(err == nil, events[k].Type(), latency)
+		}
+
+		i.updateIngestedStatus()
+	}
+
+	code := http.StatusOK
+	for n := range results {
+		if !results[n].Accepted {
+			code = http.StatusMultiStatus
+			break
+		}
+	}
+
+	if retryAfter != 0 {
+		w.Header().Set("Retry-After", retryAfterSeconds(retryAfter))
+	}
+
+	i.writeBatchResponse(w, code, &BatchResponse{Results: results})
+}
+
+func (i *Instance) writeBatchError(w http.ResponseWriter, code int, err error) {
+	i.logger.Errorw("Could not process CloudEvents batch", zap.Error(err))
+	i.writeErrorResponse(w, code, err)
+}
+
+func (i *Instance) writeErrorResponse(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	b, _ := json.Marshal(map[string]string{"error": err.Error()})
+	if _, err := w.Write(b); err != nil {
+		i.logger.Errorw("Could not write HTTP response", zap.Error(err))
+	}
+}
+
+func (i *Instance) writeBatchResponse(w http.ResponseWriter, code int, res *BatchResponse) {
+	b, err := json.Marshal(res)
+	if err != nil {
+		i.logger.Errorw("Could not serialize CloudEvents batch response", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if _, err := w.Write(b); err != nil {
+		i.logger.Errorw("Could not write HTTP response", zap.Error(err))
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+type fakeReporter struct{}
+
+func (fakeReporter) ReportProcessedEvent(ingested bool, eventType string, msLatency float64) {}
+func (fakeReporter) ReportNonValidEvent()                                                    {}
+func (fakeReporter) ReportDuplicatedEvent()                                                  {}
+func (fakeReporter) ReportThrottledEvent()                                                   {}
+func (fakeReporter) ReportLoopDetected()                                                     {}
+
+func TestBatchHandler(t *testing.T) {
+	testCases := map[string]struct {
+		body         string
+		maxBatchSize int64
+
+		expectedCode     int
+		expectedAccepted []bool
+		expectedProduced []string
+	}{
+		"all accepted": {
+			body: `[
+				{"specversion":"1.0","id":"1","source":"s","type":"t"},
+				{"specversion":"1.0","id":"2","source":"s","type":"t"}
+			]`,
+			expectedCode:     http.StatusOK,
+			expectedAccepted: []bool{true, true},
+			expectedProduced: []string{"1", "2"},
+		},
+		"not valid and failed elements": {
+			body: `[
+				{"specversion":"1.0","id":"1","source":"s","type":"t"},
+				{"specversion":"1.0","id":"2","source":"s"},
+				{"specversion":"1.0","id":"fail","source":"s","type":"t"}
+			]`,
+			expectedCode:     http.StatusMultiStatus,
+			expectedAccepted: []bool{true, false, false},
+			expectedProduced: []string{"1"},
+		},
+		"not a batch": {
+			body:         `{"specversion":"1.0","id":"1","source":"s","type":"t"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		"oversized batch": {
+			body: `[
+				{"specversion":"1.0","id":"1","source":"s","type":"t"},
+				{"specversion":"1.0","id":"2","source":"s","type":"t"}
+			]`,
+			maxBatchSize: 64,
+			expectedCode: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for n, tc := range testCases {
+		t.Run(n, func(t *testing.T) {
+			produced := []string{}
+			i := NewInstance(fakeReporter{}, zaptest.NewLogger(t).Sugar())
+			if tc.maxBatchSize != 0 {
+				i.UpdateFromConfig(&cfgbroker.Config{
+					Ingest: &cfgbroker.Ingest{MaxBatchSize: tc.maxBatchSize},
+				})
+			}
+			i.RegisterCloudEventBatchHandler(func(ctx context.Context, events []*cloudevents.Event) []error {
+				errs := make([]error, len(events))
+				for k, e := range events {
+					if e.ID() == "fail" {
+						errs[k] = errors.New("produce failed")
+						continue
+					}
+					produced = append(produced, e.ID())
+				}
+				return errs
+			})
+
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Fail(t, "Batch requests must not reach the CloudEvents receiver")
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/cloudevents-batch+json; charset=utf-8")
+			rec := httptest.NewRecorder()
+
+			i.batchMiddleware(next).ServeHTTP(rec, req)
+
+			require.Equal(t, tc.expectedCode, rec.Code)
+			if tc.expectedAccepted == nil {
+				return
+			}
+
+			res := &BatchResponse{}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), res))
+			require.Len(t, res.Results, len(tc.expectedAccepted))
+			for k, accepted := range tc.expectedAccepted {
+				assert.Equal(t, accepted, res.Results[k].Accepted, "Unexpected result for element %d", k)
+			}
+			assert.Equal(t, tc.expectedProduced, produced)
+		})
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// enrich applies the enrichment rules to the event.
+func enrich(event *cloudevents.Event, e *cfgbroker.Enrichment, brokerName string, receivedAt time.Time) error {
+	if e == nil {
+		return nil
+	}
+
+	if e.ReceiveTimeExtension != nil {
+		if err := event.Context.SetExtension(*e.ReceiveTimeExtension, receivedAt); err != nil {
+			return fmt.Errorf("could not set receive time extension: %w", err)
+		}
+	}
+
+	if e.BrokerNameExtension != nil {
+		if err := event.Context.SetExtension(*e.BrokerNameExtension, brokerName); err != nil {
+			return fmt.Errorf("could not set broker name extension: %w", err)
+		}
+	}
+
+	if e.DefaultSubject != nil && event.Subject() == "" {
+		event.SetSubject(*e.DefaultSubject)
+	}
+
+	if e.DefaultDataContentType != nil && event.DataContentType() == "" {
+		event.SetDataContentType(*e.DefaultDataContentType)
+	}
+
+	if e.TypeCasing != nil {
+		switch *e.TypeCasing {
+		case cfgbroker.TypeCasingLower:
+			event.SetType(strings.ToLower(event.Type()))
+		case cfgbroker.TypeCasingUpper:
+			event.SetType(strings.ToUpper(event.Type()))
+		}
+	}
+
+	return event.Validate()
+}
@@ -0,0 +1,92 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestEnrich(t *testing.T) {
+	receivedAt := time.Date(2023, 6, 1, 10, 0, 0, 0, time.UTC)
+	rtExt, bnExt := "receivedat", "broker"
+	subject, contentType := "default-subject", cloudevents.ApplicationJSON
+	lower, upper := cfgbroker.TypeCasingLower, cfgbroker.TypeCasingUpper
+
+	testCases := map[string]struct {
+		enrichment *cfgbroker.Enrichment
+		event      cloudevents.Event
+
+		expectedType        string
+		expectedSubject     string
+		expectedContentType string
+		expectedExtensions  map[string]interface{}
+	}{
+		"no enrichment": {
+			event:        lib.NewCloudEvent(lib.CloudEventWithTypeOption("Some.Type")),
+			expectedType: "Some.Type",
+		},
+		"extensions": {
+			enrichment: &cfgbroker.Enrichment{
+				ReceiveTimeExtension: &rtExt,
+				BrokerNameExtension:  &bnExt,
+			},
+			event:        lib.NewCloudEvent(lib.CloudEventWithTypeOption("Some.Type")),
+			expectedType: "Some.Type",
+			expectedExtensions: map[string]interface{}{
+				rtExt: types.Timestamp{Time: receivedAt},
+				bnExt: "my-broker",
+			},
+		},
+		"defaults for missing attributes": {
+			enrichment: &cfgbroker.Enrichment{
+				DefaultSubject:         &subject,
+				DefaultDataContentType: &contentType,
+				TypeCasing:             &lower,
+			},
+			event:               lib.NewCloudEvent(lib.CloudEventWithTypeOption("Some.Type")),
+			expectedType:        "some.type",
+			expectedSubject:     subject,
+			expectedContentType: contentType,
+		},
+		"defaults do not overwrite": {
+			enrichment: &cfgbroker.Enrichment{
+				DefaultSubject:         &subject,
+				DefaultDataContentType: &contentType,
+				TypeCasing:             &upper,
+			},
+			event: func() cloudevents.Event {
+				e := lib.NewCloudEvent(lib.CloudEventWithTypeOption("Some.Type"))
+				e.SetSubject("existing")
+				e.SetDataContentType(cloudevents.TextPlain)
+				return e
+			}(),
+			expectedType:        "SOME.TYPE",
+			expectedSubject:     "existing",
+			expectedContentType: cloudevents.TextPlain,
+		},
+	}
+
+	for n, tc := range testCases {
+		t.Run(n, func(t *testing.T) {
+			err := enrich(&tc.event, tc.enrichment, "my-broker", receivedAt)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedType, tc.event.Type())
+			assert.Equal(t, tc.expectedSubject, tc.event.Subject())
+			assert.Equal(t, tc.expectedContentType, tc.event.DataContentType())
+			for k, v := range tc.expectedExtensions {
+				assert.Equal(t, v, tc.event.Extensions()[k])
+			}
+		})
+	}
+}
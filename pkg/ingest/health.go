@@ -0,0 +1,108 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// Health component name for the ingest server.
+	healthComponentIngest = "ingest"
+)
+
+// HealthChecks contains the result of the health check for each
+// component, indexed by component name. A nil error means healthy.
+type HealthChecks map[string]error
+
+type HealthHandler func(context.Context) HealthChecks
+
+// ComponentHealth informs about the health of a single component.
+type ComponentHealth struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// HealthResponse is the body returned by health endpoints.
+type HealthResponse struct {
+	OK         bool                       `json:"ok"`
+	Components map[string]ComponentHealth `json:"components,omitempty"`
+}
+
+func (i *Instance) healthHandler(w http.ResponseWriter, r *http.Request) {
+	var checks HealthChecks
+
+	switch r.URL.Path {
+	case "/readyz":
+		checks = i.runHealthHandler(r.Context(), i.readinessHandler)
+		checks[healthComponentIngest] = i.ingestReadiness()
+
+	// Common health paths are kept as liveness checks.
+	case "/livez", "/healthz", "/_ah/health":
+		checks = i.runHealthHandler(r.Context(), i.livenessHandler)
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	res := &HealthResponse{
+		OK:         true,
+		Components: make(map[string]ComponentHealth, len(checks)),
+	}
+
+	for name, err := range checks {
+		ch := ComponentHealth{OK: err == nil}
+		if err != nil {
+			ch.Error = err.Error()
+			res.OK = false
+			i.logger.Debugw("Health check failed", zap.String("component", name), zap.Error(err))
+		}
+		res.Components[name] = ch
+	}
+
+	b, err := json.Marshal(res)
+	if err != nil {
+		i.logger.Errorw("Could not serialize health response", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !res.OK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	if _, err := w.Write(b); err != nil {
+		i.logger.Errorw("Could not write HTTP health response", zap.Error(err))
+	}
+}
+
+func (i *Instance) runHealthHandler(ctx context.Context, h HealthHandler) HealthChecks {
+	if h == nil {
+		return HealthChecks{}
+	}
+
+	checks := h(ctx)
+	if checks == nil {
+		checks = HealthChecks{}
+	}
+	return checks
+}
+
+// ingestReadiness checks that the ingest server is receiving events.
+func (i *Instance) ingestReadiness() error {
+	i.m.RLock()
+	defer i.m.RUnlock()
+
+	if !i.receiving {
+		return errors.New("ingest server is not receiving events")
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestHealthHandler(t *testing.T) {
+	testCases := map[string]struct {
+		path      string
+		receiving bool
+		checks    HealthChecks
+
+		expectedCode     int
+		expectedResponse *HealthResponse
+	}{
+		"ready": {
+			path:         "/readyz",
+			receiving:    true,
+			checks:       HealthChecks{"backend": nil},
+			expectedCode: http.StatusOK,
+			expectedResponse: &HealthResponse{
+				OK: true,
+				Components: map[string]ComponentHealth{
+					"backend": {OK: true},
+					"ingest":  {OK: true},
+				},
+			},
+		},
+		"not ready with escaped error": {
+			path:         "/readyz",
+			receiving:    true,
+			checks:       HealthChecks{"backend": errors.New(`dial "redis": refused`)},
+			expectedCode: http.StatusServiceUnavailable,
+			expectedResponse: &HealthResponse{
+				OK: false,
+				Components: map[string]ComponentHealth{
+					"backend": {OK: false, Error: `dial "redis": refused`},
+					"ingest":  {OK: true},
+				},
+			},
+		},
+		"ingest not receiving": {
+			path:         "/readyz",
+			expectedCode: http.StatusServiceUnavailable,
+			expectedResponse: &HealthResponse{
+				OK: false,
+				Components: map[string]ComponentHealth{
+					"ingest": {OK: false, Error: "ingest server is not receiving events"},
+				},
+			},
+		},
+		"not alive": {
+			path:         "/livez",
+			checks:       HealthChecks{"dispatch": errors.New("stalled")},
+			expectedCode: http.StatusServiceUnavailable,
+			expectedResponse: &HealthResponse{
+				OK: false,
+				Components: map[string]ComponentHealth{
+					"dispatch": {OK: false, Error: "stalled"},
+				},
+			},
+		},
+		"legacy health path": {
+			path:         "/healthz",
+			expectedCode: http.StatusOK,
+			expectedResponse: &HealthResponse{
+				OK: true,
+			},
+		},
+		"not found": {
+			path:         "/other",
+			expectedCode: http.StatusNotFound,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			i := NewInstance(fakeReporter{}, zaptest.NewLogger(t).Sugar())
+			i.receiving = tc.receiving
+			h := func(context.Context) HealthChecks { return tc.checks }
+			i.RegisterReadinessHandler(h)
+			i.RegisterLivenessHandler(h)
+
+			rec := httptest.NewRecorder()
+			i.healthHandler(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			if tc.expectedResponse == nil {
+				return
+			}
+
+			res := &HealthResponse{}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), res))
+			assert.Equal(t, tc.expectedResponse, res)
+		})
+	}
+}
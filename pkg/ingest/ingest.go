@@ -5,8 +5,12 @@ package ingest
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"net/http"
+	"reflect"
+	"sync"
 	"time"
 
 	obshttp "github.com/cloudevents/sdk-go/observability/opencensus/v2/http"
@@ -14,34 +18,85 @@ import (
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
 	"github.com/cloudevents/sdk-go/v2/protocol"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
 	"go.uber.org/zap"
 
+	"github.com/triggermesh/brokers/pkg/backend"
+	"github.com/triggermesh/brokers/pkg/common/hops"
 	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
 	"github.com/triggermesh/brokers/pkg/ingest/metrics"
 	"github.com/triggermesh/brokers/pkg/status"
+	"github.com/triggermesh/brokers/pkg/subscriptions"
 )
 
 type CloudEventHandler func(context.Context, *cloudevents.Event) error
-type ProbeHandler func() error
+type CloudEventBatchHandler func(context.Context, []*cloudevents.Event) []error
+type BackendProbeHandler func(context.Context) error
+type OverloadedHandler func() bool
 
 type Instance struct {
-	port int
-
-	ceHandler    CloudEventHandler
-	probeHandler ProbeHandler
+	port       int
+	brokerName string
+
+	ceHandler      CloudEventHandler
+	ceBatchHandler CloudEventBatchHandler
+
+	// health handlers return the status of the broker components.
+	readinessHandler HealthHandler
+	livenessHandler  HealthHandler
+	receiving        bool
+
+	// backpressure handlers inform whether the backend is able
+	// to receive events.
+	backendProbeHandler BackendProbeHandler
+	overloadedHandler   OverloadedHandler
+	backendErr          error
+
+	// enrichment rules applied to incoming events, updated
+	// from the broker configuration.
+	enrichment *cfgbroker.Enrichment
+
+	// deduplicator keeps track of ingested events, deduplication is
+	// enabled when the window is greater than zero.
+	deduplicator backend.Deduplicator
+	dedupWindow  time.Duration
+
+	// rateLimiter is rebuilt only when the rate limit
+	// configuration changes.
+	rateLimiter  *rateLimiter
+	rateLimitCfg *cfgbroker.RateLimit
+
+	// schemas validate the data of incoming events, maxEventSize
+	// limits their size in bytes when greater than zero.
+	schemas      *schemaRegistry
+	maxEventSize int64
+
+	// maxBatchSize limits the size in bytes of incoming batches.
+	maxBatchSize int64
+
+	// user and password verify the identity used
+	// for rate limiting.
+	user     string
+	password string
+
+	// loop limits the hops for events received from brokers,
+	// nil when loop protection is disabled.
+	loop *cfgbroker.LoopProtection
 
 	statusManager status.Manager
 	reporter      metrics.Reporter
 	logger        *zap.SugaredLogger
+	m             sync.RWMutex
 }
 
 type InstanceOption func(*Instance)
 
 func NewInstance(reporter metrics.Reporter, logger *zap.SugaredLogger, opts ...InstanceOption) *Instance {
 	i := &Instance{
-		port:     8080,
-		logger:   logger,
-		reporter: reporter,
+		port:         8080,
+		maxBatchSize: defaultMaxBatchSize,
+		logger:       logger,
+		reporter:     reporter,
 	}
 
 	for _, opt := range opts {
@@ -57,6 +112,18 @@ func InstanceWithPort(port int) InstanceOption {
 	}
 }
 
+func InstanceWithBrokerName(name string) InstanceOption {
+	return func(i *Instance) {
+		i.brokerName = name
+	}
+}
+
+func InstanceWithDeduplicator(d backend.Deduplicator) InstanceOption {
+	return func(i *Instance) {
+		i.deduplicator = d
+	}
+}
+
 func InstanceWithStatusManager(sm status.Manager) InstanceOption {
 	return func(i *Instance) {
 		i.statusManager = sm
@@ -71,25 +138,9 @@ func (i *Instance) Start(ctx context.Context) error {
 	p, err := obshttp.NewObservedHTTP(
 		cloudevents.WithPort(i.port),
 		cloudevents.WithShutdownTimeout(10*time.Second),
-		cloudevents.WithGetHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Use common health paths.
-			if r.URL.Path != "/healthz" && r.URL.Path != "/_ah/health" {
-				w.WriteHeader(http.StatusNotFound)
-				return
-			}
-
-			if err := i.probeHandler(); err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
-				_, werr := w.Write([]byte(`{"ok":"false", "error":"` + err.Error() + `"}`))
-				i.logger.Errorw("Could not write HTTP response (not healthy)", zap.Errors("error", []error{
-					werr, err}))
-				return
-			}
-
-			if _, err := w.Write([]byte(`{"ok": "true"}`)); err != nil {
-				i.logger.Errorw("Could not write HTTP response (healthy)", zap.Error(err))
-			}
-		}),
+		cloudevents.WithMiddleware(i.batchMiddleware),
+		cloudevents.WithMiddleware(i.admissionMiddleware),
+		cloudevents.WithGetHandlerFunc(i.healthHandler),
 	)
 	if err != nil {
 		return fmt.Errorf("could not create a CloudEvents HTTP client protocol: %w", err)
@@ -101,6 +152,10 @@ func (i *Instance) Start(ctx context.Context) error {
 		return fmt.Errorf("failed to create CloudEvents client: %w", err)
 	}
 
+	if i.backendProbeHandler != nil {
+		go i.probeBackend(ctx)
+	}
+
 	i.logger.Infof("Listening on %d", i.port)
 	var handler interface{}
 
@@ -118,6 +173,16 @@ func (i *Instance) Start(ctx context.Context) error {
 		handler = i.cloudEventsHandler
 	}
 
+	i.m.Lock()
+	i.receiving = true
+	i.m.Unlock()
+
+	defer func() {
+		i.m.Lock()
+		i.receiving = false
+		i.m.Unlock()
+	}()
+
 	if err := c.StartReceiver(ctx, handler); err != nil {
 		return fmt.Errorf("unable to start HTTP server: %w", err)
 	}
@@ -127,19 +192,100 @@ func (i *Instance) Start(ctx context.Context) error {
 
 func (i *Instance) UpdateFromConfig(c *cfgbroker.Config) {
 	i.logger.Info("Ingest Server UpdateFromConfig ...")
+
+	var enrichment *cfgbroker.Enrichment
+	var dedup *cfgbroker.Deduplication
+	var rateLimit *cfgbroker.RateLimit
+	var schemas []cfgbroker.Schema
+	var maxEventSize int64
+	var user, password string
+	maxBatchSize := defaultMaxBatchSize
+	if c.Ingest != nil {
+		user, password = c.Ingest.User, c.Ingest.Password
+		enrichment = c.Ingest.Enrichment
+		dedup = c.Ingest.Deduplication
+		rateLimit = c.Ingest.RateLimit
+		schemas = c.Ingest.Schemas
+		maxEventSize = c.Ingest.MaxEventSize
+		if c.Ingest.MaxBatchSize != 0 {
+			maxBatchSize = c.Ingest.MaxBatchSize
+		}
+	}
+
+	sr, err := newSchemaRegistry(schemas)
+	if err != nil {
+		i.logger.Errorw("Could not load schemas, disabling schema validation", zap.Error(err))
+		sr = nil
+	}
+
+	window, err := dedup.GetWindow()
+	if err != nil {
+		i.logger.Errorw("Could not parse deduplication window, disabling deduplication", zap.Error(err))
+		window = 0
+	}
+
+	if window != 0 && i.deduplicator == nil {
+		i.logger.Warn("Deduplication is not supported by the backend, disabling deduplication")
+		window = 0
+	}
+
+	i.m.Lock()
+	defer i.m.Unlock()
+	i.enrichment = enrichment
+	i.dedupWindow = window
+	i.schemas = sr
+	i.maxEventSize = maxEventSize
+	i.maxBatchSize = maxBatchSize
+	i.user, i.password = user, password
+	i.loop = c.LoopProtection
+
+	// Keep the token buckets state when the rate limits did not change.
+	if !reflect.DeepEqual(rateLimit, i.rateLimitCfg) {
+		i.rateLimiter = newRateLimiter(rateLimit)
+		i.rateLimitCfg = rateLimit
+	}
 }
 
 func (i *Instance) RegisterCloudEventHandler(h CloudEventHandler) {
 	i.ceHandler = h
 }
 
-func (i *Instance) RegisterProbeHandler(h ProbeHandler) {
-	i.probeHandler = h
+func (i *Instance) RegisterCloudEventBatchHandler(h CloudEventBatchHandler) {
+	i.ceBatchHandler = h
+}
+
+// RegisterReadinessHandler sets the handler that informs whether the broker
+// components are ready, served at the /readyz path.
+func (i *Instance) RegisterReadinessHandler(h HealthHandler) {
+	i.readinessHandler = h
+}
+
+// RegisterLivenessHandler sets the handler that informs whether the broker
+// components are alive, served at the /livez and common health paths.
+func (i *Instance) RegisterLivenessHandler(h HealthHandler) {
+	i.livenessHandler = h
+}
+
+// RegisterBackendProbeHandler sets a handler that is periodically called
+// to check the backend, rejecting incoming events while it fails.
+func (i *Instance) RegisterBackendProbeHandler(h BackendProbeHandler) {
+	i.backendProbeHandler = h
+}
+
+// RegisterOverloadedHandler sets a handler that is called for each request
+// to check whether the backend is able to receive events.
+func (i *Instance) RegisterOverloadedHandler(h OverloadedHandler) {
+	i.overloadedHandler = h
 }
 
 func (i *Instance) cloudEventsStatusManagerHandler(ctx context.Context, event cloudevents.Event) (*cloudevents.Event, protocol.Result) {
 	e, p := i.cloudEventsHandler(ctx, event)
+	i.updateIngestedStatus()
+
+	return e, p
+}
 
+func (i *Instance) updateIngestedStatus() {
 	t := time.Now()
 	if i.statusManager != nil {
 		i.statusManager.UpdateIngestStatus(&status.IngestStatus{
@@ -147,8 +293,6 @@ func (i *Instance) cloudEventsStatusManagerHandler(ctx context.Context, event cl
 			LastIngested: &t,
 		})
 	}
-
-	return e, p
 }
 
 func (i *Instance) cloudEventsHandler(ctx context.Context, event cloudevents.Event) (*cloudevents.Event, protocol.Result) {
@@ -159,10 +303,118 @@ func (i *Instance) cloudEventsHandler(ctx context.Context, event cloudevents.Eve
 		return nil, protocol.ResultNACK
 	}
 
+	if err := i.validateSchema(&event); err != nil {
+		i.reporter.ReportNonValidEvent()
+		return nil, cehttp.NewResult(http.StatusBadRequest, "%v", err)
+	}
+
+	produce, dedupKey, err := i.prepareEvent(ctx, &event)
+	if err != nil {
+		return nil, protocol.NewReceipt(false, "%v", err)
+	}
+	if !produce {
+		return nil, protocol.ResultACK
+	}
+
 	if err := i.ceHandler(ctx, &event); err != nil {
 		i.logger.Errorw("Could not produce CloudEvent to broker", zap.Error(err))
+		i.forgetEvent(ctx, dedupKey)
 		return nil, protocol.ResultNACK
 	}
 
 	return nil, protocol.ResultACK
 }
+
+// validateSchema checks the event data against the schema registry.
+func (i *Instance) validateSchema(event *cloudevents.Event) error {
+	i.m.RLock()
+	sr := i.schemas
+	i.m.RUnlock()
+
+	if sr == nil {
+		return nil
+	}
+
+	return sr.validate(event)
+}
+
+// prepareEvent applies the ingest rules to an incoming event before it is
+// produced. When the event must not be produced, because it is a duplicate
+// or exceeds the hop limit, the produce return value will be false.
+//
+// The returned deduplication key must be passed to forgetEvent when the event
+// could not be produced.
+func (i *Instance) prepareEvent(ctx context.Context, event *cloudevents.Event) (produce bool, dedupKey string, err error) {
+	i.m.RLock()
+	enrichment := i.enrichment
+	dedupWindow := i.dedupWindow
+	loop := i.loop
+	i.m.RUnlock()
+
+	// Only events re-driven from the dead letter storage, which are
+	// produced directly to the backend, are routed to a single trigger.
+	if _, ok := event.Extensions()[subscriptions.RedriveExtension]; ok {
+		i.logger.Debugw("Removing re-drive extension from ingested CloudEvent",
+			zap.String("source", event.Source()), zap.String("id", event.ID()))
+		event.SetExtension(subscriptions.RedriveExtension, nil)
+	}
+
+	// The list of triggers is informed by this broker's subscribers, names
+	// received from clients or other brokers are not kept.
+	if _, ok := event.Extensions()[hops.TriggersExtension]; ok {
+		event.SetExtension(hops.TriggersExtension, nil)
+	}
+
+	if loop != nil {
+		exceeded, err := i.countHop(event, loop)
+		if err != nil {
+			return false, "", err
+		}
+		if exceeded {
+			return false, "", nil
+		}
+	}
+
+	if err := enrich(event, enrichment, i.brokerName, time.Now()); err != nil {
+		i.logger.Errorw("Could not apply enrichment rules to CloudEvent", zap.Error(err))
+		return false, "", fmt.Errorf("could not apply enrichment rules: %w", err)
+	}
+
+	if dedupWindow == 0 {
+		return true, "", nil
+	}
+
+	dedupKey = deduplicationKey(event)
+	seen, err := i.deduplicator.Seen(ctx, dedupKey, dedupWindow)
+	switch {
+	case err != nil:
+		// Do not block ingestion when the deduplication store fails.
+		i.logger.Errorw("Could not check CloudEvent for duplicates", zap.Error(err))
+		return true, "", nil
+	case seen:
+		i.logger.Debugw("Skipping duplicated CloudEvent",
+			zap.String("source", event.Source()), zap.String("id", event.ID()))
+		i.reporter.ReportDuplicatedEvent()
+		return false, "", nil
+	}
+
+	return true, dedupKey, nil
+}
+
+// forgetEvent removes the event from the deduplication store so that
+// producers can retry it.
+func (i *Instance) forgetEvent(ctx context.Context, dedupKey string) {
+	if dedupKey == "" {
+		return
+	}
+
+	if err := i.deduplicator.Forget(ctx, dedupKey); err != nil {
+		i.logger.Errorw("Could not remove CloudEvent from deduplication store", zap.Error(err))
+	}
+}
+
+// deduplicationKey identifies an event by its source and id.
+func deduplicationKey(event *cloudevents.Event) string {
+	h := sha256.Sum256([]byte(event.Source() + "\x00" + event.ID()))
+	return hex.EncodeToString(h[:])
+}
@@ -0,0 +1,33 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"context"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/subscriptions"
+)
+
+func TestPrepareEventRedrive(t *testing.T) {
+	i := NewInstance(fakeReporter{}, zaptest.NewLogger(t).Sugar())
+	i.UpdateFromConfig(&cfgbroker.Config{})
+
+	event := cloudevents.NewEvent()
+	event.SetID("1")
+	event.SetType("test.type")
+	event.SetSource("test.source")
+	event.SetExtension(subscriptions.RedriveExtension, "trigger1")
+
+	produce, _, err := i.prepareEvent(context.Background(), &event)
+	require.NoError(t, err)
+	assert.True(t, produce)
+	assert.NotContains(t, event.Extensions(), subscriptions.RedriveExtension, "Producers must not route events to a trigger")
+}
@@ -0,0 +1,41 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"fmt"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/common/hops"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// countHop increments the hop count of events received from brokers,
+// returning whether the event exceeds the hop limit. Events that do not
+// inform the hop count are not modified.
+func (i *Instance) countHop(event *cloudevents.Event, loop *cfgbroker.LoopProtection) (bool, error) {
+	n, ok, err := hops.Count(event)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, nil
+	}
+
+	n++
+	if n > loop.MaxHops {
+		i.logger.Warnw("Event loop detected, dropping CloudEvent", zap.Int("maxHops", loop.MaxHops),
+			zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
+		i.reporter.ReportLoopDetected()
+		return true, nil
+	}
+
+	if err := hops.Set(event, n, nil); err != nil {
+		return false, fmt.Errorf("could not inform hops: %w", err)
+	}
+
+	return false, nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"context"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/triggermesh/brokers/pkg/common/hops"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+func TestLoopProtection(t *testing.T) {
+	testCases := map[string]struct {
+		hops interface{}
+
+		expectedProduce bool
+		expectedHops    *int
+		expectedErr     bool
+	}{
+		"not from a broker": {
+			expectedProduce: true,
+		},
+		"under the limit": {
+			hops:            1,
+			expectedProduce: true,
+			expectedHops:    intPtr(2),
+		},
+		"over the limit": {
+			hops:            2,
+			expectedProduce: false,
+		},
+		"not valid hops": {
+			hops:        "many",
+			expectedErr: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			i := NewInstance(fakeReporter{}, zaptest.NewLogger(t).Sugar())
+			i.UpdateFromConfig(&cfgbroker.Config{
+				LoopProtection: &cfgbroker.LoopProtection{MaxHops: 2},
+			})
+
+			event := cloudevents.NewEvent()
+			event.SetID("1")
+			event.SetType("test.type")
+			event.SetSource("test.source")
+			event.SetExtension(hops.TriggersExtension, "t1,t2")
+			if tc.hops != nil {
+				event.SetExtension(hops.CountExtension, tc.hops)
+			}
+
+			produce, _, err := i.prepareEvent(context.Background(), &event)
+			if tc.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedProduce, produce)
+
+			if tc.expectedHops != nil {
+				n, _, err := hops.Count(&event)
+				require.NoError(t, err)
+				assert.Equal(t, *tc.expectedHops, n)
+			}
+			assert.Empty(t, hops.Triggers(&event), "Received triggers were not removed")
+		})
+	}
+}
+
+func intPtr(i int) *int {
+	return &i
+}
@@ -48,6 +48,30 @@ var (
 		"Number of requests rejected by the Broker ingestion.",
 		stats.UnitDimensionless,
 	)
+
+	// duplicatedCountM is a counter which records the number of events
+	// that were dropped because they had already been ingested.
+	duplicatedCountM = stats.Int64(
+		"ingest/duplicated_count",
+		"Number of duplicated events dropped by the Broker ingestion.",
+		stats.UnitDimensionless,
+	)
+
+	// throttledCountM is a counter which records the number of events
+	// that were rejected due to rate limits or backpressure.
+	throttledCountM = stats.Int64(
+		"ingest/throttled_count",
+		"Number of events rejected by the Broker ingestion due to rate limits or backpressure.",
+		stats.UnitDimensionless,
+	)
+
+	// loopCountM is a counter which records the number of events
+	// that were dropped because they exceeded the hop limit.
+	loopCountM = stats.Int64(
+		"ingest/loop_count",
+		"Number of events dropped by the Broker ingestion due to the hop limit.",
+		stats.UnitDimensionless,
+	)
 )
 
 func registerStatViews() error {
@@ -78,12 +102,36 @@ func registerStatViews() error {
 			Aggregation: view.Count(),
 			TagKeys:     []tag.Key{},
 		},
+		&view.View{
+			Name:        duplicatedCountM.Name(),
+			Description: duplicatedCountM.Description(),
+			Measure:     duplicatedCountM,
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{},
+		},
+		&view.View{
+			Name:        throttledCountM.Name(),
+			Description: throttledCountM.Description(),
+			Measure:     throttledCountM,
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{},
+		},
+		&view.View{
+			Name:        loopCountM.Name(),
+			Description: loopCountM.Description(),
+			Measure:     loopCountM,
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{},
+		},
 	)
 }
 
 type Reporter interface {
 	ReportProcessedEvent(ingested bool, eventType string, msLatency float64)
 	ReportNonValidEvent()
+	ReportDuplicatedEvent()
+	ReportThrottledEvent()
+	ReportLoopDetected()
 }
 
 // Reporter holds cached metric objects to report ingress metrics.
@@ -131,3 +179,15 @@ func (r *reporter) ReportProcessedEvent(ingested bool, eventType string, msLaten
 func (r *reporter) ReportNonValidEvent() {
 	knmetrics.Record(r.ctx, rejectedCountM.M(1))
 }
+
+func (r *reporter) ReportDuplicatedEvent() {
+	knmetrics.Record(r.ctx, duplicatedCountM.M(1))
+}
+
+func (r *reporter) ReportThrottledEvent() {
+	knmetrics.Record(r.ctx, throttledCountM.M(1))
+}
+
+func (r *reporter) ReportLoopDetected() {
+	knmetrics.Record(r.ctx, loopCountM.M(1))
+}
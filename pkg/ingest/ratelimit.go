@@ -0,0 +1,168 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+const (
+	// Keyed limiters that have not been used for this period
+	// are removed.
+	keyedLimiterExpiry = 10 * time.Minute
+
+	// Maximum number of keyed limiters, when reached the least
+	// recently used limiter is removed.
+	keyedLimiterMaxKeys = 10000
+)
+
+// rateLimiter applies token bucket limits to incoming events.
+type rateLimiter struct {
+	global      *rate.Limiter
+	perSource   *keyedLimiter
+	perIdentity *keyedLimiter
+}
+
+func newRateLimiter(cfg *cfgbroker.RateLimit) *rateLimiter {
+	if cfg == nil || (cfg.Global == nil && cfg.PerSource == nil && cfg.PerIdentity == nil) {
+		return nil
+	}
+
+	rl := &rateLimiter{
+		perSource:   newKeyedLimiter(cfg.PerSource),
+		perIdentity: newKeyedLimiter(cfg.PerIdentity),
+	}
+
+	if cfg.Global != nil {
+		rl.global = rate.NewLimiter(rate.Limit(cfg.Global.EventsPerSecond), cfg.Global.GetBurst())
+	}
+
+	return rl
+}
+
+// allow consumes a token from each of the limiters that apply to the
+// event. When any of the limits is exceeded no tokens are consumed and
+// the time to wait before retrying is returned.
+func (rl *rateLimiter) allow(identity, source string, now time.Time) (bool, time.Duration) {
+	limiters := make([]*rate.Limiter, 0, 3)
+	if rl.global != nil {
+		limiters = append(limiters, rl.global)
+	}
+	if l := rl.perIdentity.get(identity, now); l != nil {
+		limiters = append(limiters, l)
+	}
+	if l := rl.perSource.get(source, now); l != nil {
+		limiters = append(limiters, l)
+	}
+
+	reservations := make([]*rate.Reservation, 0, len(limiters))
+	var wait time.Duration
+	for _, l := range limiters {
+		r := l.ReserveN(now, 1)
+		reservations = append(reservations, r)
+
+		if !r.OK() {
+			// The burst does not allow a single event, use the
+			// refill period as a hint for the client.
+			if d := time.Duration(float64(time.Second) / float64(l.Limit())); d > wait {
+				wait = d
+			}
+			continue
+		}
+
+		if d := r.DelayFrom(now); d > wait {
+			wait = d
+		}
+	}
+
+	if wait == 0 {
+		return true, 0
+	}
+
+	for _, r := range reservations {
+		r.CancelAt(now)
+	}
+
+	return false, wait
+}
+
+// keyedLimiter keeps a token bucket per key.
+type keyedLimiter struct {
+	limit rate.Limit
+	burst int
+
+	limiters  map[string]*keyedLimiterEntry
+	maxKeys   int
+	lastSweep time.Time
+	m         sync.Mutex
+}
+
+type keyedLimiterEntry struct {
+	limiter  *rate.Limiter
+	lastUsed time.Time
+}
+
+func newKeyedLimiter(rule *cfgbroker.RateLimitRule) *keyedLimiter {
+	if rule == nil {
+		return nil
+	}
+
+	return &keyedLimiter{
+		limit:    rate.Limit(rule.EventsPerSecond),
+		burst:    rule.GetBurst(),
+		limiters: make(map[string]*keyedLimiterEntry),
+		maxKeys:  keyedLimiterMaxKeys,
+	}
+}
+
+// get returns the limiter for the key, creating it if it does not exist.
+// Empty keys are not limited.
+func (kl *keyedLimiter) get(key string, now time.Time) *rate.Limiter {
+	if kl == nil || key == "" {
+		return nil
+	}
+
+	kl.m.Lock()
+	defer kl.m.Unlock()
+
+	if now.Sub(kl.lastSweep) > keyedLimiterExpiry {
+		for k, e := range kl.limiters {
+			if now.Sub(e.lastUsed) > keyedLimiterExpiry {
+				delete(kl.limiters, k)
+			}
+		}
+		kl.lastSweep = now
+	}
+
+	e, ok := kl.limiters[key]
+	if !ok {
+		if len(kl.limiters) >= kl.maxKeys {
+			kl.evictOldest()
+		}
+		e = &keyedLimiterEntry{
+			limiter: rate.NewLimiter(kl.limit, kl.burst),
+		}
+		kl.limiters[key] = e
+	}
+	e.lastUsed = now
+
+	return e.limiter
+}
+
+// evictOldest removes the least recently used limiter.
+func (kl *keyedLimiter) evictOldest() {
+	var oldest string
+	var lastUsed time.Time
+	for k, e := range kl.limiters {
+		if lastUsed.IsZero() || e.lastUsed.Before(lastUsed) {
+			oldest, lastUsed = k, e.lastUsed
+		}
+	}
+	delete(kl.limiters, oldest)
+}
@@ -0,0 +1,180 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zaptest"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+func TestRateLimiter(t *testing.T) {
+	burst := 2
+	now := time.Now()
+
+	rl := newRateLimiter(&cfgbroker.RateLimit{
+		Global:    &cfgbroker.RateLimitRule{EventsPerSecond: 10},
+		PerSource: &cfgbroker.RateLimitRule{EventsPerSecond: 1, Burst: &burst},
+	})
+
+	for n := 0; n < burst; n++ {
+		ok, _ := rl.allow("", "source1", now)
+		assert.True(t, ok, "event %d should be allowed", n)
+	}
+
+	ok, wait := rl.allow("", "source1", now)
+	assert.False(t, ok, "source limit should be exceeded")
+	assert.Equal(t, time.Second, wait)
+
+	// Rejected events must not consume tokens from other limiters.
+	for n := 0; n < 8; n++ {
+		ok, _ := rl.allow("", "source"+string(rune('a'+n)), now)
+		assert.True(t, ok, "event %d for a new source should be allowed", n)
+	}
+
+	ok, _ = rl.allow("", "source2", now)
+	assert.False(t, ok, "global limit should be exceeded")
+
+	ok, _ = rl.allow("", "source1", now.Add(time.Second))
+	assert.True(t, ok, "tokens should be refilled")
+}
+
+func TestKeyedLimiterMaxKeys(t *testing.T) {
+	now := time.Now()
+	kl := newKeyedLimiter(&cfgbroker.RateLimitRule{EventsPerSecond: 1})
+	kl.maxKeys = 2
+
+	kl.get("a", now)
+	kl.get("b", now.Add(time.Millisecond))
+	kl.get("a", now.Add(2*time.Millisecond))
+	kl.get("c", now.Add(3*time.Millisecond))
+
+	assert.Len(t, kl.limiters, 2)
+	assert.NotContains(t, kl.limiters, "b", "Least recently used limiter was not removed")
+}
+
+func TestRequestIdentity(t *testing.T) {
+	i := NewInstance(fakeReporter{}, zaptest.NewLogger(t).Sugar())
+	i.UpdateFromConfig(&cfgbroker.Config{
+		Ingest: &cfgbroker.Ingest{User: "user", Password: "s3cr3t"},
+	})
+
+	testCases := map[string]struct {
+		user     string
+		password string
+
+		expectedIdentity string
+	}{
+		"verified user": {
+			user:             "user",
+			password:         "s3cr3t",
+			expectedIdentity: "user:user",
+		},
+		"wrong password": {
+			user:             "user",
+			password:         "guess",
+			expectedIdentity: "addr:192.0.2.1",
+		},
+		"unknown user": {
+			user:             "random",
+			password:         "s3cr3t",
+			expectedIdentity: "addr:192.0.2.1",
+		},
+		"no credentials": {
+			expectedIdentity: "addr:192.0.2.1",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			if tc.user != "" {
+				req.SetBasicAuth(tc.user, tc.password)
+			}
+
+			assert.Equal(t, tc.expectedIdentity, i.requestIdentity(req))
+		})
+	}
+}
+
+func TestAdmissionMiddleware(t *testing.T) {
+	burst := 1
+
+	testCases := map[string]struct {
+		overloaded   bool
+		backendErr   error
+		maxEventSize int64
+		requests     int
+
+		expectedCode       int
+		expectedRetryAfter string
+	}{
+		"accepted": {
+			requests:     1,
+			expectedCode: http.StatusOK,
+		},
+		"rate limited": {
+			requests:           2,
+			expectedCode:       http.StatusTooManyRequests,
+			expectedRetryAfter: "1",
+		},
+		"event too large": {
+			maxEventSize: 10,
+			requests:     1,
+			expectedCode: http.StatusRequestEntityTooLarge,
+		},
+		"backend overloaded": {
+			overloaded:         true,
+			requests:           1,
+			expectedCode:       http.StatusServiceUnavailable,
+			expectedRetryAfter: "5",
+		},
+		"backend not available": {
+			backendErr:         errors.New("connection refused"),
+			requests:           1,
+			expectedCode:       http.StatusServiceUnavailable,
+			expectedRetryAfter: "5",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			i := NewInstance(fakeReporter{}, zaptest.NewLogger(t).Sugar())
+			i.RegisterOverloadedHandler(func() bool { return tc.overloaded })
+			i.backendErr = tc.backendErr
+			i.UpdateFromConfig(&cfgbroker.Config{
+				Ingest: &cfgbroker.Ingest{
+					MaxEventSize: tc.maxEventSize,
+					RateLimit: &cfgbroker.RateLimit{
+						PerSource: &cfgbroker.RateLimitRule{EventsPerSecond: 1, Burst: &burst},
+					},
+				},
+			})
+
+			h := i.admissionMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			var rec *httptest.ResponseRecorder
+			for n := 0; n < tc.requests; n++ {
+				req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(
+					`{"specversion":"1.0","id":"1","source":"s","type":"t"}`))
+				req.Header.Set("Content-Type", structuredContentType)
+				rec = httptest.NewRecorder()
+				h.ServeHTTP(rec, req)
+			}
+
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			assert.Equal(t, tc.expectedRetryAfter, rec.Header().Get("Retry-After"))
+		})
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// schemaRegistry keeps the compiled JSON Schemas indexed by
+// the CloudEvents attribute they apply to.
+type schemaRegistry struct {
+	byType       map[string]*jsonschema.Schema
+	byDataSchema map[string]*jsonschema.Schema
+}
+
+func newSchemaRegistry(schemas []cfgbroker.Schema) (*schemaRegistry, error) {
+	if len(schemas) == 0 {
+		return nil, nil
+	}
+
+	sr := &schemaRegistry{
+		byType:       make(map[string]*jsonschema.Schema),
+		byDataSchema: make(map[string]*jsonschema.Schema),
+	}
+
+	for n := range schemas {
+		s, err := schemas[n].Compile()
+		if err != nil {
+			return nil, fmt.Errorf("could not compile schema at position %d: %w", n, err)
+		}
+
+		if schemas[n].DataSchema != "" {
+			sr.byDataSchema[schemas[n].DataSchema] = s
+		} else {
+			sr.byType[schemas[n].Type] = s
+		}
+	}
+
+	return sr, nil
+}
+
+// validate checks the event data against the schema registered for the
+// event's dataschema, or for its type if there is none. Events without
+// a matching schema are not validated.
+func (sr *schemaRegistry) validate(event *cloudevents.Event) error {
+	s, ok := sr.byDataSchema[event.DataSchema()]
+	if !ok || event.DataSchema() == "" {
+		if s, ok = sr.byType[event.Type()]; !ok {
+			return nil
+		}
+	}
+
+	var data interface{}
+	if len(event.Data()) != 0 {
+		d := json.NewDecoder(bytes.NewReader(event.Data()))
+		d.UseNumber()
+		if err := d.Decode(&data); err != nil {
+			return fmt.Errorf("event data is not JSON: %w", err)
+		}
+	}
+
+	err := s.Validate(data)
+	if err == nil {
+		return nil
+	}
+
+	verr := &jsonschema.ValidationError{}
+	if !errors.As(err, &verr) {
+		return err
+	}
+
+	msgs := []string{}
+	for _, e := range verr.BasicOutput().Errors {
+		// Skip the wrapping errors that do not inform a cause.
+		if e.Error == "" || strings.HasPrefix(e.Error, "doesn't validate with") {
+			continue
+		}
+		msgs = append(msgs, fmt.Sprintf("%s: %s", locationOrRoot(e.InstanceLocation), e.Error))
+	}
+
+	if len(msgs) == 0 {
+		return fmt.Errorf("event data does not match schema: %w", err)
+	}
+
+	return fmt.Errorf("event data does not match schema: %s", strings.Join(msgs, "; "))
+}
+
+func locationOrRoot(location string) string {
+	if location == "" {
+		return "/"
+	}
+	return location
+}
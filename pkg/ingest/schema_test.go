@@ -0,0 +1,84 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+const orderSchema = `{
+	"type": "object",
+	"properties": {
+		"id": {"type": "string"},
+		"amount": {"type": "number", "minimum": 0}
+	},
+	"required": ["id"]
+}`
+
+func TestSchemaRegistry(t *testing.T) {
+	sr, err := newSchemaRegistry([]cfgbroker.Schema{
+		{Type: "order.created", Schema: []byte(orderSchema)},
+		{DataSchema: "https://example.com/order.json", Schema: []byte(orderSchema)},
+	})
+	require.NoError(t, err)
+
+	testCases := map[string]struct {
+		eventType  string
+		dataSchema string
+		data       string
+
+		expectedErr string
+	}{
+		"valid by type": {
+			eventType: "order.created",
+			data:      `{"id":"1","amount":12.5}`,
+		},
+		"no matching schema": {
+			eventType: "order.deleted",
+			data:      `{"amount":-1}`,
+		},
+		"missing required property": {
+			eventType:   "order.created",
+			data:        `{"amount":1}`,
+			expectedErr: "missing properties: 'id'",
+		},
+		"invalid by dataschema": {
+			eventType:   "other.type",
+			dataSchema:  "https://example.com/order.json",
+			data:        `{"id":"1","amount":-1}`,
+			expectedErr: "/amount: must be >= 0 but found -1",
+		},
+		"not JSON": {
+			eventType:   "order.created",
+			data:        `id=1`,
+			expectedErr: "event data is not JSON",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			e := cloudevents.NewEvent()
+			e.SetID("1")
+			e.SetSource("test")
+			e.SetType(tc.eventType)
+			e.SetDataSchema(tc.dataSchema)
+			e.DataEncoded = []byte(tc.data)
+
+			err := sr.validate(&e)
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.expectedErr)
+		})
+	}
+}
@@ -24,6 +24,17 @@ const (
 	SubscriptionStatusComplete SubscriptionStatusChoice = "Complete"
 )
 
+type CircuitBreakerStateChoice string
+
+const (
+	// Events are delivered to the target.
+	CircuitBreakerStateClosed CircuitBreakerStateChoice = "Closed"
+	// Consumption is paused after consecutive delivery failures.
+	CircuitBreakerStateOpen CircuitBreakerStateChoice = "Open"
+	// An event is being sent to probe the target.
+	CircuitBreakerStateHalfOpen CircuitBreakerStateChoice = "HalfOpen"
+)
+
 type IngestStatusChoice string
 
 const (
@@ -128,6 +128,9 @@ type SubscriptionStatus struct {
 	Status  SubscriptionStatusChoice `json:"status"`
 	Message *string                  `json:"message,omitempty"`
 
+	// CircuitBreaker state for subscriptions that configure it.
+	CircuitBreaker CircuitBreakerStateChoice `json:"circuitBreaker,omitempty"`
+
 	LastProcessed *time.Time `json:"lastProcessed,omitempty"`
 }
 
@@ -148,6 +151,10 @@ func (ss *SubscriptionStatus) Merge(in *SubscriptionStatus) {
 		ss.Status = in.Status
 	}
 
+	if ss.CircuitBreaker == "" && in.CircuitBreaker != "" {
+		ss.CircuitBreaker = in.CircuitBreaker
+	}
+
 	// Message is merged only if the status does not change
 	if ss.Message == nil && in.Message != nil && ss.Status == in.Status {
 		ss.Message = in.Message
@@ -161,7 +168,7 @@ func (ss *SubscriptionStatus) EqualSoftStatus(in *SubscriptionStatus) bool {
 		return false
 	}
 
-	return ss.Status == in.Status
+	return ss.Status == in.Status && ss.CircuitBreaker == in.CircuitBreaker
 }
 
 func (ss *SubscriptionStatus) EqualStatus(in *SubscriptionStatus) bool {
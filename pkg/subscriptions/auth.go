@@ -0,0 +1,208 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// tokenRequestTimeout bounds OAuth2 token requests, which
+// are performed while dispatching events.
+const tokenRequestTimeout = 30 * time.Second
+
+// secret returns a value that is either informed inline or read
+// from a file, which is read again when it is modified.
+type secret struct {
+	value string
+	file  *watchedFile
+}
+
+func newSecret(sv *cfgbroker.SecretValue) *secret {
+	s := &secret{}
+	switch {
+	case sv.File != nil:
+		s.file = &watchedFile{path: *sv.File}
+	case sv.Value != nil:
+		s.value = *sv.Value
+	}
+	return s
+}
+
+func (s *secret) get() (string, error) {
+	if s.file == nil {
+		return s.value, nil
+	}
+
+	b, _, err := s.file.read()
+	if err != nil {
+		return "", fmt.Errorf("could not read secret file: %w", err)
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
+
+// targetAuth builds the headers that authenticate requests
+// sent to the target.
+type targetAuth struct {
+	headers map[string]*secret
+
+	basicUser     string
+	basicPassword *secret
+	bearer        *secret
+	tokenSource   oauth2.TokenSource
+
+	// token is the cached OAuth2 token, requested again
+	// when it expires or the target rejects it.
+	token *oauth2.Token
+	m     sync.Mutex
+}
+
+func newTargetAuth(ctx context.Context, a *cfgbroker.TargetAuth) *targetAuth {
+	if a == nil {
+		return nil
+	}
+
+	ta := &targetAuth{
+		headers: make(map[string]*secret, len(a.Headers)),
+	}
+
+	for k, v := range a.Headers {
+		v := v
+		ta.headers[k] = newSecret(&v)
+	}
+
+	switch {
+	case a.Basic != nil:
+		ta.basicUser = a.Basic.User
+		ta.basicPassword = newSecret(&a.Basic.Password)
+	case a.Bearer != nil:
+		ta.bearer = newSecret(a.Bearer)
+	case a.OAuth2 != nil:
+		ta.tokenSource = &clientCredentialsSource{
+			ctx:          ctx,
+			timeout:      tokenRequestTimeout,
+			cfg:          a.OAuth2,
+			clientSecret: newSecret(&a.OAuth2.ClientSecret),
+		}
+	}
+
+	return ta
+}
+
+// header returns the headers to be added to the request.
+func (ta *targetAuth) header() (http.Header, error) {
+	h := make(http.Header, len(ta.headers)+1)
+	for k, s := range ta.headers {
+		v, err := s.get()
+		if err != nil {
+			return nil, fmt.Errorf("could not read value for header %q: %w", k, err)
+		}
+		h.Set(k, v)
+	}
+
+	switch {
+	case ta.basicPassword != nil:
+		p, err := ta.basicPassword.get()
+		if err != nil {
+			return nil, fmt.Errorf("could not read basic auth password: %w", err)
+		}
+		r := &http.Request{Header: h}
+		r.SetBasicAuth(ta.basicUser, p)
+
+	case ta.bearer != nil:
+		t, err := ta.bearer.get()
+		if err != nil {
+			return nil, fmt.Errorf("could not read bearer token: %w", err)
+		}
+		h.Set("Authorization", "Bearer "+t)
+
+	case ta.tokenSource != nil:
+		t, err := ta.oauth2Token()
+		if err != nil {
+			return nil, fmt.Errorf("could not retrieve OAuth2 token: %w", err)
+		}
+		t.SetAuthHeader(&http.Request{Header: h})
+	}
+
+	return h, nil
+}
+
+// oauth2Token returns the cached token, requesting a new one when
+// there is none or it has expired.
+func (ta *targetAuth) oauth2Token() (*oauth2.Token, error) {
+	ta.m.Lock()
+	defer ta.m.Unlock()
+
+	if ta.token.Valid() {
+		return ta.token, nil
+	}
+
+	t, err := ta.tokenSource.Token()
+	if err != nil {
+		return nil, err
+	}
+	ta.token = t
+
+	return t, nil
+}
+
+// invalidate discards the cached OAuth2 token when it was sent at
+// the headers of a request that the target rejected.
+func (ta *targetAuth) invalidate(h http.Header) {
+	if ta.tokenSource == nil {
+		return
+	}
+
+	ta.m.Lock()
+	defer ta.m.Unlock()
+
+	if ta.token != nil && h.Get("Authorization") == ta.token.Type()+" "+ta.token.AccessToken {
+		ta.token = nil
+	}
+}
+
+// clientCredentialsSource requests tokens using the client credentials flow,
+// reading the client secret for each request so that it can be rotated.
+type clientCredentialsSource struct {
+	ctx          context.Context
+	timeout      time.Duration
+	cfg          *cfgbroker.OAuth2ClientCredentials
+	clientSecret *secret
+}
+
+func (c *clientCredentialsSource) Token() (*oauth2.Token, error) {
+	cs, err := c.clientSecret.get()
+	if err != nil {
+		return nil, fmt.Errorf("could not read client secret: %w", err)
+	}
+
+	cfg := &clientcredentials.Config{
+		ClientID:     c.cfg.ClientID,
+		ClientSecret: cs,
+		TokenURL:     c.cfg.TokenURL,
+		Scopes:       c.cfg.Scopes,
+	}
+
+	if len(c.cfg.EndpointParams) != 0 {
+		cfg.EndpointParams = make(map[string][]string, len(c.cfg.EndpointParams))
+		for k, v := range c.cfg.EndpointParams {
+			cfg.EndpointParams.Set(k, v)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeout)
+	defer cancel()
+
+	return cfg.Token(ctx)
+}
@@ -0,0 +1,275 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+func TestTargetAuthHeader(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("s3cr3t\n"), 0o600))
+
+	testCases := map[string]struct {
+		auth *cfgbroker.TargetAuth
+
+		expectedHeader http.Header
+	}{
+		"static headers": {
+			auth: &cfgbroker.TargetAuth{
+				Headers: map[string]cfgbroker.SecretValue{
+					"X-Static":  {Value: strPtr("value")},
+					"X-Api-Key": {File: &tokenFile},
+				},
+			},
+			expectedHeader: http.Header{
+				"X-Static":  []string{"value"},
+				"X-Api-Key": []string{"s3cr3t"},
+			},
+		},
+		"basic": {
+			auth: &cfgbroker.TargetAuth{
+				Basic: &cfgbroker.BasicAuth{
+					User:     "user",
+					Password: cfgbroker.SecretValue{File: &tokenFile},
+				},
+			},
+			expectedHeader: http.Header{
+				"Authorization": []string{"Basic dXNlcjpzM2NyM3Q="},
+			},
+		},
+		"bearer": {
+			auth: &cfgbroker.TargetAuth{
+				Bearer: &cfgbroker.SecretValue{File: &tokenFile},
+			},
+			expectedHeader: http.Header{
+				"Authorization": []string{"Bearer s3cr3t"},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			h, err := newTargetAuth(context.Background(), tc.auth).header()
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedHeader, h)
+		})
+	}
+}
+
+func TestSecretFileRotation(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, os.WriteFile(file, []byte("first"), 0o600))
+
+	s := newSecret(&cfgbroker.SecretValue{File: &file})
+	v, err := s.get()
+	require.NoError(t, err)
+	assert.Equal(t, "first", v)
+
+	require.NoError(t, os.WriteFile(file, []byte("second"), 0o600))
+	// Make sure the modification time changes regardless of
+	// the file system time resolution.
+	mt := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(file, mt, mt))
+
+	v, err = s.get()
+	require.NoError(t, err)
+	assert.Equal(t, "second", v)
+}
+
+func TestTargetAuthOAuth2(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "client_credentials", r.Form.Get("grant_type"))
+		assert.Equal(t, "https://target.example.com", r.Form.Get("audience"))
+
+		id, secret, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "client", id)
+		assert.Equal(t, "s3cr3t", secret)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"t0k3n","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer srv.Close()
+
+	ta := newTargetAuth(context.Background(), &cfgbroker.TargetAuth{
+		OAuth2: &cfgbroker.OAuth2ClientCredentials{
+			TokenURL:       srv.URL,
+			ClientID:       "client",
+			ClientSecret:   cfgbroker.SecretValue{Value: strPtr("s3cr3t")},
+			EndpointParams: map[string]string{"audience": "https://target.example.com"},
+		},
+	})
+
+	for n := 0; n < 2; n++ {
+		h, err := ta.header()
+		require.NoError(t, err)
+		assert.Equal(t, "Bearer t0k3n", h.Get("Authorization"))
+	}
+
+	assert.Equal(t, 1, requests, "token should be cached")
+}
+
+func TestTargetAuthOAuth2Timeout(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer srv.Close()
+	defer close(done)
+
+	ccs := &clientCredentialsSource{
+		ctx:          context.Background(),
+		timeout:      50 * time.Millisecond,
+		cfg:          &cfgbroker.OAuth2ClientCredentials{TokenURL: srv.URL, ClientID: "client"},
+		clientSecret: newSecret(&cfgbroker.SecretValue{Value: strPtr("s3cr3t")}),
+	}
+
+	_, err := ccs.Token()
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestTargetAuthUnchanged(t *testing.T) {
+	s := &subscriber{
+		name:      "test",
+		parentCtx: context.Background(),
+		logger:    zaptest.NewLogger(t).Sugar(),
+	}
+
+	url, newURL := "http://localhost:8080", "http://localhost:9090"
+	auth := &cfgbroker.TargetAuth{Bearer: &cfgbroker.SecretValue{Value: strPtr("t0k3n")}}
+
+	require.NoError(t, s.updateTrigger(cfgbroker.Trigger{Target: cfgbroker.Target{URL: &url, Auth: auth}}))
+	ta := s.auth
+
+	require.NoError(t, s.updateTrigger(cfgbroker.Trigger{Target: cfgbroker.Target{URL: &newURL, Auth: auth}}))
+	assert.Same(t, ta, s.auth, "Authentication replaced without changes")
+
+	require.NoError(t, s.updateTrigger(cfgbroker.Trigger{Target: cfgbroker.Target{URL: &newURL}}))
+	assert.Nil(t, s.auth, "Authentication not removed")
+}
+
+func TestTargetAuthOAuth2Invalidate(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"access_token":"t0k3n%d","token_type":"Bearer","expires_in":3600}`, requests)
+	}))
+	defer srv.Close()
+
+	ta := newTargetAuth(context.Background(), &cfgbroker.TargetAuth{
+		OAuth2: &cfgbroker.OAuth2ClientCredentials{
+			TokenURL:     srv.URL,
+			ClientID:     "client",
+			ClientSecret: cfgbroker.SecretValue{Value: strPtr("s3cr3t")},
+		},
+	})
+
+	h, err := ta.header()
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer t0k3n1", h.Get("Authorization"))
+
+	// Rejected headers that do not contain the cached token are ignored.
+	ta.invalidate(http.Header{"Authorization": []string{"Bearer other"}})
+	h, err = ta.header()
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer t0k3n1", h.Get("Authorization"))
+
+	ta.invalidate(h)
+	h, err = ta.header()
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer t0k3n2", h.Get("Authorization"), "Rejected token was not discarded")
+	assert.Equal(t, 2, requests)
+}
+
+func TestTargetAuthTokenRequestUnlocked(t *testing.T) {
+	requested := make(chan struct{}, 1)
+	release := make(chan struct{})
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested <- struct{}{}
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"t0k3n","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer tokenSrv.Close()
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer target.Close()
+
+	client, err := cloudevents.NewClientHTTP()
+	require.NoError(t, err)
+
+	s := &subscriber{
+		name:      "test",
+		ceClient:  client,
+		parentCtx: context.Background(),
+		logger:    zaptest.NewLogger(t).Sugar(),
+	}
+
+	trigger := cfgbroker.Trigger{Target: cfgbroker.Target{
+		URL: &target.URL,
+		Auth: &cfgbroker.TargetAuth{OAuth2: &cfgbroker.OAuth2ClientCredentials{
+			TokenURL:     tokenSrv.URL,
+			ClientID:     "client",
+			ClientSecret: cfgbroker.SecretValue{Value: strPtr("s3cr3t")},
+		}},
+	}}
+	require.NoError(t, s.updateTrigger(trigger))
+
+	event := cloudevents.NewEvent()
+	event.SetID("1")
+	event.SetType("test.type")
+	event.SetSource("test.source")
+
+	dispatched := make(chan struct{})
+	go func() {
+		defer close(dispatched)
+		s.dispatchCloudEvent(&event)
+	}()
+
+	select {
+	case <-requested:
+	case <-time.After(time.Second):
+		t.Fatal("Token was not requested")
+	}
+
+	updated := make(chan error)
+	go func() {
+		updated <- s.updateTrigger(trigger)
+	}()
+
+	select {
+	case err := <-updated:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Error("Trigger update blocked by the token request")
+	}
+
+	close(release)
+	<-dispatched
+}
+
+func strPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,211 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rickb777/date/period"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/status"
+)
+
+// defaultProbePeriod is the time the circuit breaker stays open before
+// probing the target when the trigger does not inform it.
+const defaultProbePeriod = 30 * time.Second
+
+// circuitBreaker tracks consecutive delivery failures to the target. When
+// open, consumption is paused and a single event is let through every probe
+// period, closing the breaker when that delivery succeeds.
+type circuitBreaker struct {
+	cfg         cfgbroker.CircuitBreaker
+	threshold   int
+	probePeriod time.Duration
+
+	state    status.CircuitBreakerStateChoice
+	failures int
+	openedAt time.Time
+	// released breakers do not block callers anymore.
+	released bool
+
+	// changed is closed and replaced at every state change
+	// to wake up waiting callers.
+	changed  chan struct{}
+	onChange func(status.CircuitBreakerStateChoice)
+	m        sync.Mutex
+}
+
+func newCircuitBreaker(cfg *cfgbroker.CircuitBreaker, onChange func(status.CircuitBreakerStateChoice)) (*circuitBreaker, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	pp := defaultProbePeriod
+	if cfg.ProbePeriod != nil {
+		p, err := period.Parse(*cfg.ProbePeriod)
+		if err != nil {
+			return nil, errors.New("probe period parsing: " + err.Error())
+		}
+		pp = p.DurationApprox()
+	}
+
+	return &circuitBreaker{
+		cfg:         *cfg,
+		threshold:   cfg.ConsecutiveFailures,
+		probePeriod: pp,
+		state:       status.CircuitBreakerStateClosed,
+		changed:     make(chan struct{}),
+		onChange:    onChange,
+	}, nil
+}
+
+// acquire blocks while the breaker is open. When the probe period has
+// elapsed a single caller is let through to probe the target, which
+// is informed by the returned value.
+func (cb *circuitBreaker) acquire(ctx context.Context) (bool, error) {
+	for {
+		cb.m.Lock()
+		if cb.released || cb.state == status.CircuitBreakerStateClosed {
+			cb.m.Unlock()
+			return false, nil
+		}
+
+		wait := cb.probePeriod - time.Since(cb.openedAt)
+		if cb.state == status.CircuitBreakerStateOpen && wait <= 0 {
+			cb.setState(status.CircuitBreakerStateHalfOpen)
+			cb.m.Unlock()
+			return true, nil
+		}
+
+		if err := cb.wait(ctx, wait); err != nil {
+			return false, err
+		}
+	}
+}
+
+// tryAcquire is the non blocking variant of acquire, returning whether the
+// caller must probe the target, or must not send the event to the target
+// because the breaker is open.
+func (cb *circuitBreaker) tryAcquire() (probe bool, open bool) {
+	cb.m.Lock()
+	defer cb.m.Unlock()
+
+	switch {
+	case cb.released || cb.state == status.CircuitBreakerStateClosed:
+		return false, false
+	case cb.state == status.CircuitBreakerStateOpen && time.Since(cb.openedAt) >= cb.probePeriod:
+		cb.setState(status.CircuitBreakerStateHalfOpen)
+		return true, false
+	}
+
+	return false, true
+}
+
+// cancelProbe is called when the caller that acquired the probe did not
+// send the event to the target, letting another caller probe right away.
+func (cb *circuitBreaker) cancelProbe() {
+	cb.m.Lock()
+	defer cb.m.Unlock()
+
+	if cb.state == status.CircuitBreakerStateHalfOpen {
+		cb.setState(status.CircuitBreakerStateOpen)
+	}
+}
+
+// waitConsumption blocks while the breaker is open, letting consumption
+// resume when it is closed or when a probe needs to be sent.
+func (cb *circuitBreaker) waitConsumption(ctx context.Context) error {
+	for {
+		cb.m.Lock()
+		if cb.released || cb.state == status.CircuitBreakerStateClosed {
+			cb.m.Unlock()
+			return nil
+		}
+
+		wait := cb.probePeriod - time.Since(cb.openedAt)
+		if cb.state == status.CircuitBreakerStateOpen && wait <= 0 {
+			cb.m.Unlock()
+			return nil
+		}
+
+		if err := cb.wait(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// wait unlocks the breaker and blocks until its state changes, the
+// informed duration elapses or the context is done.
+func (cb *circuitBreaker) wait(ctx context.Context, d time.Duration) error {
+	changed := cb.changed
+	state := cb.state
+	cb.m.Unlock()
+
+	var timeout <-chan time.Time
+	if state == status.CircuitBreakerStateOpen {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-changed:
+	case <-timeout:
+	}
+	return nil
+}
+
+// result registers the outcome of a delivery to the target.
+func (cb *circuitBreaker) result(success bool) {
+	cb.m.Lock()
+	defer cb.m.Unlock()
+
+	switch {
+	case success:
+		cb.failures = 0
+		if cb.state != status.CircuitBreakerStateClosed {
+			cb.setState(status.CircuitBreakerStateClosed)
+		}
+
+	case cb.state == status.CircuitBreakerStateHalfOpen:
+		// The probe failed, wait for the next one.
+		cb.openedAt = time.Now()
+		cb.setState(status.CircuitBreakerStateOpen)
+
+	case cb.state == status.CircuitBreakerStateClosed:
+		cb.failures++
+		if cb.failures >= cb.threshold {
+			cb.openedAt = time.Now()
+			cb.setState(status.CircuitBreakerStateOpen)
+		}
+	}
+}
+
+// release unblocks all callers, it is used when the breaker
+// is not going to be used anymore.
+func (cb *circuitBreaker) release() {
+	cb.m.Lock()
+	defer cb.m.Unlock()
+
+	cb.released = true
+	close(cb.changed)
+	cb.changed = make(chan struct{})
+}
+
+// setState must be called with the lock held.
+func (cb *circuitBreaker) setState(state status.CircuitBreakerStateChoice) {
+	cb.state = state
+	close(cb.changed)
+	cb.changed = make(chan struct{})
+
+	if cb.onChange != nil {
+		cb.onChange(state)
+	}
+}
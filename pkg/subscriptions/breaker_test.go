@@ -0,0 +1,212 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/triggermesh/brokers/pkg/backend/impl/memory"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/status"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	probePeriod := "PT0.2S"
+
+	var states []status.CircuitBreakerStateChoice
+	var m sync.Mutex
+	cb, err := newCircuitBreaker(&cfgbroker.CircuitBreaker{
+		ConsecutiveFailures: 2,
+		ProbePeriod:         &probePeriod,
+	}, func(s status.CircuitBreakerStateChoice) {
+		m.Lock()
+		defer m.Unlock()
+		states = append(states, s)
+	})
+	require.NoError(t, err)
+
+	blocked := func(f func(ctx context.Context) error) bool {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		return f(ctx) != nil
+	}
+
+	acquire := func(ctx context.Context) error {
+		_, err := cb.acquire(ctx)
+		return err
+	}
+
+	cb.result(false)
+	assert.False(t, blocked(cb.waitConsumption), "Consumption paused before reaching the failures threshold")
+
+	cb.result(false)
+	assert.True(t, blocked(cb.waitConsumption), "Consumption not paused after reaching the failures threshold")
+	assert.True(t, blocked(acquire), "Dispatch not paused after reaching the failures threshold")
+
+	time.Sleep(200 * time.Millisecond)
+	assert.False(t, blocked(cb.waitConsumption), "Consumption paused after the probe period")
+
+	probe, err := cb.acquire(context.Background())
+	require.NoError(t, err)
+	assert.True(t, probe, "Expected dispatch to probe the target")
+	assert.True(t, blocked(cb.waitConsumption), "Consumption not paused while probing")
+	assert.True(t, blocked(acquire), "Dispatch not paused while probing")
+
+	// Failed probe opens the breaker again.
+	cb.result(false)
+	assert.True(t, blocked(acquire), "Dispatch not paused after failed probe")
+
+	time.Sleep(200 * time.Millisecond)
+	probe, err = cb.acquire(context.Background())
+	require.NoError(t, err)
+	assert.True(t, probe, "Expected dispatch to probe the target")
+
+	cb.result(true)
+	assert.False(t, blocked(cb.waitConsumption), "Consumption paused after successful probe")
+	probe, err = cb.acquire(context.Background())
+	require.NoError(t, err)
+	assert.False(t, probe, "Unexpected probe with closed breaker")
+
+	m.Lock()
+	defer m.Unlock()
+	assert.Equal(t, []status.CircuitBreakerStateChoice{
+		status.CircuitBreakerStateOpen,
+		status.CircuitBreakerStateHalfOpen,
+		status.CircuitBreakerStateOpen,
+		status.CircuitBreakerStateHalfOpen,
+		status.CircuitBreakerStateClosed,
+	}, states)
+}
+
+func TestCircuitBreakerRelease(t *testing.T) {
+	cb, err := newCircuitBreaker(&cfgbroker.CircuitBreaker{ConsecutiveFailures: 1}, nil)
+	require.NoError(t, err)
+
+	cb.result(false)
+
+	done := make(chan error)
+	go func() {
+		_, err := cb.acquire(context.Background())
+		done <- err
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("Dispatch not paused with open breaker")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cb.release()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Dispatch still paused after releasing the breaker")
+	}
+}
+
+func TestCircuitBreakerUpdateError(t *testing.T) {
+	s := &subscriber{
+		name:      "test",
+		parentCtx: context.Background(),
+		logger:    zaptest.NewLogger(t).Sugar(),
+	}
+
+	url, newURL := "http://localhost:8080", "http://localhost:9090"
+	trigger := cfgbroker.Trigger{
+		Target: cfgbroker.Target{
+			URL: &url,
+			DeliveryOptions: &cfgbroker.DeliveryOptions{
+				CircuitBreaker: &cfgbroker.CircuitBreaker{ConsecutiveFailures: 1},
+			},
+		},
+	}
+	require.NoError(t, s.updateTrigger(trigger))
+	cb := s.breaker
+
+	badPeriod := "not a period"
+	err := s.updateTrigger(cfgbroker.Trigger{
+		Target: cfgbroker.Target{
+			URL: &newURL,
+			DeliveryOptions: &cfgbroker.DeliveryOptions{
+				CircuitBreaker: &cfgbroker.CircuitBreaker{ConsecutiveFailures: 1, ProbePeriod: &badPeriod},
+			},
+		},
+	})
+	require.Error(t, err)
+	assert.Equal(t, trigger, s.trigger, "Trigger was partially updated")
+	assert.Same(t, cb, s.breaker, "Circuit breaker was replaced")
+}
+
+func TestCircuitBreakerSharedDispatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	received := make(chan string, 10)
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e, err := cloudevents.NewEventFromHTTPRequest(r)
+		require.NoError(t, err)
+		received <- e.ID()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer up.Close()
+
+	logger := zaptest.NewLogger(t).Sugar()
+	b := memory.New(&memory.MemoryArgs{
+		BufferSize:             10,
+		ProduceTimeoutDuration: time.Second,
+		DedupCacheSize:         10,
+	}, logger)
+	require.NoError(t, b.Init(ctx))
+	go func() { _ = b.Start(ctx) }()
+
+	m, err := New(ctx, logger, b, nil)
+	require.NoError(t, err)
+
+	// The breaker for the trigger whose target is down
+	// opens after the first failure.
+	do := deliveryOptions(0, "PT0S")
+	probePeriod := "PT30S"
+	do.CircuitBreaker = &cfgbroker.CircuitBreaker{
+		ConsecutiveFailures: 1,
+		ProbePeriod:         &probePeriod,
+	}
+	m.UpdateFromConfig(&cfgbroker.Config{Triggers: map[string]cfgbroker.Trigger{
+		"down": {Target: cfgbroker.Target{URL: &down.URL}, DeliveryOptions: &do},
+		"up":   {Target: cfgbroker.Target{URL: &up.URL}},
+	}})
+
+	for _, id := range []string{"1", "2", "3"} {
+		e := cloudevents.NewEvent()
+		e.SetID(id)
+		e.SetType("test.type")
+		e.SetSource("test.source")
+		require.NoError(t, b.Produce(ctx, &e))
+	}
+
+	for _, id := range []string{"1", "2", "3"} {
+		select {
+		case got := <-received:
+			assert.Equal(t, id, got)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Event %s was not delivered while the other trigger's breaker is open", id)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/types"
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+	"github.com/triggermesh/brokers/pkg/common/hops"
+)
+
+// Extensions added to events sent to the dead letter sink, following
+// the Knative knativeerrordest, knativeerrorcode and knativeerrordata
+// extensions.
+const (
+	// ErrorDestExtension is the target where the event could not be delivered.
+	ErrorDestExtension = "triggermesherrordest"
+	// ErrorCodeExtension is the last response status code from the target.
+	ErrorCodeExtension = "triggermesherrorcode"
+	// ErrorDataExtension is the base64 encoded and truncated last
+	// response body from the target.
+	ErrorDataExtension = "triggermesherrordata"
+	// ErrorTriggerExtension is the trigger that failed delivering the event.
+	ErrorTriggerExtension = "triggermeshtrigger"
+	// ErrorAttemptsExtension is the number of attempts sending the event
+	// to the target.
+	ErrorAttemptsExtension = "triggermeshattempts"
+	// ErrorReasonExtension is the error that prevented sending
+	// the event to the target.
+	ErrorReasonExtension = "triggermesherrorreason"
+)
+
+// RedriveExtension routes an event produced to the backend to a single
+// trigger. It is used to re-drive events from the dead letter storage.
+const RedriveExtension = "triggermeshredrive"
+
+var errorExtensions = []string{
+	ErrorDestExtension,
+	ErrorCodeExtension,
+	ErrorDataExtension,
+	ErrorTriggerExtension,
+	ErrorAttemptsExtension,
+	ErrorReasonExtension,
+}
+
+// deliveryReport contains the outcome of sending an event to the target.
+type deliveryReport struct {
+	attempts     int
+	statusCode   int
+	responseData []byte
+
+	// err is the reason why the event was not sent to the target.
+	err error
+}
+
+// withErrorExtensions returns a copy of the event that contains the
+// delivery failure details.
+func (s *subscriber) withErrorExtensions(event *cloudevents.Event, target *url.URL, report *deliveryReport) *cloudevents.Event {
+	e := event.Clone()
+
+	exts := map[string]interface{}{
+		ErrorTriggerExtension:  s.name,
+		ErrorAttemptsExtension: report.attempts,
+	}
+
+	if target == nil && s.trigger.Target.URL != nil {
+		target, _ = url.Parse(*s.trigger.Target.URL)
+	}
+	if target != nil {
+		exts[ErrorDestExtension] = target.String()
+	}
+
+	if report.statusCode != 0 {
+		exts[ErrorCodeExtension] = report.statusCode
+	}
+
+	if len(report.responseData) != 0 {
+		exts[ErrorDataExtension] = base64.StdEncoding.EncodeToString(report.responseData)
+	}
+
+	if report.err != nil {
+		exts[ErrorReasonExtension] = report.err.Error()
+	}
+
+	for k, v := range exts {
+		if err := e.Context.SetExtension(k, v); err != nil {
+			s.logger.Errorw("Could not set error extension for the dead letter sink", zap.String("extension", k), zap.Error(err),
+				zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
+		}
+	}
+
+	return &e
+}
+
+// produceDeadLetter stores the event at the backend's dead letter storage
+// for the trigger, along with the delivery failure details.
+func (s *subscriber) produceDeadLetter(event *cloudevents.Event, target *url.URL, report *deliveryReport) bool {
+	store, ok := s.backend.(backend.DeadLetterStore)
+	if !ok {
+		s.logger.Errorw("Backend does not support dead letter storage",
+			zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
+		return false
+	}
+
+	ctx, cancel := s.delivery.attemptContext(s.parentCtx)
+	defer cancel()
+
+	if err := store.ProduceDeadLetter(ctx, s.name, s.withErrorExtensions(event, target, report)); err != nil {
+		s.logger.Errorw("Could not store event at the backend dead letter storage", zap.Error(err),
+			zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
+		return false
+	}
+
+	return true
+}
+
+// redriveTarget returns whether the event is being re-driven to a
+// trigger, and the trigger name.
+func redriveTarget(event *cloudevents.Event) (string, bool, error) {
+	v, ok := event.Extensions()[RedriveExtension]
+	if !ok {
+		return "", false, nil
+	}
+
+	trigger, err := types.ToString(v)
+	if err != nil {
+		return "", true, fmt.Errorf("not valid %s extension: %w", RedriveExtension, err)
+	}
+
+	return trigger, true, nil
+}
+
+// RedriveEvent returns a copy of an event stored at the dead letter storage
+// that can be produced to the backend to be dispatched only by the trigger.
+// Delivery failure details are removed from the event, and the hop count is
+// restarted.
+func RedriveEvent(event *cloudevents.Event, trigger string) (*cloudevents.Event, error) {
+	e := event.Clone()
+
+	for _, ext := range append(errorExtensions, hops.CountExtension, hops.TriggersExtension) {
+		if err := e.Context.SetExtension(ext, nil); err != nil {
+			return nil, fmt.Errorf("could not remove extension %s: %w", ext, err)
+		}
+	}
+
+	if err := e.Context.SetExtension(RedriveExtension, trigger); err != nil {
+		return nil, fmt.Errorf("could not set extension %s: %w", RedriveExtension, err)
+	}
+
+	return &e, nil
+}
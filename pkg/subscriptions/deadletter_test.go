@@ -0,0 +1,305 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+func TestDeadLetterErrorExtensions(t *testing.T) {
+	longBody := strings.Repeat("x", maxResponseData+100)
+
+	testCases := map[string]struct {
+		enabled bool
+		body    string
+
+		expectedExtensions map[string]interface{}
+	}{
+		"disabled": {
+			enabled:            false,
+			body:               "order not valid",
+			expectedExtensions: nil,
+		},
+		"enabled": {
+			enabled: true,
+			body:    "order not valid",
+			expectedExtensions: map[string]interface{}{
+				ErrorTriggerExtension:  "test-subscriber",
+				ErrorAttemptsExtension: "2",
+				ErrorCodeExtension:     "503",
+				ErrorDataExtension:     base64.StdEncoding.EncodeToString([]byte("order not valid")),
+			},
+		},
+		"truncated data": {
+			enabled: true,
+			body:    longBody,
+			expectedExtensions: map[string]interface{}{
+				ErrorTriggerExtension:  "test-subscriber",
+				ErrorAttemptsExtension: "2",
+				ErrorCodeExtension:     "503",
+				ErrorDataExtension:     base64.StdEncoding.EncodeToString([]byte(longBody[:maxResponseData])),
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				_, _ = w.Write([]byte(tc.body))
+			}))
+			defer target.Close()
+
+			dlsEvents := make(chan *cloudevents.Event, 1)
+			dls := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				e, err := cloudevents.NewEventFromHTTPRequest(r)
+				require.NoError(t, err)
+				dlsEvents <- e
+				w.WriteHeader(http.StatusAccepted)
+			}))
+			defer dls.Close()
+
+			p, err := cehttp.New(cehttp.WithRoundTripper(&responseInfoTransport{base: http.DefaultTransport}))
+			require.NoError(t, err)
+			client, err := cloudevents.NewClient(p)
+			require.NoError(t, err)
+
+			s := subscriber{
+				name:      "test-subscriber",
+				ceClient:  client,
+				parentCtx: context.Background(),
+				logger:    zaptest.NewLogger(t).Sugar(),
+			}
+
+			do := deliveryOptions(1, "PT0S")
+			do.DeadLetterURL = &dls.URL
+			do.DeadLetterErrorExtensions = tc.enabled
+			err = s.updateTrigger(cfgbroker.Trigger{
+				Target:          cfgbroker.Target{URL: &target.URL},
+				DeliveryOptions: &do,
+			})
+			require.NoError(t, err)
+
+			event := cloudevents.NewEvent()
+			event.SetID("1")
+			event.SetType("test.type")
+			event.SetSource("test.source")
+
+			s.dispatchCloudEvent(&event)
+
+			var e *cloudevents.Event
+			select {
+			case e = <-dlsEvents:
+			case <-time.After(time.Second):
+				t.Fatal("Event not received at the dead letter sink")
+			}
+
+			if tc.expectedExtensions != nil {
+				tc.expectedExtensions[ErrorDestExtension] = target.URL
+			}
+			assert.Equal(t, tc.expectedExtensions, e.Extensions())
+			assert.Empty(t, event.Extensions(), "Original event must not be modified")
+		})
+	}
+}
+
+// deadLetterBackend is a backend that only implements producing
+// to the dead letter storage.
+type deadLetterBackend struct {
+	backend.Interface
+	backend.DeadLetterStore
+
+	subscription string
+	events       chan *cloudevents.Event
+}
+
+func (b *deadLetterBackend) ProduceDeadLetter(_ context.Context, subscription string, event *cloudevents.Event) error {
+	b.subscription = subscription
+	b.events <- event
+	return nil
+}
+
+func TestDeadLetterBackend(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer target.Close()
+
+	p, err := cehttp.New(cehttp.WithRoundTripper(&responseInfoTransport{base: http.DefaultTransport}))
+	require.NoError(t, err)
+	client, err := cloudevents.NewClient(p)
+	require.NoError(t, err)
+
+	b := &deadLetterBackend{events: make(chan *cloudevents.Event, 1)}
+	s := subscriber{
+		name:      "test-subscriber",
+		backend:   b,
+		ceClient:  client,
+		parentCtx: context.Background(),
+		logger:    zaptest.NewLogger(t).Sugar(),
+	}
+
+	do := deliveryOptions(0, "PT0S")
+	do.DeadLetterBackend = true
+	err = s.updateTrigger(cfgbroker.Trigger{
+		Target:          cfgbroker.Target{URL: &target.URL},
+		DeliveryOptions: &do,
+	})
+	require.NoError(t, err)
+
+	event := cloudevents.NewEvent()
+	event.SetID("1")
+	event.SetType("test.type")
+	event.SetSource("test.source")
+
+	s.dispatchCloudEvent(&event)
+
+	var e *cloudevents.Event
+	select {
+	case e = <-b.events:
+	default:
+		t.Fatal("Event not stored at the backend dead letter storage")
+	}
+
+	assert.Equal(t, "test-subscriber", b.subscription)
+	assert.Equal(t, map[string]interface{}{
+		ErrorTriggerExtension:  "test-subscriber",
+		ErrorAttemptsExtension: int32(1),
+		ErrorCodeExtension:     int32(500),
+		ErrorDestExtension:     target.URL,
+	}, e.Extensions())
+}
+
+func TestDeadLetterTransformError(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Event that could not be transformed was sent to the target")
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer target.Close()
+
+	client, err := cloudevents.NewClientHTTP()
+	require.NoError(t, err)
+
+	b := &deadLetterBackend{events: make(chan *cloudevents.Event, 1)}
+	s := subscriber{
+		name:      "test-subscriber",
+		backend:   b,
+		ceClient:  client,
+		parentCtx: context.Background(),
+		logger:    zaptest.NewLogger(t).Sugar(),
+	}
+
+	do := deliveryOptions(0, "PT0S")
+	do.DeadLetterBackend = true
+	err = s.updateTrigger(cfgbroker.Trigger{
+		Target: cfgbroker.Target{URL: &target.URL},
+		Transform: &cfgbroker.Transform{
+			Set: map[string]string{"ext1": "transformed"},
+			Data: &cfgbroker.DataTransform{
+				Mapping: map[string]string{"id": "id"},
+			},
+		},
+		DeliveryOptions: &do,
+	})
+	require.NoError(t, err)
+
+	event := cloudevents.NewEvent()
+	event.SetID("1")
+	event.SetType("test.type")
+	event.SetSource("test.source")
+	require.NoError(t, event.SetData(cloudevents.TextPlain, "not json"))
+
+	s.dispatchCloudEvent(&event)
+
+	var e *cloudevents.Event
+	select {
+	case e = <-b.events:
+	default:
+		t.Fatal("Event not stored at the backend dead letter storage")
+	}
+
+	assert.Equal(t, "not json", string(e.Data()), "Original event data must be dead lettered")
+	assert.Equal(t, "test.type", e.Type())
+
+	exts := e.Extensions()
+	assert.NotContains(t, exts, "ext1", "Transformation must not be applied to the dead lettered event")
+	assert.NotContains(t, exts, ErrorCodeExtension)
+	assert.Equal(t, "test-subscriber", exts[ErrorTriggerExtension])
+	assert.Equal(t, int32(0), exts[ErrorAttemptsExtension])
+	assert.Equal(t, target.URL, exts[ErrorDestExtension])
+	assert.Contains(t, exts[ErrorReasonExtension], "could not transform event")
+}
+
+func TestRedriveEvent(t *testing.T) {
+	received := make(chan *cloudevents.Event, 1)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e, err := cloudevents.NewEventFromHTTPRequest(r)
+		require.NoError(t, err)
+		received <- e
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer target.Close()
+
+	client, err := cloudevents.NewClientHTTP()
+	require.NoError(t, err)
+
+	newSubscriber := func(name string) *subscriber {
+		s := &subscriber{
+			name:      name,
+			ceClient:  client,
+			parentCtx: context.Background(),
+			logger:    zaptest.NewLogger(t).Sugar(),
+		}
+		require.NoError(t, s.updateTrigger(cfgbroker.Trigger{
+			Target: cfgbroker.Target{URL: &target.URL},
+		}))
+		return s
+	}
+
+	dl := cloudevents.NewEvent()
+	dl.SetID("1")
+	dl.SetType("test.type")
+	dl.SetSource("test.source")
+	dl.SetExtension("custom", "value")
+	dl.SetExtension(ErrorTriggerExtension, "test-subscriber")
+	dl.SetExtension(ErrorAttemptsExtension, 3)
+	dl.SetExtension(ErrorCodeExtension, 500)
+
+	event, err := RedriveEvent(&dl, "test-subscriber")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"custom":         "value",
+		RedriveExtension: "test-subscriber",
+	}, event.Extensions())
+
+	newSubscriber("other-subscriber").dispatchCloudEvent(event)
+	select {
+	case <-received:
+		t.Fatal("Re-driven event dispatched by a different trigger")
+	default:
+	}
+
+	newSubscriber("test-subscriber").dispatchCloudEvent(event)
+	select {
+	case e := <-received:
+		assert.Equal(t, map[string]interface{}{"custom": "value"}, e.Extensions())
+	default:
+		t.Fatal("Re-driven event not dispatched by the trigger")
+	}
+}
@@ -0,0 +1,261 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	cecontext "github.com/cloudevents/sdk-go/v2/context"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/rickb777/date/period"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// defaultRetryAfterMax caps the delay requested by targets using the
+// Retry-After header when the trigger does not inform a maximum.
+const defaultRetryAfterMax = time.Minute
+
+// retriableStatusCodes are the response status codes that are retried,
+// the same set the CloudEvents HTTP protocol retries by default.
+var retriableStatusCodes = map[int]struct{}{
+	http.StatusNotFound:              {},
+	http.StatusRequestEntityTooLarge: {},
+	http.StatusTooEarly:              {},
+	http.StatusTooManyRequests:       {},
+	http.StatusBadGateway:            {},
+	http.StatusServiceUnavailable:    {},
+	http.StatusGatewayTimeout:        {},
+}
+
+// deliveryPolicy contains the trigger's delivery options used
+// to send events to the target.
+type deliveryPolicy struct {
+	retry         cecontext.RetryParams
+	timeout       time.Duration
+	retryAfterMax time.Duration
+	nonRetryable  map[int]struct{}
+}
+
+func newDeliveryPolicy(do *cfgbroker.DeliveryOptions) (*deliveryPolicy, error) {
+	dp := &deliveryPolicy{
+		retry:         cecontext.DefaultRetryParams,
+		retryAfterMax: defaultRetryAfterMax,
+	}
+
+	if do == nil {
+		return dp, nil
+	}
+
+	if do.Retry != nil && *do.Retry >= 1 && do.BackoffPolicy != nil {
+		var delay time.Duration
+		if do.BackoffDelay != nil {
+			p, err := period.Parse(*do.BackoffDelay)
+			if err != nil {
+				return nil, errors.New("backoff delay parsing: " + err.Error())
+			}
+			delay = p.DurationApprox()
+		}
+
+		dp.retry.MaxTries = int(*do.Retry)
+		dp.retry.Period = delay
+
+		switch *do.BackoffPolicy {
+		case cfgbroker.BackoffPolicyLinear:
+			dp.retry.Strategy = cecontext.BackoffStrategyLinear
+		case cfgbroker.BackoffPolicyExponential:
+			dp.retry.Strategy = cecontext.BackoffStrategyExponential
+		default:
+			dp.retry.Strategy = cecontext.BackoffStrategyConstant
+		}
+	}
+
+	if do.Timeout != nil {
+		p, err := period.Parse(*do.Timeout)
+		if err != nil {
+			return nil, errors.New("timeout parsing: " + err.Error())
+		}
+		dp.timeout = p.DurationApprox()
+	}
+
+	if do.RetryAfterMax != nil {
+		p, err := period.Parse(*do.RetryAfterMax)
+		if err != nil {
+			return nil, errors.New("retry after max parsing: " + err.Error())
+		}
+		dp.retryAfterMax = p.DurationApprox()
+	}
+
+	if len(do.NonRetryableStatusCodes) != 0 {
+		dp.nonRetryable = make(map[int]struct{}, len(do.NonRetryableStatusCodes))
+		for _, code := range do.NonRetryableStatusCodes {
+			dp.nonRetryable[code] = struct{}{}
+		}
+	}
+
+	return dp, nil
+}
+
+// attemptContext returns the context for a single delivery request.
+func (dp *deliveryPolicy) attemptContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if dp.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, dp.timeout)
+}
+
+// retriable returns whether a failed delivery should be retried, with
+// the status code returned by the target, or zero if no response was
+// received.
+func (dp *deliveryPolicy) retriable(code int) bool {
+	if code == 0 {
+		return true
+	}
+
+	if _, ok := dp.nonRetryable[code]; ok {
+		return false
+	}
+
+	_, ok := retriableStatusCodes[code]
+	return ok
+}
+
+// backoff returns the delay before the next try, or false if no more
+// retries should be done. Delays requested by the target using the
+// Retry-After header are honored for 429 and 503 responses.
+func (dp *deliveryPolicy) backoff(tries int, code int, retryAfter string, now time.Time) (time.Duration, bool) {
+	if tries > dp.retry.MaxTries {
+		return 0, false
+	}
+
+	if code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable {
+		if d, ok := parseRetryAfter(retryAfter, now); ok {
+			if d > dp.retryAfterMax {
+				d = dp.retryAfterMax
+			}
+			return d, true
+		}
+	}
+
+	return dp.retry.BackoffFor(tries), true
+}
+
+// parseRetryAfter parses the Retry-After header value, which can be
+// informed either as seconds or as an HTTP date.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+
+	if s, err := strconv.Atoi(v); err == nil {
+		if s < 0 {
+			return 0, false
+		}
+		return time.Duration(s) * time.Second, true
+	}
+
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+
+	d := t.Sub(now)
+	if d < 0 {
+		d = 0
+	}
+	return d, true
+}
+
+// statusCode returns the HTTP status code at the result of a delivery,
+// or zero if no response was received.
+func statusCode(result error) int {
+	var httpResult *cehttp.Result
+	if errors.As(result, &httpResult) {
+		return httpResult.StatusCode
+	}
+	return 0
+}
+
+type responseInfoKey struct{}
+
+// maxResponseData is the maximum size of the response body kept
+// for failed deliveries.
+const maxResponseData = 1024
+
+// responseInfo keeps response data that is not exposed by the
+// CloudEvents client.
+type responseInfo struct {
+	retryAfter string
+	// data is the truncated body of non 2xx responses.
+	data []byte
+	m    sync.Mutex
+}
+
+func withResponseInfo(ctx context.Context) (context.Context, *responseInfo) {
+	ri := &responseInfo{}
+	return context.WithValue(ctx, responseInfoKey{}, ri), ri
+}
+
+func (ri *responseInfo) getRetryAfter() string {
+	ri.m.Lock()
+	defer ri.m.Unlock()
+	return ri.retryAfter
+}
+
+func (ri *responseInfo) getData() []byte {
+	ri.m.Lock()
+	defer ri.m.Unlock()
+	return ri.data
+}
+
+// responseInfoTransport stores response data at the responseInfo
+// informed at the request context, if any.
+type responseInfoTransport struct {
+	base http.RoundTripper
+}
+
+func (t *responseInfoTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.base.RoundTrip(req)
+	if err != nil {
+		return res, err
+	}
+
+	ri, ok := req.Context().Value(responseInfoKey{}).(*responseInfo)
+	if !ok {
+		return res, nil
+	}
+
+	var data []byte
+	if res.StatusCode/100 != 2 && res.Body != nil {
+		// Keep the beginning of the body, the response is
+		// still fully readable by the caller.
+		data, err = io.ReadAll(io.LimitReader(res.Body, maxResponseData))
+		if err != nil {
+			return nil, err
+		}
+		res.Body = &prefixedBody{
+			Reader: io.MultiReader(bytes.NewReader(data), res.Body),
+			Closer: res.Body,
+		}
+	}
+
+	ri.m.Lock()
+	ri.retryAfter = res.Header.Get("Retry-After")
+	ri.data = data
+	ri.m.Unlock()
+
+	return res, nil
+}
+
+type prefixedBody struct {
+	io.Reader
+	io.Closer
+}
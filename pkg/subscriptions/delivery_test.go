@@ -0,0 +1,218 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+func TestDeliveryPolicyBackoff(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := map[string]struct {
+		do         cfgbroker.DeliveryOptions
+		tries      int
+		code       int
+		retryAfter string
+
+		expectedDelay time.Duration
+		expectedRetry bool
+	}{
+		"backoff": {
+			do:            deliveryOptions(3, "PT2S"),
+			tries:         2,
+			code:          http.StatusInternalServerError,
+			expectedDelay: 8 * time.Second,
+			expectedRetry: true,
+		},
+		"max tries reached": {
+			do:    deliveryOptions(3, "PT2S"),
+			tries: 4,
+			code:  http.StatusInternalServerError,
+		},
+		"retry after seconds": {
+			do:            deliveryOptions(3, "PT2S"),
+			tries:         1,
+			code:          http.StatusTooManyRequests,
+			retryAfter:    "10",
+			expectedDelay: 10 * time.Second,
+			expectedRetry: true,
+		},
+		"retry after date": {
+			do:            deliveryOptions(3, "PT2S"),
+			tries:         1,
+			code:          http.StatusServiceUnavailable,
+			retryAfter:    now.Add(30 * time.Second).Format(http.TimeFormat),
+			expectedDelay: 30 * time.Second,
+			expectedRetry: true,
+		},
+		"retry after capped": {
+			do:            deliveryOptions(3, "PT2S"),
+			tries:         1,
+			code:          http.StatusTooManyRequests,
+			retryAfter:    "3600",
+			expectedDelay: defaultRetryAfterMax,
+			expectedRetry: true,
+		},
+		"retry after not valid": {
+			do:            deliveryOptions(3, "PT2S"),
+			tries:         1,
+			code:          http.StatusTooManyRequests,
+			retryAfter:    "soon",
+			expectedDelay: 4 * time.Second,
+			expectedRetry: true,
+		},
+		"retry after ignored": {
+			do:            deliveryOptions(3, "PT2S"),
+			tries:         1,
+			code:          http.StatusInternalServerError,
+			retryAfter:    "10",
+			expectedDelay: 4 * time.Second,
+			expectedRetry: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			dp, err := newDeliveryPolicy(&tc.do)
+			require.NoError(t, err)
+
+			d, retry := dp.backoff(tc.tries, tc.code, tc.retryAfter, now)
+			assert.Equal(t, tc.expectedRetry, retry)
+			assert.Equal(t, tc.expectedDelay, d)
+		})
+	}
+}
+
+func TestSubscriberDeliver(t *testing.T) {
+	testCases := map[string]struct {
+		responses []func(w http.ResponseWriter)
+		do        cfgbroker.DeliveryOptions
+
+		expectedDelivered bool
+		expectedRequests  int32
+	}{
+		"retry after honored": {
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(http.StatusTooManyRequests)
+				},
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusAccepted) },
+			},
+			// Backoff delay would exceed the test timeout.
+			do:                deliveryOptions(2, "PT1H"),
+			expectedDelivered: true,
+			expectedRequests:  2,
+		},
+		"non retryable status code": {
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusUnprocessableEntity) },
+			},
+			do: func() cfgbroker.DeliveryOptions {
+				do := deliveryOptions(3, "PT0S")
+				do.NonRetryableStatusCodes = []int{http.StatusBadRequest, http.StatusUnprocessableEntity}
+				return do
+			}(),
+			expectedDelivered: false,
+			expectedRequests:  1,
+		},
+		"not retryable by default": {
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusInternalServerError) },
+			},
+			do:                deliveryOptions(2, "PT0S"),
+			expectedDelivered: false,
+			expectedRequests:  1,
+		},
+		"retries exhausted": {
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusServiceUnavailable) },
+			},
+			do:                deliveryOptions(2, "PT0S"),
+			expectedDelivered: false,
+			expectedRequests:  3,
+		},
+		"timeout": {
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) { time.Sleep(2 * time.Second) },
+			},
+			do: func() cfgbroker.DeliveryOptions {
+				timeout := "PT1S"
+				return cfgbroker.DeliveryOptions{Timeout: &timeout}
+			}(),
+			expectedDelivered: false,
+			expectedRequests:  1,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var requests int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&requests, 1)
+				i := int(n) - 1
+				if i >= len(tc.responses) {
+					i = len(tc.responses) - 1
+				}
+				tc.responses[i](w)
+			}))
+			defer srv.Close()
+
+			p, err := cehttp.New(cehttp.WithRoundTripper(&responseInfoTransport{base: http.DefaultTransport}))
+			require.NoError(t, err)
+			client, err := cloudevents.NewClient(p)
+			require.NoError(t, err)
+
+			s := subscriber{
+				name:      "test-subscriber",
+				ceClient:  client,
+				parentCtx: context.Background(),
+				logger:    zaptest.NewLogger(t).Sugar(),
+			}
+
+			do := tc.do
+			err = s.updateTrigger(cfgbroker.Trigger{
+				Target:          cfgbroker.Target{URL: &srv.URL},
+				DeliveryOptions: &do,
+			})
+			require.NoError(t, err)
+
+			event := cloudevents.NewEvent()
+			event.SetID("1")
+			event.SetType("test.type")
+			event.SetSource("test.source")
+
+			start := time.Now()
+			delivered, report := s.deliver(s.ctx, &event)
+
+			assert.Equal(t, tc.expectedDelivered, delivered)
+			assert.Equal(t, tc.expectedRequests, atomic.LoadInt32(&requests))
+			assert.Equal(t, int(tc.expectedRequests), report.attempts)
+			assert.Less(t, time.Since(start), 10*time.Second)
+		})
+	}
+}
+
+func deliveryOptions(retry int32, delay string) cfgbroker.DeliveryOptions {
+	policy := cfgbroker.BackoffPolicyExponential
+	return cfgbroker.DeliveryOptions{
+		Retry:         &retry,
+		BackoffPolicy: &policy,
+		BackoffDelay:  &delay,
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/types"
+	"go.uber.org/zap"
+
+	"knative.dev/eventing/pkg/eventfilter"
+	"knative.dev/eventing/pkg/eventfilter/attributes"
+	"knative.dev/pkg/logging"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+type regexFilter struct {
+	filters map[string]*regexp.Regexp
+}
+
+// newRegexFilter returns an event filter which passes if the value of the context
+// attribute in the CloudEvent matches the regular expression.
+func newRegexFilter(filters map[string]string) (eventfilter.Filter, error) {
+	rf := &regexFilter{
+		filters: make(map[string]*regexp.Regexp, len(filters)),
+	}
+
+	for attribute, expr := range filters {
+		if attribute == "" || expr == "" {
+			return nil, errors.New("invalid arguments, attribute and regular expression can't be empty")
+		}
+
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression for attribute %q: %w", attribute, err)
+		}
+		rf.filters[attribute] = re
+	}
+
+	return rf, nil
+}
+
+func (filter *regexFilter) Filter(ctx context.Context, event cloudevents.Event) eventfilter.FilterResult {
+	logger := logging.FromContext(ctx)
+	for k, re := range filter.filters {
+		value, ok := attributes.LookupAttribute(event, k)
+		if !ok {
+			logger.Debugw("Couldn't find attribute in event. Regex match failed.", zap.String("attribute", k), zap.String("regex", re.String()))
+			return eventfilter.FailFilter
+		}
+		if !re.MatchString(fmt.Sprintf("%v", value)) {
+			return eventfilter.FailFilter
+		}
+	}
+	return eventfilter.PassFilter
+}
+
+// boundsCheck compares a value with a range bound, returning -1, 0 or 1
+// when the value is lower, equal or greater than the bound.
+type boundsCheck func(value interface{}) (int, error)
+
+type rangeBound struct {
+	compare boundsCheck
+	// pass returns true if the comparison result satisfies the bound.
+	pass func(int) bool
+}
+
+type rangeFilter struct {
+	filters map[string][]rangeBound
+}
+
+// newRangeFilter returns an event filter which passes if the value of the context
+// attribute in the CloudEvent is within the informed bounds.
+func newRangeFilter(filters map[string]cfgbroker.Range) (eventfilter.Filter, error) {
+	rf := &rangeFilter{
+		filters: make(map[string][]rangeBound, len(filters)),
+	}
+
+	for attribute, r := range filters {
+		if attribute == "" {
+			return nil, errors.New("invalid arguments, attribute can't be empty")
+		}
+
+		kind, err := r.Kind()
+		if err != nil {
+			return nil, fmt.Errorf("invalid range for attribute %q: %w", attribute, err)
+		}
+
+		bounds := []rangeBound{}
+		for _, b := range []struct {
+			value *cfgbroker.RangeValue
+			pass  func(int) bool
+		}{
+			{r.GreaterThan, func(c int) bool { return c > 0 }},
+			{r.GreaterThanOrEqual, func(c int) bool { return c >= 0 }},
+			{r.LessThan, func(c int) bool { return c < 0 }},
+			{r.LessThanOrEqual, func(c int) bool { return c <= 0 }},
+		} {
+			if b.value == nil {
+				continue
+			}
+
+			var compare boundsCheck
+			switch kind {
+			case cfgbroker.RangeKindNumber:
+				n, _ := b.value.Number()
+				compare = numberCompare(n)
+			case cfgbroker.RangeKindTime:
+				t, _ := b.value.Time()
+				compare = timeCompare(t)
+			}
+
+			bounds = append(bounds, rangeBound{compare: compare, pass: b.pass})
+		}
+
+		rf.filters[attribute] = bounds
+	}
+
+	return rf, nil
+}
+
+func (filter *rangeFilter) Filter(ctx context.Context, event cloudevents.Event) eventfilter.FilterResult {
+	logger := logging.FromContext(ctx)
+	for k, bounds := range filter.filters {
+		value, ok := lookupRangeAttribute(event, k)
+		if !ok {
+			logger.Debugw("Couldn't find attribute in event. Range match failed.", zap.String("attribute", k))
+			return eventfilter.FailFilter
+		}
+
+		for _, b := range bounds {
+			c, err := b.compare(value)
+			if err != nil {
+				logger.Debugw("Attribute could not be compared. Range match failed.", zap.String("attribute", k), zap.Error(err))
+				return eventfilter.FailFilter
+			}
+			if !b.pass(c) {
+				return eventfilter.FailFilter
+			}
+		}
+	}
+	return eventfilter.PassFilter
+}
+
+// lookupRangeAttribute returns the attribute value without string
+// conversions for the time attribute.
+func lookupRangeAttribute(event cloudevents.Event, attr string) (interface{}, bool) {
+	if attr == "time" {
+		t := event.Time()
+		return t, !t.IsZero()
+	}
+	return attributes.LookupAttribute(event, attr)
+}
+
+func numberCompare(bound float64) boundsCheck {
+	return func(value interface{}) (int, error) {
+		n, err := strconv.ParseFloat(fmt.Sprintf("%v", value), 64)
+		if err != nil {
+			return 0, err
+		}
+
+		switch {
+		case n < bound:
+			return -1, nil
+		case n > bound:
+			return 1, nil
+		}
+		return 0, nil
+	}
+}
+
+func timeCompare(bound time.Time) boundsCheck {
+	return func(value interface{}) (int, error) {
+		t, err := types.ToTime(value)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case t.Before(bound):
+			return -1, nil
+		case t.After(bound):
+			return 1, nil
+		}
+		return 0, nil
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CheckFailures returns an error when any of the triggers could not be setup.
+func (m *Manager) CheckFailures() error {
+	m.m.RLock()
+	defer m.m.RUnlock()
+
+	if len(m.failures) == 0 {
+		return nil
+	}
+
+	msgs := make([]string, 0, len(m.failures))
+	for name, msg := range m.failures {
+		msgs = append(msgs, fmt.Sprintf("trigger %q: %s", name, msg))
+	}
+	sort.Strings(msgs)
+
+	return fmt.Errorf("failed triggers: %s", strings.Join(msgs, "; "))
+}
+
+// CheckStalled returns an error when any of the subscribers has been
+// dispatching an event for longer than the informed timeout.
+func (m *Manager) CheckStalled(timeout time.Duration) error {
+	m.m.RLock()
+	defer m.m.RUnlock()
+
+	now := time.Now()
+	msgs := []string{}
+	for name, s := range m.subscribers {
+		start := s.oldestDispatch()
+		if start.IsZero() {
+			continue
+		}
+
+		if d := now.Sub(start); d > timeout {
+			msgs = append(msgs, fmt.Sprintf("trigger %q dispatch has not advanced for %s", name, d.Round(time.Second)))
+		}
+	}
+
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	sort.Strings(msgs)
+	return fmt.Errorf("stalled dispatch: %s", strings.Join(msgs, "; "))
+}
+
+// trackDispatch registers the start of a dispatch, returning the
+// function that must be called when it finishes.
+func (s *subscriber) trackDispatch() func() {
+	s.im.Lock()
+	defer s.im.Unlock()
+
+	if s.inflight == nil {
+		s.inflight = make(map[uint64]time.Time)
+	}
+
+	s.dispatchSeq++
+	id := s.dispatchSeq
+	s.inflight[id] = time.Now()
+
+	return func() {
+		s.im.Lock()
+		defer s.im.Unlock()
+		delete(s.inflight, id)
+	}
+}
+
+// oldestDispatch returns the start time of the oldest dispatch
+// being processed, or zero time if there is none.
+func (s *subscriber) oldestDispatch() time.Time {
+	s.im.Lock()
+	defer s.im.Unlock()
+
+	var oldest time.Time
+	for _, t := range s.inflight {
+		if oldest.IsZero() || t.Before(oldest) {
+			oldest = t
+		}
+	}
+	return oldest
+}
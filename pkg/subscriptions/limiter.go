@@ -0,0 +1,99 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/time/rate"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// dispatchLimiter bounds the number of events being dispatched at the same
+// time and the rate at which they are dispatched for a trigger.
+type dispatchLimiter struct {
+	maxConcurrency *int
+	rateLimit      *cfgbroker.RateLimitRule
+
+	// slots is a semaphore sized after the maximum concurrency,
+	// nil when concurrency is not limited.
+	slots chan struct{}
+	// rate is nil when the rate is not limited.
+	rate *rate.Limiter
+}
+
+func newDispatchLimiter(do *cfgbroker.DeliveryOptions) *dispatchLimiter {
+	if do == nil || (do.MaxConcurrency == nil && do.RateLimit == nil) {
+		return nil
+	}
+
+	l := &dispatchLimiter{
+		maxConcurrency: do.MaxConcurrency,
+		rateLimit:      do.RateLimit,
+	}
+
+	if do.MaxConcurrency != nil {
+		l.slots = make(chan struct{}, *do.MaxConcurrency)
+	}
+
+	if do.RateLimit != nil {
+		l.rate = rate.NewLimiter(rate.Limit(do.RateLimit.EventsPerSecond), do.RateLimit.GetBurst())
+	}
+
+	return l
+}
+
+// equal returns whether the limiter was created using
+// the same delivery options.
+func (l *dispatchLimiter) equal(do *cfgbroker.DeliveryOptions) bool {
+	if l == nil || do == nil {
+		return l == nil && (do == nil || (do.MaxConcurrency == nil && do.RateLimit == nil))
+	}
+
+	if (l.maxConcurrency == nil) != (do.MaxConcurrency == nil) ||
+		l.maxConcurrency != nil && *l.maxConcurrency != *do.MaxConcurrency {
+		return false
+	}
+
+	if (l.rateLimit == nil) != (do.RateLimit == nil) {
+		return false
+	}
+	if l.rateLimit == nil {
+		return true
+	}
+
+	return l.rateLimit.EventsPerSecond == do.RateLimit.EventsPerSecond &&
+		l.rateLimit.GetBurst() == do.RateLimit.GetBurst()
+}
+
+// acquire blocks until a dispatch slot is available and the rate allows a
+// new event, returning the function that frees the slot.
+func (l *dispatchLimiter) acquire(ctx context.Context) (func(), error) {
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	release := func() {}
+	if l.slots != nil {
+		var once sync.Once
+		release = func() {
+			once.Do(func() { <-l.slots })
+		}
+	}
+
+	if l.rate != nil {
+		if err := l.rate.Wait(ctx); err != nil {
+			release()
+			return nil, err
+		}
+	}
+
+	return release, nil
+}
@@ -0,0 +1,115 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+func TestDispatchLimiterConcurrency(t *testing.T) {
+	maxConcurrency := 2
+	l := newDispatchLimiter(&cfgbroker.DeliveryOptions{MaxConcurrency: &maxConcurrency})
+	require.NotNil(t, l)
+
+	r1, err := l.acquire(context.Background())
+	require.NoError(t, err)
+	_, err = l.acquire(context.Background())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = l.acquire(ctx)
+	assert.Error(t, err, "Expected acquire to block when all slots are in use")
+
+	// Releasing twice must not free more than one slot.
+	r1()
+	r1()
+
+	_, err = l.acquire(context.Background())
+	require.NoError(t, err)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = l.acquire(ctx)
+	assert.Error(t, err, "Expected acquire to block when all slots are in use")
+}
+
+func TestDispatchLimiterRate(t *testing.T) {
+	burst := 1
+	l := newDispatchLimiter(&cfgbroker.DeliveryOptions{
+		RateLimit: &cfgbroker.RateLimitRule{EventsPerSecond: 10, Burst: &burst},
+	})
+	require.NotNil(t, l)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		release, err := l.acquire(context.Background())
+		require.NoError(t, err)
+		release()
+	}
+
+	// First event uses the burst, the next two wait 100ms each.
+	assert.GreaterOrEqual(t, time.Since(start), 180*time.Millisecond)
+}
+
+func TestDispatchLimiterEqual(t *testing.T) {
+	one, two := 1, 2
+
+	testCases := map[string]struct {
+		current  *cfgbroker.DeliveryOptions
+		incoming *cfgbroker.DeliveryOptions
+		expected bool
+	}{
+		"no limits": {
+			current:  nil,
+			incoming: &cfgbroker.DeliveryOptions{},
+			expected: true,
+		},
+		"limits added": {
+			current:  nil,
+			incoming: &cfgbroker.DeliveryOptions{MaxConcurrency: &one},
+			expected: false,
+		},
+		"limits removed": {
+			current:  &cfgbroker.DeliveryOptions{MaxConcurrency: &one},
+			incoming: &cfgbroker.DeliveryOptions{},
+			expected: false,
+		},
+		"same limits": {
+			current: &cfgbroker.DeliveryOptions{
+				MaxConcurrency: &one,
+				RateLimit:      &cfgbroker.RateLimitRule{EventsPerSecond: 5},
+			},
+			incoming: &cfgbroker.DeliveryOptions{
+				MaxConcurrency: &one,
+				RateLimit:      &cfgbroker.RateLimitRule{EventsPerSecond: 5},
+			},
+			expected: true,
+		},
+		"concurrency changed": {
+			current:  &cfgbroker.DeliveryOptions{MaxConcurrency: &one},
+			incoming: &cfgbroker.DeliveryOptions{MaxConcurrency: &two},
+			expected: false,
+		},
+		"burst changed": {
+			current:  &cfgbroker.DeliveryOptions{RateLimit: &cfgbroker.RateLimitRule{EventsPerSecond: 5}},
+			incoming: &cfgbroker.DeliveryOptions{RateLimit: &cfgbroker.RateLimitRule{EventsPerSecond: 5, Burst: &one}},
+			expected: false,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			l := newDispatchLimiter(tc.current)
+			assert.Equal(t, tc.expected, l.equal(tc.incoming))
+		})
+	}
+}
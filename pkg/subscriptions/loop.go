@@ -0,0 +1,95 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"fmt"
+	"reflect"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/common/hops"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// updateLoopProtection sets the broker wide loop protection settings.
+func (s *subscriber) updateLoopProtection(lp *cfgbroker.LoopProtection) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if reflect.DeepEqual(s.loop, lp) {
+		return
+	}
+	s.loop = lp
+}
+
+// withHops returns a copy of the event that informs its hop count and
+// the list of triggers that delivered it, including this one.
+func (s *subscriber) withHops(event *cloudevents.Event) *cloudevents.Event {
+	n, _, err := hops.Count(event)
+	if err != nil {
+		s.logger.Warnw("Resetting hop count for event", zap.Error(err),
+			zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
+	}
+
+	e := event.Clone()
+	if err := hops.Set(&e, n, append(hops.Triggers(event), s.name)); err != nil {
+		s.logger.Errorw("Could not inform hops for event", zap.Error(err),
+			zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
+		return event
+	}
+
+	return &e
+}
+
+// replyHops returns a copy of the reply that inherits the hops from the
+// delivered event, and whether it exceeds the hop limit. Replies that are
+// re-ingested increment the hop count, forwarded replies are counted by
+// the receiving broker.
+func (s *subscriber) replyHops(event, reply *cloudevents.Event, ingest bool) (*cloudevents.Event, bool) {
+	// The delivered event count has already been validated.
+	n, _, _ := hops.Count(event)
+	if ingest {
+		n++
+	}
+
+	r := reply.Clone()
+	if err := hops.Set(&r, n, hops.Triggers(event)); err != nil {
+		s.logger.Errorw("Could not inform hops for response", zap.Error(err),
+			zap.String("type", reply.Type()), zap.String("source", reply.Source()), zap.String("id", reply.ID()))
+		return reply, false
+	}
+
+	return &r, n > s.loop.MaxHops
+}
+
+// loopDetected handles a reply that exceeds the hop limit according to the
+// loop protection action. The delivery of the event that produced the reply
+// is considered successful.
+func (s *subscriber) loopDetected(reply *cloudevents.Event, from string) bool {
+	if s.reporter != nil {
+		s.reporter.ReportLoopDetected(reply.Type())
+	}
+
+	triggers := hops.Triggers(reply)
+
+	if s.loop.GetAction() != cfgbroker.LoopActionDeadLetter {
+		s.logger.Warnw(fmt.Sprintf("Event loop detected, dropping response from %s", from),
+			zap.Strings("triggers", triggers), zap.Int("maxHops", s.loop.MaxHops),
+			zap.String("type", reply.Type()), zap.String("source", reply.Source()), zap.String("id", reply.ID()))
+		return true
+	}
+
+	s.logger.Warnw(fmt.Sprintf("Event loop detected, dead lettering response from %s", from),
+		zap.Strings("triggers", triggers), zap.Int("maxHops", s.loop.MaxHops),
+		zap.String("type", reply.Type()), zap.String("source", reply.Source()), zap.String("id", reply.ID()))
+
+	report := &deliveryReport{}
+	if !s.deadLetter(reply, nil, report) {
+		s.lostEvent(reply, nil, report)
+	}
+
+	return true
+}
@@ -0,0 +1,127 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/triggermesh/brokers/pkg/common/hops"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+func TestLoopProtection(t *testing.T) {
+	testCases := map[string]struct {
+		hops   *int
+		action cfgbroker.LoopAction
+
+		expectedDeliveredHops string
+		expectedProduced      int
+		expectedReplyHops     int
+		expectedDeadLettered  int
+		expectedLoops         int
+	}{
+		"first delivery": {
+			expectedDeliveredHops: "0",
+			expectedProduced:      1,
+			expectedReplyHops:     1,
+		},
+		"under the limit": {
+			hops:                  intPtr(1),
+			expectedDeliveredHops: "1",
+			expectedProduced:      1,
+			expectedReplyHops:     2,
+		},
+		"over the limit dropped": {
+			hops:                  intPtr(2),
+			expectedDeliveredHops: "2",
+			expectedLoops:         1,
+		},
+		"over the limit dead lettered": {
+			hops:                  intPtr(2),
+			action:                cfgbroker.LoopActionDeadLetter,
+			expectedDeliveredHops: "2",
+			expectedDeadLettered:  1,
+			expectedLoops:         1,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			// The target replies with the same event, which would
+			// make it loop indefinitely.
+			target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, tc.expectedDeliveredHops, r.Header.Get("Ce-"+hops.CountExtension))
+				assert.Equal(t, "test-subscriber", r.Header.Get("Ce-"+hops.TriggersExtension))
+				for k, v := range r.Header {
+					w.Header()[k] = v
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer target.Close()
+
+			deadLettered := 0
+			dls := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				deadLettered++
+				w.WriteHeader(http.StatusAccepted)
+			}))
+			defer dls.Close()
+
+			client, err := cloudevents.NewClientHTTP()
+			require.NoError(t, err)
+
+			b := &replyBackend{}
+			reporter := &lostReporter{}
+			s := subscriber{
+				name:      "test-subscriber",
+				backend:   b,
+				ceClient:  client,
+				reporter:  reporter,
+				parentCtx: context.Background(),
+				logger:    zaptest.NewLogger(t).Sugar(),
+			}
+
+			do := deliveryOptions(0, "PT0S")
+			do.DeadLetterURL = &dls.URL
+			require.NoError(t, s.updateTrigger(cfgbroker.Trigger{
+				Target:          cfgbroker.Target{URL: &target.URL},
+				DeliveryOptions: &do,
+			}))
+			s.updateLoopProtection(&cfgbroker.LoopProtection{MaxHops: 2, Action: tc.action})
+
+			event := cloudevents.NewEvent()
+			event.SetID("1")
+			event.SetType("test.type")
+			event.SetSource("test.source")
+			if tc.hops != nil {
+				require.NoError(t, hops.Set(&event, *tc.hops, nil))
+			}
+
+			s.dispatchCloudEvent(&event)
+
+			require.Len(t, b.produced, tc.expectedProduced)
+			if tc.expectedProduced != 0 {
+				n, ok, err := hops.Count(b.produced[0])
+				require.NoError(t, err)
+				assert.True(t, ok)
+				assert.Equal(t, tc.expectedReplyHops, n)
+				assert.Equal(t, []string{"test-subscriber"}, hops.Triggers(b.produced[0]))
+			}
+			assert.Equal(t, tc.expectedDeadLettered, deadLettered)
+			assert.Equal(t, tc.expectedLoops, reporter.loops)
+			assert.Empty(t, reporter.lost)
+		})
+	}
+}
+
+func intPtr(i int) *int {
+	return &i
+}
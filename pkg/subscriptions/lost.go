@@ -0,0 +1,50 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"net/url"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"go.uber.org/zap"
+)
+
+// LostEventSink is the last resort storage for events that could not be
+// delivered to the target nor the dead letter sink.
+type LostEventSink func(ctx context.Context, trigger string, event *cloudevents.Event) error
+
+// lostEvent handles an event that could not be delivered to the target nor
+// the dead letter sink, storing it at the lost events sink if configured.
+func (s *subscriber) lostEvent(event *cloudevents.Event, target *url.URL, report *deliveryReport) {
+	persisted := false
+	if s.lostSink != nil {
+		if err := s.lostSink(s.parentCtx, s.name, s.withErrorExtensions(event, target, report)); err != nil {
+			s.logger.Errorw("Could not store event at the lost events sink", zap.Error(err),
+				zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
+		} else {
+			persisted = true
+		}
+	}
+
+	if s.reporter != nil {
+		s.reporter.ReportLostEvent(event.Type(), persisted)
+	}
+
+	msg := "Event was lost"
+	if target != nil {
+		msg += " while sending to " + target.String()
+	}
+
+	if persisted {
+		s.logger.Warnw(msg+", stored at the lost events sink",
+			zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
+		return
+	}
+
+	// If the event could not be stored either just write a log entry. Set the attribute
+	// `lost: true` to help log aggregators identify lost events by querying.
+	s.logger.Errorw(msg, zap.Bool("lost", true),
+		zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
+}
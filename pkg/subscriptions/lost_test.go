@@ -0,0 +1,96 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+type lostReporter struct {
+	lost  []bool
+	loops int
+}
+
+func (r *lostReporter) ReportTriggeredEvent(bool, string, string, float64) {}
+
+func (r *lostReporter) ReportLostEvent(_ string, persisted bool) {
+	r.lost = append(r.lost, persisted)
+}
+
+func (r *lostReporter) ReportLoopDetected(string) {
+	r.loops++
+}
+
+func TestLostEventSink(t *testing.T) {
+	testCases := map[string]struct {
+		sinkErr error
+
+		expectedPersisted bool
+	}{
+		"persisted": {
+			expectedPersisted: true,
+		},
+		"sink failed": {
+			sinkErr:           errors.New("disk full"),
+			expectedPersisted: false,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			}))
+			defer target.Close()
+
+			client, err := cloudevents.NewClientHTTP()
+			require.NoError(t, err)
+
+			var stored []*cloudevents.Event
+			reporter := &lostReporter{}
+			s := subscriber{
+				name:      "test-subscriber",
+				ceClient:  client,
+				reporter:  reporter,
+				parentCtx: context.Background(),
+				logger:    zaptest.NewLogger(t).Sugar(),
+				lostSink: func(_ context.Context, trigger string, event *cloudevents.Event) error {
+					assert.Equal(t, "test-subscriber", trigger)
+					stored = append(stored, event)
+					return tc.sinkErr
+				},
+			}
+
+			do := deliveryOptions(0, "PT0S")
+			err = s.updateTrigger(cfgbroker.Trigger{
+				Target:          cfgbroker.Target{URL: &target.URL},
+				DeliveryOptions: &do,
+			})
+			require.NoError(t, err)
+
+			event := cloudevents.NewEvent()
+			event.SetID("1")
+			event.SetType("test.type")
+			event.SetSource("test.source")
+
+			s.dispatchCloudEvent(&event)
+
+			require.Len(t, stored, 1, "Event not sent to the lost events sink")
+			assert.Equal(t, "test-subscriber", stored[0].Extensions()[ErrorTriggerExtension],
+				"Lost event does not include the failure details")
+			assert.Equal(t, []bool{tc.expectedPersisted}, reporter.lost)
+		})
+	}
+}
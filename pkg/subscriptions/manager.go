@@ -6,11 +6,16 @@ package subscriptions
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"reflect"
 	"sync"
+	"time"
 
 	obshttp "github.com/cloudevents/sdk-go/observability/opencensus/v2/http"
 	ceclient "github.com/cloudevents/sdk-go/v2/client"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/plugin/ochttp/propagation/tracecontext"
 	"go.uber.org/zap"
 
 	"knative.dev/pkg/logging"
@@ -21,6 +26,16 @@ import (
 	"github.com/triggermesh/brokers/pkg/subscriptions/metrics"
 )
 
+const (
+	// resubscribeRetryDelay is the time to wait before retrying
+	// a failed re-subscription.
+	resubscribeRetryDelay = 10 * time.Second
+
+	// orphanSweepTimeout bounds looking for orphaned
+	// subscriptions at the backend.
+	orphanSweepTimeout = 30 * time.Second
+)
+
 type Subscription struct {
 	Trigger cfgbroker.Trigger
 }
@@ -34,21 +49,68 @@ type Manager struct {
 	// Subscribers map indexed by name
 	subscribers map[string]*subscriber
 
+	// failures contains the error message for triggers
+	// that could not be setup, indexed by name.
+	failures map[string]string
+
+	// resubscriptions contains the triggers whose backend subscription
+	// could not be re-created, indexed by name.
+	resubscriptions map[string]*pendingResubscription
+
+	// lostSink stores events that could not be delivered nor
+	// dead lettered, nil when not configured.
+	lostSink LostEventSink
+
+	// loop contains the broker wide loop protection
+	// settings, nil when disabled.
+	loop *cfgbroker.LoopProtection
+
+	// swept is set once orphaned subscriptions have been
+	// looked for at the backend.
+	swept bool
+
 	ctx context.Context
 	m   sync.RWMutex
 }
 
-func New(inctx context.Context, logger *zap.SugaredLogger, be backend.Interface, statusManager status.Manager) (*Manager, error) {
+// pendingResubscription is a re-subscription that failed
+// and is retried.
+type pendingResubscription struct {
+	// reset informs if the consumer position must be moved
+	// to the start bound.
+	reset bool
+	timer *time.Timer
+}
+
+type ManagerOption func(*Manager)
+
+// WithLostEventSink sets the last resort storage for events that
+// could not be delivered to the target nor the dead letter sink.
+func WithLostEventSink(sink LostEventSink) ManagerOption {
+	return func(m *Manager) {
+		m.lostSink = sink
+	}
+}
+
+func New(inctx context.Context, logger *zap.SugaredLogger, be backend.Interface, statusManager status.Manager, opts ...ManagerOption) (*Manager, error) {
 	// Needed for Knative filters
 	ctx := logging.WithLogger(inctx, logger)
 
-	return &Manager{
-		backend:       be,
-		subscribers:   make(map[string]*subscriber),
-		logger:        logger,
-		statusManager: statusManager,
-		ctx:           ctx,
-	}, nil
+	m := &Manager{
+		backend:         be,
+		subscribers:     make(map[string]*subscriber),
+		failures:        make(map[string]string),
+		resubscriptions: make(map[string]*pendingResubscription),
+		logger:          logger,
+		statusManager:   statusManager,
+		ctx:             ctx,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m, nil
 }
 
 func (m *Manager) UpdateFromConfig(c *cfgbroker.Config) {
@@ -56,11 +118,19 @@ func (m *Manager) UpdateFromConfig(c *cfgbroker.Config) {
 	m.m.Lock()
 	defer m.m.Unlock()
 
+	m.loop = c.LoopProtection
+
 	for name, sub := range m.subscribers {
 		if _, ok := c.Triggers[name]; !ok {
 			m.logger.Infow("Deleting subscription", zap.String("name", name))
 			sub.unsubscribe()
 			delete(m.subscribers, name)
+			delete(m.failures, name)
+			m.cancelResubscription(name)
+
+			if c, ok := m.backend.(backend.SubscriptionCleaner); ok {
+				c.RemoveSubscription(name)
+			}
 
 			if m.statusManager != nil {
 				m.statusManager.EnsureNoSubscription(name)
@@ -73,8 +143,9 @@ func (m *Manager) UpdateFromConfig(c *cfgbroker.Config) {
 		if !ok {
 			s, err := m.createSubscriber(name, trigger)
 			if err != nil {
-				msg := "Failed to create trigger subscription"
-				m.logger.Errorw(msg, zap.String("trigger", name), zap.Error(err))
+				m.logger.Errorw("Failed to create trigger subscription", zap.String("trigger", name), zap.Error(err))
+				msg := "Failed to create trigger subscription: " + err.Error()
+				m.failures[name] = msg
 				if m.statusManager != nil {
 					m.statusManager.EnsureSubscription(name, &status.SubscriptionStatus{
 						Status:  status.SubscriptionStatusFailed,
@@ -94,24 +165,92 @@ func (m *Manager) UpdateFromConfig(c *cfgbroker.Config) {
 			}
 
 			m.subscribers[name] = s
+			delete(m.failures, name)
 			m.logger.Infow("Subscription for trigger updated", zap.String("name", name))
 			continue
 		}
 
-		if reflect.DeepEqual(s.trigger, trigger) {
-			// If there are no changes to the subscription, skip.
-			continue
+		s.updateLoopProtection(c.LoopProtection)
+
+		pending, resubscribe := m.resubscriptions[name]
+		reset := resubscribe && pending.reset
+
+		if !reflect.DeepEqual(s.trigger, trigger) {
+			// Update existing subscription with new data.
+			m.logger.Infow("Updating subscription upon trigger configuration", zap.String("name", name), zap.Any("trigger", trigger))
+
+			// Bounds are applied by the backend when subscribing, the consumer
+			// position is only moved when the start bound changes.
+			if !reflect.DeepEqual(s.trigger.Bounds, trigger.Bounds) {
+				resubscribe = true
+				reset = reset || startBoundChanged(s.trigger.Bounds, trigger.Bounds)
+			}
+
+			if err := s.updateTrigger(trigger); err != nil {
+				m.logger.Errorw("Could not setup trigger", zap.String("name", name), zap.Error(err))
+				msg := "Could not setup trigger: " + err.Error()
+				m.failures[name] = msg
+				s.setFailure(msg)
+				if m.statusManager != nil {
+					m.statusManager.EnsureSubscription(name, &status.SubscriptionStatus{
+						Status:  status.SubscriptionStatusFailed,
+						Message: &msg,
+					})
+				}
+				continue
+			}
+		}
+
+		if resubscribe {
+			if err := m.resubscribe(s, reset); err != nil {
+				m.resubscribeFailed(name, reset, err)
+				continue
+			}
+		}
+
+		// A previous failed update is not in effect anymore.
+		delete(m.failures, name)
+		if s.clearFailure() && m.statusManager != nil {
+			m.statusManager.EnsureSubscription(name, &status.SubscriptionStatus{
+				Status: status.SubscriptionStatusRunning,
+			})
+		}
+	}
+
+	// Triggers that failed creation and were removed from the
+	// configuration are no longer reported.
+	for name := range m.failures {
+		if _, ok := c.Triggers[name]; !ok {
+			delete(m.failures, name)
 		}
+	}
 
-		// Update existing subscription with new data.
-		m.logger.Infow("Updating subscription upon trigger configuration", zap.String("name", name), zap.Any("trigger", trigger))
-		if err := s.updateTrigger(trigger); err != nil {
-			m.logger.Errorw("Could not setup trigger", zap.String("name", name), zap.Error(err))
-			return
+	// Backend state for triggers that were deleted while the broker was
+	// not running is discarded at startup. The backend might be slow to
+	// respond, the lookup does not block configuration updates.
+	if !m.swept {
+		m.swept = true
+		if cl, ok := m.backend.(backend.SubscriptionCleaner); ok {
+			names := make([]string, 0, len(c.Triggers))
+			for name := range c.Triggers {
+				names = append(names, name)
+			}
+			go m.removeOrphanedSubscriptions(cl, names)
 		}
 	}
 }
 
+// removeOrphanedSubscriptions discards the backend state for subscriptions
+// that do not belong to any of the informed triggers.
+func (m *Manager) removeOrphanedSubscriptions(cl backend.SubscriptionCleaner, names []string) {
+	ctx, cancel := context.WithTimeout(m.ctx, orphanSweepTimeout)
+	defer cancel()
+
+	if err := cl.RemoveOrphanedSubscriptions(ctx, names); err != nil {
+		m.logger.Errorw("Could not remove orphaned subscriptions from the backend", zap.Error(err))
+	}
+}
+
 func (m *Manager) createSubscriber(name string, trigger cfgbroker.Trigger) (*subscriber, error) {
 	// Create CloudEvents client with reporter for Trigger.
 	ir, err := metrics.NewReporter(m.ctx, name)
@@ -119,7 +258,16 @@ func (m *Manager) createSubscriber(name string, trigger cfgbroker.Trigger) (*sub
 		return nil, fmt.Errorf("failed to setup trigger stats reporter: %w", err)
 	}
 
-	p, err := obshttp.NewObservedHTTP()
+	// The TLS dialer applies the trigger's TLS settings to connections to
+	// the target. The transport is wrapped for tracing the same way the
+	// observed protocol does for the default transport, and keeps response
+	// headers used for retrying.
+	d := newTLSDialer()
+	p, err := obshttp.NewObservedHTTP(cehttp.WithRoundTripper(&ochttp.Transport{
+		Propagation:    &tracecontext.HTTPFormat{},
+		Base:           &responseInfoTransport{base: d.transport},
+		FormatSpanName: func(r *http.Request) string { return "cloudevents.http." + r.URL.Path },
+	}))
 	if err != nil {
 		return nil, fmt.Errorf("could not create CloudEvents HTTP protocol: %w", err)
 	}
@@ -130,12 +278,17 @@ func (m *Manager) createSubscriber(name string, trigger cfgbroker.Trigger) (*sub
 	}
 
 	s := &subscriber{
-		name:          name,
-		backend:       m.backend,
-		statusManager: m.statusManager,
-		ceClient:      ceClient,
-		parentCtx:     m.ctx,
-		logger:        m.logger,
+		name:           name,
+		backend:        m.backend,
+		statusManager:  m.statusManager,
+		ceClient:       ceClient,
+		reporter:       ir,
+		lostSink:       m.lostSink,
+		loop:           m.loop,
+		sharedDispatch: m.backend.Info().SharedDispatch,
+		tlsDialer:      d,
+		parentCtx:      m.ctx,
+		logger:         m.logger,
 	}
 
 	m.logger.Infow("Creating new subscription from trigger configuration", zap.String("name", name), zap.Any("trigger", trigger))
@@ -143,9 +296,128 @@ func (m *Manager) createSubscriber(name string, trigger cfgbroker.Trigger) (*sub
 		return nil, fmt.Errorf("could not setup trigger: %w", err)
 	}
 
-	if err := m.backend.Subscribe(name, trigger.Bounds, s.dispatchCloudEvent, s.statusChange); err != nil {
+	if err := m.subscribe(s, trigger.Bounds); err != nil {
 		return nil, fmt.Errorf("could not create subscription for trigger: %w", err)
 	}
 
 	return s, nil
 }
+
+// subscribe creates the backend subscription for the subscriber.
+func (m *Manager) subscribe(s *subscriber, bounds *cfgbroker.TriggerBounds, opts ...backend.SubscribeOption) error {
+	opts = append([]backend.SubscribeOption{
+		backend.WithConsumerGate(s.waitConsumption),
+		backend.WithConsumerLimiter(s.acquireDispatch),
+	}, opts...)
+
+	return m.backend.Subscribe(s.name, bounds, s.dispatchCloudEvent, s.statusChange, opts...)
+}
+
+// resubscribe re-creates the backend subscription for a trigger whose bounds
+// changed, optionally moving the consumer position to the new start bound.
+func (m *Manager) resubscribe(s *subscriber, reset bool) error {
+	m.logger.Infow("Re-subscribing upon trigger bounds change", zap.String("name", s.name),
+		zap.Any("bounds", s.trigger.Bounds), zap.Bool("reset", reset))
+
+	s.unsubscribe()
+	if err := s.resetCircuitBreaker(); err != nil {
+		return fmt.Errorf("could not reset circuit breaker: %w", err)
+	}
+
+	var opts []backend.SubscribeOption
+	if reset {
+		opts = append(opts, backend.WithResetPosition())
+	}
+	if err := m.subscribe(s, s.trigger.Bounds, opts...); err != nil {
+		return fmt.Errorf("could not create subscription for trigger: %w", err)
+	}
+
+	m.cancelResubscription(s.name)
+
+	if m.statusManager != nil {
+		// Same as new subscriptions the status changes to Running
+		// when the first event is processed.
+		msg := "Subscription restarted after bounds change"
+		m.statusManager.EnsureSubscription(s.name, &status.SubscriptionStatus{
+			Status:  status.SubscriptionStatusReady,
+			Message: &msg,
+		})
+	}
+
+	return nil
+}
+
+// resubscribeFailed reports the failure and schedules a retry for the
+// re-subscription, keeping the position reset if it was requested.
+// Must be called with the manager lock held.
+func (m *Manager) resubscribeFailed(name string, reset bool, err error) {
+	m.logger.Errorw("Could not re-subscribe trigger", zap.String("name", name), zap.Error(err))
+	msg := "Could not re-subscribe trigger: " + err.Error()
+	m.failures[name] = msg
+	if m.statusManager != nil {
+		m.statusManager.EnsureSubscription(name, &status.SubscriptionStatus{
+			Status:  status.SubscriptionStatusFailed,
+			Message: &msg,
+		})
+	}
+
+	if p, ok := m.resubscriptions[name]; ok {
+		p.reset = reset
+		p.timer.Reset(resubscribeRetryDelay)
+		return
+	}
+
+	m.resubscriptions[name] = &pendingResubscription{
+		reset: reset,
+		timer: time.AfterFunc(resubscribeRetryDelay, func() {
+			m.retryResubscription(name)
+		}),
+	}
+}
+
+// retryResubscription re-creates a subscription that previously failed.
+func (m *Manager) retryResubscription(name string) {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	p, ok := m.resubscriptions[name]
+	if !ok || m.ctx.Err() != nil {
+		return
+	}
+
+	s, ok := m.subscribers[name]
+	if !ok {
+		m.cancelResubscription(name)
+		return
+	}
+
+	if err := m.resubscribe(s, p.reset); err != nil {
+		m.resubscribeFailed(name, p.reset, err)
+		return
+	}
+	delete(m.failures, name)
+}
+
+// cancelResubscription stops retrying a failed re-subscription.
+// Must be called with the manager lock held.
+func (m *Manager) cancelResubscription(name string) {
+	if p, ok := m.resubscriptions[name]; ok {
+		p.timer.Stop()
+		delete(m.resubscriptions, name)
+	}
+}
+
+// startBoundChanged returns whether the start bound differs
+// between two trigger bounds.
+func startBoundChanged(prev, next *cfgbroker.TriggerBounds) bool {
+	start := func(b *cfgbroker.TriggerBounds) (string, string) {
+		if b == nil {
+			return "", ""
+		}
+		return b.ByID.GetStart(), b.ByDate.GetStart()
+	}
+
+	prevID, prevDate := start(prev)
+	nextID, nextDate := start(next)
+	return prevID != nextID || prevDate != nextDate
+}
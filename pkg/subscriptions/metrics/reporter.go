@@ -23,12 +23,14 @@ const (
 	LabelDelivered     = "delivered"
 	LabelSentEventType = "sent_type"
 	LabelTrigger       = "trigger_name"
+	LabelPersisted     = "persisted"
 )
 
 var (
 	sentEventTypeKey  = tag.MustNewKey(LabelSentEventType)
 	deliveredEventKey = tag.MustNewKey(LabelDelivered)
 	triggerKey        = tag.MustNewKey(LabelTrigger)
+	persistedKey      = tag.MustNewKey(LabelPersisted)
 
 	// eventCountM is a counter which records the number of events received
 	// by the Broker.
@@ -44,6 +46,22 @@ var (
 		"trigger/event_latency",
 		"The latency in milliseconds for the broker Trigger subscriptions.",
 		"ms")
+
+	// lostCountM is a counter which records the number of events that
+	// could not be delivered to the target nor the dead letter sink.
+	lostCountM = stats.Int64(
+		"trigger/lost_count",
+		"Number of events that could not be delivered via Trigger subscription nor dead lettered.",
+		stats.UnitDimensionless,
+	)
+
+	// loopCountM is a counter which records the number of replies that
+	// exceeded the loop protection hop limit.
+	loopCountM = stats.Int64(
+		"trigger/loop_count",
+		"Number of replies via Trigger subscription that exceeded the hop limit.",
+		stats.UnitDimensionless,
+	)
 )
 
 func registerStatViews() error {
@@ -68,6 +86,20 @@ func registerStatViews() error {
 			Aggregation: view.Count(),
 			TagKeys:     tagKeys,
 		},
+		&view.View{
+			Name:        lostCountM.Name(),
+			Description: lostCountM.Description(),
+			Measure:     lostCountM,
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{triggerKey, sentEventTypeKey, persistedKey},
+		},
+		&view.View{
+			Name:        loopCountM.Name(),
+			Description: loopCountM.Description(),
+			Measure:     loopCountM,
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{triggerKey, sentEventTypeKey},
+		},
 	)
 }
 
@@ -77,6 +109,8 @@ func initContext(ctx context.Context, triggerName string) (context.Context, erro
 
 type Reporter interface {
 	ReportTriggeredEvent(delivered bool, sentType, receivedType string, msLatency float64)
+	ReportLostEvent(sentType string, persisted bool)
+	ReportLoopDetected(sentType string)
 }
 
 // Reporter holds cached metric objects to report ingress metrics.
@@ -123,3 +157,24 @@ func (r *reporter) ReportTriggeredEvent(delivered bool, sentType, receivedType s
 	knmetrics.Record(ctx, latencyMs.M(msLatency), stats.WithTags(tag.Insert(metrics.ReceivedEventTypeKey, receivedType)))
 	knmetrics.Record(ctx, eventCountM.M(1))
 }
+
+func (r *reporter) ReportLostEvent(sentType string, persisted bool) {
+	ctx, err := tag.New(r.ctx,
+		tag.Insert(sentEventTypeKey, sentType),
+		tag.Insert(persistedKey, strconv.FormatBool(persisted)),
+	)
+	if err != nil {
+		r.logger.Errorw("error setting tags to OpenCensus context", zap.Error(err))
+	}
+
+	knmetrics.Record(ctx, lostCountM.M(1))
+}
+
+func (r *reporter) ReportLoopDetected(sentType string) {
+	ctx, err := tag.New(r.ctx, tag.Insert(sentEventTypeKey, sentType))
+	if err != nil {
+		r.logger.Errorw("error setting tags to OpenCensus context", zap.Error(err))
+	}
+
+	knmetrics.Record(ctx, loopCountM.M(1))
+}
@@ -0,0 +1,74 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"fmt"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"go.uber.org/zap"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// handleReply applies the trigger's reply policy to an event returned by
+// the target, returning whether the delivery can be considered successful.
+func (s *subscriber) handleReply(ctx context.Context, event, reply *cloudevents.Event) bool {
+	from := cloudevents.TargetFromContext(ctx).String()
+	policy := s.trigger.Reply.GetPolicy()
+
+	if s.loop != nil && policy != cfgbroker.ReplyPolicyDiscard {
+		var exceeded bool
+		reply, exceeded = s.replyHops(event, reply, policy != cfgbroker.ReplyPolicyForward)
+		if exceeded {
+			return s.loopDetected(reply, from)
+		}
+	}
+
+	switch policy {
+	case cfgbroker.ReplyPolicyDiscard:
+		s.logger.Debugw(fmt.Sprintf("Discarding response from %s", from),
+			zap.String("type", reply.Type()), zap.String("source", reply.Source()), zap.String("id", reply.ID()))
+		return true
+
+	case cfgbroker.ReplyPolicyForward:
+		return s.forwardReply(reply, from)
+
+	case cfgbroker.ReplyPolicyIngestBestEffort:
+		if err := s.backend.Produce(ctx, reply); err != nil {
+			s.logger.Warnw(fmt.Sprintf("Failed to consume response from %s, the delivery is not affected", from),
+				zap.Error(err), zap.String("type", reply.Type()), zap.String("source", reply.Source()), zap.String("id", reply.ID()))
+		}
+		return true
+	}
+
+	if err := s.backend.Produce(ctx, reply); err != nil {
+		s.logger.Errorw(fmt.Sprintf("Failed to consume response from %s", from),
+			zap.Error(err), zap.String("type", reply.Type()), zap.String("source", reply.Source()), zap.String("id", reply.ID()))
+
+		// Not ingesting the response is considered an error.
+		return false
+	}
+
+	return true
+}
+
+// forwardReply sends the reply to the trigger's reply URL. Target
+// authentication headers are not sent along with the reply.
+func (s *subscriber) forwardReply(reply *cloudevents.Event, from string) bool {
+	ctx, cancel := s.delivery.attemptContext(
+		cloudevents.ContextWithTarget(s.parentCtx, *s.trigger.Reply.URL))
+	defer cancel()
+
+	result := s.ceClient.Send(ctx, *reply)
+	if code := statusCode(result); !cloudevents.IsACK(result) || (code != 0 && code/100 != 2) {
+		s.logger.Errorw(fmt.Sprintf("Failed to forward response from %s to %s", from, *s.trigger.Reply.URL),
+			zap.Error(result), zap.Int("statusCode", code),
+			zap.String("type", reply.Type()), zap.String("source", reply.Source()), zap.String("id", reply.ID()))
+		return false
+	}
+
+	return true
+}
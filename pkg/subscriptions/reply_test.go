@@ -0,0 +1,145 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// replyBackend is a backend that only implements producing events.
+type replyBackend struct {
+	backend.Interface
+
+	err      error
+	produced []*cloudevents.Event
+}
+
+func (b *replyBackend) Produce(_ context.Context, event *cloudevents.Event) error {
+	b.produced = append(b.produced, event)
+	return b.err
+}
+
+func TestReplyPolicy(t *testing.T) {
+	testCases := map[string]struct {
+		policy     cfgbroker.ReplyPolicyType
+		produceErr error
+		forwardErr bool
+
+		expectedDelivered bool
+		expectedProduced  int
+		expectedForwarded int
+	}{
+		"default ingest": {
+			expectedDelivered: true,
+			expectedProduced:  1,
+		},
+		"ingest failed": {
+			policy:            cfgbroker.ReplyPolicyIngest,
+			produceErr:        errors.New("backend not available"),
+			expectedDelivered: false,
+			expectedProduced:  1,
+		},
+		"ingest best effort failed": {
+			policy:            cfgbroker.ReplyPolicyIngestBestEffort,
+			produceErr:        errors.New("backend not available"),
+			expectedDelivered: true,
+			expectedProduced:  1,
+		},
+		"discard": {
+			policy:            cfgbroker.ReplyPolicyDiscard,
+			expectedDelivered: true,
+		},
+		"forward": {
+			policy:            cfgbroker.ReplyPolicyForward,
+			expectedDelivered: true,
+			expectedForwarded: 1,
+		},
+		"forward failed": {
+			policy:            cfgbroker.ReplyPolicyForward,
+			forwardErr:        true,
+			expectedDelivered: false,
+			expectedForwarded: 1,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "Bearer t0k3n", r.Header.Get("Authorization"))
+				w.Header().Set("Ce-Specversion", "1.0")
+				w.Header().Set("Ce-Id", "reply-1")
+				w.Header().Set("Ce-Type", "reply.type")
+				w.Header().Set("Ce-Source", "target")
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer target.Close()
+
+			forwarded := 0
+			forward := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Empty(t, r.Header.Get("Authorization"), "Target authentication sent along with the reply")
+				assert.Equal(t, "reply-1", r.Header.Get("Ce-Id"))
+				forwarded++
+				if tc.forwardErr {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.WriteHeader(http.StatusAccepted)
+			}))
+			defer forward.Close()
+
+			client, err := cloudevents.NewClientHTTP()
+			require.NoError(t, err)
+
+			token := "t0k3n"
+			b := &replyBackend{err: tc.produceErr}
+			s := subscriber{
+				name:      "test-subscriber",
+				backend:   b,
+				ceClient:  client,
+				parentCtx: context.Background(),
+				logger:    zaptest.NewLogger(t).Sugar(),
+			}
+
+			trigger := cfgbroker.Trigger{
+				Target: cfgbroker.Target{
+					URL:  &target.URL,
+					Auth: &cfgbroker.TargetAuth{Bearer: &cfgbroker.SecretValue{Value: &token}},
+				},
+			}
+			if tc.policy != "" {
+				trigger.Reply = &cfgbroker.Reply{Policy: tc.policy}
+				if tc.policy == cfgbroker.ReplyPolicyForward {
+					trigger.Reply.URL = &forward.URL
+				}
+			}
+			require.NoError(t, s.updateTrigger(trigger))
+
+			event := cloudevents.NewEvent()
+			event.SetID("1")
+			event.SetType("test.type")
+			event.SetSource("test.source")
+
+			h, err := s.auth.header()
+			require.NoError(t, err)
+
+			delivered, _ := s.deliver(cehttp.WithCustomHeader(s.ctx, h), &event)
+			assert.Equal(t, tc.expectedDelivered, delivered)
+			assert.Len(t, b.produced, tc.expectedProduced)
+			assert.Equal(t, tc.expectedForwarded, forwarded)
+		})
+	}
+}
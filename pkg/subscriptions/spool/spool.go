@@ -0,0 +1,144 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package spool
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+const (
+	entryExtension = ".json"
+	tmpPrefix      = "."
+)
+
+// Spool stores events at the local file system, using a directory for
+// each trigger and a file for each event.
+type Spool struct {
+	dir string
+	seq uint64
+}
+
+// New returns a spool that uses the directory, creating it if needed.
+func New(dir string) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("could not create spool directory %q: %w", dir, err)
+	}
+
+	return &Spool{dir: dir}, nil
+}
+
+func (s *Spool) triggerDir(trigger string) string {
+	return filepath.Join(s.dir, url.PathEscape(trigger))
+}
+
+// StoreLost writes the event to the trigger's spool directory. The event is
+// written to a temporary file that is renamed when complete, so that partial
+// writes are never replayed.
+func (s *Spool) StoreLost(_ context.Context, trigger string, event *cloudevents.Event) error {
+	b, err := event.MarshalJSON()
+	if err != nil {
+		return fmt.Errorf("could not serialize CloudEvent: %w", err)
+	}
+
+	dir := s.triggerDir(trigger)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return fmt.Errorf("could not create spool directory for trigger: %w", err)
+	}
+
+	// Entries are named after the time they were stored so
+	// that they sort in order.
+	name := fmt.Sprintf("%020d-%06d%s", time.Now().UnixNano(), atomic.AddUint64(&s.seq, 1)%1000000, entryExtension)
+
+	tmp := filepath.Join(dir, tmpPrefix+name)
+	if err := os.WriteFile(tmp, b, 0o640); err != nil {
+		return fmt.Errorf("could not write spool entry: %w", err)
+	}
+
+	if err := os.Rename(tmp, filepath.Join(dir, name)); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("could not write spool entry: %w", err)
+	}
+
+	return nil
+}
+
+// Triggers returns the names of the triggers that have spooled events.
+func (s *Spool) Triggers() ([]string, error) {
+	des, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read spool directory: %w", err)
+	}
+
+	triggers := []string{}
+	for _, de := range des {
+		if !de.IsDir() {
+			continue
+		}
+
+		t, err := url.PathUnescape(de.Name())
+		if err != nil {
+			continue
+		}
+		triggers = append(triggers, t)
+	}
+
+	return triggers, nil
+}
+
+// Entries returns the spooled entries for the trigger, oldest first.
+func (s *Spool) Entries(trigger string) ([]string, error) {
+	des, err := os.ReadDir(s.triggerDir(trigger))
+	switch {
+	case os.IsNotExist(err):
+		return []string{}, nil
+	case err != nil:
+		return nil, fmt.Errorf("could not read spool directory for trigger: %w", err)
+	}
+
+	entries := []string{}
+	for _, de := range des {
+		name := de.Name()
+		if de.IsDir() || strings.HasPrefix(name, tmpPrefix) || !strings.HasSuffix(name, entryExtension) {
+			continue
+		}
+		entries = append(entries, name)
+	}
+
+	sort.Strings(entries)
+	return entries, nil
+}
+
+// Read returns the event stored at a spooled entry.
+func (s *Spool) Read(trigger, entry string) (*cloudevents.Event, error) {
+	b, err := os.ReadFile(filepath.Join(s.triggerDir(trigger), filepath.Base(entry)))
+	if err != nil {
+		return nil, fmt.Errorf("could not read spool entry: %w", err)
+	}
+
+	event := &cloudevents.Event{}
+	if err := event.UnmarshalJSON(b); err != nil {
+		return nil, fmt.Errorf("could not unmarshal spooled CloudEvent %s: %w", entry, err)
+	}
+
+	return event, nil
+}
+
+// Remove deletes a spooled entry.
+func (s *Spool) Remove(trigger, entry string) error {
+	if err := os.Remove(filepath.Join(s.triggerDir(trigger), filepath.Base(entry))); err != nil {
+		return fmt.Errorf("could not remove spool entry: %w", err)
+	}
+
+	return nil
+}
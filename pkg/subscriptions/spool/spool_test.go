@@ -0,0 +1,60 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package spool
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpool(t *testing.T) {
+	s, err := New(filepath.Join(t.TempDir(), "spool"))
+	require.NoError(t, err)
+
+	triggers, err := s.Triggers()
+	require.NoError(t, err)
+	assert.Empty(t, triggers)
+
+	entries, err := s.Entries("trigger/1")
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	for _, id := range []string{"1", "2", "3"} {
+		e := cloudevents.NewEvent()
+		e.SetID(id)
+		e.SetType("test.type")
+		e.SetSource("test.source")
+		require.NoError(t, s.StoreLost(context.Background(), "trigger/1", &e))
+	}
+
+	// Partially written entries are ignored.
+	require.NoError(t, os.WriteFile(filepath.Join(s.triggerDir("trigger/1"), tmpPrefix+"partial.json"), []byte("{"), 0o640))
+
+	triggers, err = s.Triggers()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"trigger/1"}, triggers)
+
+	entries, err = s.Entries("trigger/1")
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	ids := []string{}
+	for _, entry := range entries {
+		e, err := s.Read("trigger/1", entry)
+		require.NoError(t, err)
+		ids = append(ids, e.ID())
+	}
+	assert.Equal(t, []string{"1", "2", "3"}, ids, "Entries are not sorted by storage time")
+
+	require.NoError(t, s.Remove("trigger/1", entries[0]))
+	entries, err = s.Entries("trigger/1")
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
@@ -5,30 +5,79 @@ package subscriptions
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
 	"sync"
 	"time"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
-	"github.com/rickb777/date/period"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
 	"go.uber.org/zap"
 
 	"knative.dev/eventing/pkg/eventfilter"
 	"knative.dev/eventing/pkg/eventfilter/subscriptionsapi"
-	"knative.dev/pkg/logging"
 
 	"github.com/triggermesh/brokers/pkg/backend"
 	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
 	"github.com/triggermesh/brokers/pkg/status"
+	"github.com/triggermesh/brokers/pkg/subscriptions/metrics"
 )
 
 type subscriber struct {
 	trigger cfgbroker.Trigger
 
+	// filter is the materialized filter tree for the trigger. It is
+	// compiled once every time the trigger configuration changes.
+	filter eventfilter.Filter
+
+	// transformer applies the trigger's transform operations, nil
+	// when no transformation is configured.
+	transformer *transformer
+
+	// auth builds the authentication headers for the target,
+	// nil when no authentication is configured.
+	auth *targetAuth
+
+	// delivery contains the retry and timeout settings
+	// for sending events.
+	delivery *deliveryPolicy
+
+	// breaker pauses consumption when the target fails,
+	// nil when no circuit breaker is configured.
+	breaker *circuitBreaker
+	// sharedDispatch is set when the backend dispatches events for
+	// all subscriptions from a single reader, in which case waiting
+	// for the breaker would delay the rest of subscriptions.
+	sharedDispatch bool
+
+	// limiter throttles the events read from the backend,
+	// nil when no throttling is configured.
+	limiter *dispatchLimiter
+
+	// tlsDialer applies the target TLS settings to the
+	// subscriber's HTTP transport.
+	tlsDialer *tlsDialer
+
+	// loop limits the re-ingestion of replies, nil when
+	// loop protection is disabled.
+	loop *cfgbroker.LoopProtection
+
+	// failure is the error of the last trigger update that could not be
+	// applied, nil when the configuration in effect matches the trigger.
+	failure *string
+
 	name          string
 	backend       backend.Interface
 	statusManager status.Manager
 	ceClient      cloudevents.Client
+	reporter      metrics.Reporter
+
+	// lostSink stores events that could not be delivered nor
+	// dead lettered, nil when not configured.
+	lostSink LostEventSink
 
 	// We need to have both the parent context used to build the subscriber and the
 	// local context used to send CloudEvents that contains the target and delivery
@@ -38,11 +87,25 @@ type subscriber struct {
 	parentCtx context.Context
 	ctx       context.Context
 
+	// inflight keeps the start time of the dispatches being
+	// processed, used to detect stalled deliveries.
+	inflight    map[uint64]time.Time
+	dispatchSeq uint64
+	im          sync.Mutex
+
 	logger *zap.SugaredLogger
 	m      sync.RWMutex
 }
 
 func (s *subscriber) unsubscribe() {
+	// Dispatches waiting for the circuit breaker need to finish
+	// for the backend to unsubscribe.
+	s.m.RLock()
+	if s.breaker != nil {
+		s.breaker.release()
+	}
+	s.m.RUnlock()
+
 	s.backend.Unsubscribe(s.name)
 }
 
@@ -55,58 +118,147 @@ func (s *subscriber) updateTrigger(trigger cfgbroker.Trigger) error {
 	}
 	ctx := cloudevents.ContextWithTarget(s.parentCtx, url)
 
-	// HACK temporary to make the Delivery options move smooth,
-	// remove the method and access the field when the structure is
-	// completely migrated to having the delivery options at the root.
-	if do := trigger.GetDeliveryOptions(); do != nil &&
-		do.Retry != nil &&
-		*do.Retry >= 1 &&
-		do.BackoffPolicy != nil {
+	filters, err := materializeFiltersList(trigger.Filters)
+	if err != nil {
+		return fmt.Errorf("could not apply trigger %q configuration due to filter materialization: %w", s.name, err)
+	}
 
-		delay, err := period.Parse(*do.BackoffDelay)
-		if err != nil {
-			return fmt.Errorf("could not apply trigger %q configuration due to backoff delay parsing: %w", s.name, err)
-		}
+	tt, err := newTargetTLS(trigger.Target.TLS)
+	if err != nil {
+		return fmt.Errorf("could not apply trigger %q configuration due to TLS settings: %w", s.name, err)
+	}
 
-		switch *do.BackoffPolicy {
-		case cfgbroker.BackoffPolicyLinear:
-			ctx = cloudevents.ContextWithRetriesLinearBackoff(
-				ctx, delay.DurationApprox(), int(*do.Retry))
+	tr, err := newTransformer(trigger.Transform)
+	if err != nil {
+		return fmt.Errorf("could not apply trigger %q configuration due to transform: %w", s.name, err)
+	}
 
-		case cfgbroker.BackoffPolicyExponential:
-			ctx = cloudevents.ContextWithRetriesExponentialBackoff(
-				ctx, delay.DurationApprox(), int(*do.Retry))
+	// HACK temporary to make the Delivery options move smooth,
+	// remove the method and access the field when the structure is
+	// completely migrated to having the delivery options at the root.
+	dp, err := newDeliveryPolicy(trigger.GetDeliveryOptions())
+	if err != nil {
+		return fmt.Errorf("could not apply trigger %q configuration due to delivery options: %w", s.name, err)
+	}
 
-		default:
-			ctx = cloudevents.ContextWithRetriesConstantBackoff(
-				ctx, delay.DurationApprox(), int(*do.Retry))
-		}
+	// Subscriber settings are replaced only after all of them
+	// are successfully created.
+	s.m.RLock()
+	current := s.breaker
+	s.m.RUnlock()
+	cb, err := s.triggerCircuitBreaker(current, trigger.GetDeliveryOptions())
+	if err != nil {
+		return fmt.Errorf("could not apply trigger %q configuration due to circuit breaker: %w", s.name, err)
 	}
 
 	s.m.Lock()
 	defer s.m.Unlock()
 
+	s.filter = subscriptionsapi.NewAllFilter(filters...)
+	s.transformer = tr
+	// Keep the current authentication when not modified
+	// so that cached tokens are reused.
+	if !reflect.DeepEqual(s.trigger.Target.Auth, trigger.Target.Auth) {
+		s.auth = newTargetAuth(s.parentCtx, trigger.Target.Auth)
+	}
+	s.delivery = dp
 	s.trigger = trigger
+	s.setCircuitBreaker(cb)
+	// Keep the current limiter when not modified so that
+	// in flight dispatches are still accounted.
+	if !s.limiter.equal(trigger.GetDeliveryOptions()) {
+		s.limiter = newDispatchLimiter(trigger.GetDeliveryOptions())
+	}
+	if s.tlsDialer != nil {
+		s.tlsDialer.update(url, tt)
+	}
 	s.ctx = ctx
 
 	return nil
 }
 
 func (s *subscriber) dispatchCloudEvent(event *cloudevents.Event) {
+	// Events re-driven from the dead letter storage are only
+	// dispatched for their trigger.
+	trigger, redrive, err := redriveTarget(event)
+	switch {
+	case err != nil:
+		s.logger.Errorw("Skipped delivery of re-driven event", zap.Error(err),
+			zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
+		return
+	case redrive && trigger != s.name:
+		return
+	case redrive:
+		e := event.Clone()
+		e.SetExtension(RedriveExtension, nil)
+		event = &e
+	}
+
+	// Wait for the circuit breaker before tracking the dispatch, paused
+	// dispatches are not considered stalled.
+	s.m.RLock()
+	cb := s.breaker
+	s.m.RUnlock()
+
+	probe, open := false, false
+	switch {
+	case cb == nil:
+	case s.sharedDispatch:
+		// Events are not sent to the target while the breaker is open.
+		probe, open = cb.tryAcquire()
+	default:
+		var err error
+		if probe, err = cb.acquire(s.parentCtx); err != nil {
+			s.logger.Warnw("Sending event without waiting for the circuit breaker", zap.Error(err),
+				zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
+		}
+	}
+
+	defer s.trackDispatch()()
+
+	// Authentication headers might need requesting a token, which
+	// must not block trigger updates while holding the lock.
+	s.m.RLock()
+	auth := s.auth
+	s.m.RUnlock()
+
+	var authHeader http.Header
+	var authErr error
+	if auth != nil {
+		authHeader, authErr = auth.header()
+	}
+
 	s.m.RLock()
 	defer s.m.RUnlock()
 
+	// The probe is given back when the event is not sent to the target.
+	sent := false
+	if probe {
+		defer func() {
+			if !sent {
+				cb.cancelProbe()
+			}
+		}()
+	}
+
 	if s.statusManager != nil {
 		defer func() {
 			t := time.Now()
-			s.statusChange(&status.SubscriptionStatus{
+			ss := &status.SubscriptionStatus{
 				Status:        status.SubscriptionStatusRunning,
 				LastProcessed: &t,
-			})
+			}
+			// The trigger keeps the failed status while dispatching
+			// with the previous configuration.
+			if s.failure != nil {
+				ss.Status = status.SubscriptionStatusFailed
+				ss.Message = s.failure
+			}
+			s.statusChange(ss)
 		}()
 	}
 
-	res := subscriptionsapi.NewAllFilter(materializeFiltersList(s.ctx, s.trigger.Filters)...).Filter(s.ctx, *event)
+	res := s.filter.Filter(s.ctx, *event)
 	if res == eventfilter.FailFilter {
 		s.logger.Debugw("Skipped delivery due to filter", zap.Any("event", *event))
 		return
@@ -115,27 +267,202 @@ func (s *subscriber) dispatchCloudEvent(event *cloudevents.Event) {
 	// Only try to send if target URL has been configured. When not
 	// configured try to send to the dead letter sink.
 	url := cloudevents.TargetFromContext(s.ctx)
-	if url != nil && s.send(s.ctx, event) {
+
+	report := &deliveryReport{}
+	if open {
+		url = nil
+		report.err = errors.New("circuit breaker is open")
+	}
+
+	// Transform the event before sending it to the target. If the
+	// transformation fails the original event is sent to the dead letter sink.
+	outEvent := event
+	if url != nil && s.transformer != nil {
+		tev, err := s.transformer.transform(event)
+		if err != nil {
+			s.logger.Errorw("Could not transform event", zap.Error(err),
+				zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
+			// Skip sending to the target.
+			url = nil
+			report.err = fmt.Errorf("could not transform event: %w", err)
+		} else {
+			outEvent = tev
+		}
+	}
+
+	// Keep track of the hops and triggers for the event being
+	// delivered, replies are counted based on them.
+	if url != nil && s.loop != nil {
+		outEvent = s.withHops(outEvent)
+	}
+
+	// Authentication headers only apply to the target, not to the DLS.
+	ctx := s.ctx
+	if url != nil && auth != nil {
+		if authErr != nil {
+			s.logger.Errorw("Could not build authentication for target", zap.Error(authErr),
+				zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
+			// Skip sending to the target.
+			url = nil
+			report.err = fmt.Errorf("could not build authentication for target: %w", authErr)
+		} else {
+			ctx = cehttp.WithCustomHeader(ctx, authHeader)
+		}
+	}
+
+	if url != nil {
+		sent = true
+		var delivered bool
+		delivered, report = s.deliver(ctx, outEvent)
+		if cb != nil {
+			cb.result(delivered)
+		}
+		// A rejected token might have been revoked before expiring.
+		if report.statusCode == http.StatusUnauthorized && auth != nil {
+			auth.invalidate(authHeader)
+		}
+		if delivered {
+			return
+		}
+	}
+
+	if s.deadLetter(event, url, report) {
 		return
 	}
 
-	// If the event could not be sent (including retries), check for DLS
-	// and send if is it configured.
+	s.lostEvent(event, url, report)
+}
+
+// deadLetter sends the event to the trigger's backend dead letter storage
+// or dead letter sink, returning whether the event was accepted by any of
+// them. It must be called with the read lock held.
+func (s *subscriber) deadLetter(event *cloudevents.Event, target *url.URL, report *deliveryReport) bool {
+	// Check for the backend dead letter storage and store the event
+	// if it is configured.
+	if do := s.trigger.GetDeliveryOptions(); do != nil && do.DeadLetterBackend {
+		if s.produceDeadLetter(event, target, report) {
+			return true
+		}
+	}
+
+	// Check for DLS and send if is it configured.
 	if do := s.trigger.GetDeliveryOptions(); do != nil && do.DeadLetterURL != nil && *do.DeadLetterURL != "" {
-		dlsCtx := cloudevents.ContextWithTarget(s.parentCtx, *do.DeadLetterURL)
-		if s.send(dlsCtx, event) {
-			return
+		dlsEvent := event
+		if do.DeadLetterErrorExtensions {
+			dlsEvent = s.withErrorExtensions(event, target, report)
+		}
+
+		dlsCtx, cancel := s.delivery.attemptContext(
+			cloudevents.ContextWithTarget(s.parentCtx, *do.DeadLetterURL))
+		ok, _ := s.send(dlsCtx, dlsEvent)
+		cancel()
+		if ok {
+			return true
 		}
 	}
 
-	// If the event could not be sent either to the target or the DLS just write a log entry.
-	// Set the attribute `lost: true` to help log aggregators identify lost events by querying.
-	msg := "Event was lost"
-	if url != nil {
-		msg += " while sending to " + url.String()
+	return false
+}
+
+// triggerCircuitBreaker returns the circuit breaker for the delivery options,
+// which is the current one when its configuration does not change.
+func (s *subscriber) triggerCircuitBreaker(current *circuitBreaker, do *cfgbroker.DeliveryOptions) (*circuitBreaker, error) {
+	var cfg *cfgbroker.CircuitBreaker
+	if do != nil {
+		cfg = do.CircuitBreaker
+	}
+
+	if current != nil && cfg != nil && reflect.DeepEqual(current.cfg, *cfg) {
+		return current, nil
+	}
+
+	return newCircuitBreaker(cfg, s.circuitBreakerChange)
+}
+
+// setFailure records the error of a trigger update that could not be applied.
+func (s *subscriber) setFailure(msg string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.failure = &msg
+}
+
+// clearFailure removes the recorded trigger update error, returning
+// whether there was one.
+func (s *subscriber) clearFailure() bool {
+	s.m.Lock()
+	defer s.m.Unlock()
+	failed := s.failure != nil
+	s.failure = nil
+	return failed
+}
+
+// setCircuitBreaker replaces the circuit breaker, releasing the previous
+// one. It must be called with the lock held.
+func (s *subscriber) setCircuitBreaker(cb *circuitBreaker) {
+	if s.breaker == cb {
+		return
+	}
+
+	if s.breaker != nil {
+		s.breaker.release()
+		if cb == nil {
+			s.circuitBreakerChange(status.CircuitBreakerStateClosed)
+		}
+	}
+	s.breaker = cb
+}
+
+// resetCircuitBreaker replaces a circuit breaker that was released when
+// unsubscribing with a new one using the same configuration.
+func (s *subscriber) resetCircuitBreaker() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.breaker == nil {
+		return nil
+	}
+
+	cb, err := s.triggerCircuitBreaker(nil, s.trigger.GetDeliveryOptions())
+	if err != nil {
+		return err
+	}
+	s.breaker = cb
+	s.circuitBreakerChange(status.CircuitBreakerStateClosed)
+
+	return nil
+}
+
+func (s *subscriber) circuitBreakerChange(state status.CircuitBreakerStateChoice) {
+	s.logger.Infow("Circuit breaker state changed", zap.String("trigger", s.name), zap.String("state", string(state)))
+	s.statusChange(&status.SubscriptionStatus{
+		CircuitBreaker: state,
+	})
+}
+
+// waitConsumption blocks while the circuit breaker pauses
+// reading events from the backend.
+func (s *subscriber) waitConsumption(ctx context.Context) error {
+	s.m.RLock()
+	cb := s.breaker
+	s.m.RUnlock()
+
+	if cb == nil {
+		return nil
 	}
-	s.logger.Errorw(msg, zap.Bool("lost", true),
-		zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
+	return cb.waitConsumption(ctx)
+}
+
+// acquireDispatch blocks until the trigger's limiter accepts a new
+// event to be read from the backend.
+func (s *subscriber) acquireDispatch(ctx context.Context) (func(), error) {
+	s.m.RLock()
+	l := s.limiter
+	s.m.RUnlock()
+
+	if l == nil {
+		return func() {}, nil
+	}
+	return l.acquire(ctx)
 }
 
 func (s *subscriber) statusChange(ss *status.SubscriptionStatus) {
@@ -144,87 +471,159 @@ func (s *subscriber) statusChange(ss *status.SubscriptionStatus) {
 	}
 }
 
-func (s *subscriber) send(ctx context.Context, event *cloudevents.Event) bool {
+// deliver sends the event to the target, retrying failed deliveries
+// according to the trigger's delivery options.
+func (s *subscriber) deliver(ctx context.Context, event *cloudevents.Event) (bool, *deliveryReport) {
+	report := &deliveryReport{}
+	for tries := 1; ; tries++ {
+		actx, cancel := s.delivery.attemptContext(ctx)
+		actx, ri := withResponseInfo(actx)
+		ok, code := s.send(actx, event)
+		cancel()
+
+		report.attempts = tries
+		report.statusCode = code
+		report.responseData = ri.getData()
+		if ok {
+			return true, report
+		}
+
+		if !s.delivery.retriable(code) {
+			s.logger.Debugw("Status code not retryable, will not try again", zap.Int("statusCode", code),
+				zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
+			return false, report
+		}
+
+		d, retry := s.delivery.backoff(tries, code, ri.getRetryAfter(), time.Now())
+		if !retry {
+			return false, report
+		}
+
+		t := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return false, report
+		case <-t.C:
+		}
+	}
+}
+
+// send makes a single delivery attempt, returning whether it succeeded
+// and the response status code, or zero if no response was received.
+func (s *subscriber) send(ctx context.Context, event *cloudevents.Event) (bool, int) {
 	res, result := s.ceClient.Request(ctx, *event)
+	code := statusCode(result)
 
 	switch {
+	case code != 0 && code/100 != 2:
+		// Responses that cannot be parsed as events are informed as ACK
+		// by the CloudEvents client, the status code needs to be checked.
+		s.logger.Errorw(fmt.Sprintf("Event not accepted at %s",
+			cloudevents.TargetFromContext(ctx).String()),
+			zap.Error(result), zap.Int("statusCode", code),
+			zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
+		return false, code
+
 	case cloudevents.IsACK(result):
 		if res != nil {
-			if err := s.backend.Produce(ctx, res); err != nil {
-				s.logger.Errorw(fmt.Sprintf("Failed to consume response from %s",
-					cloudevents.TargetFromContext(ctx).String()),
-					zap.Error(err), zap.String("type", res.Type()), zap.String("source", res.Source()), zap.String("id", res.ID()))
-
-				// Not ingesting the response is considered an error.
-				// TODO make this configurable.
-				return false
-			}
+			return s.handleReply(ctx, event, res), code
 		}
-		return true
+		return true, code
 
 	case cloudevents.IsUndelivered(result):
 		s.logger.Errorw(fmt.Sprintf("Failed to send event to %s",
 			cloudevents.TargetFromContext(ctx).String()),
 			zap.Error(result), zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
-		return false
+		return false, code
 
 	case cloudevents.IsNACK(result):
 		s.logger.Errorw(fmt.Sprintf("Event not accepted at %s",
 			cloudevents.TargetFromContext(ctx).String()),
 			zap.Error(result), zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
-		return false
+		return false, code
 	}
 
 	s.logger.Errorw(fmt.Sprintf("Unknown event send outcome at %s",
 		cloudevents.TargetFromContext(ctx).String()),
 		zap.Error(result), zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
-	return false
+	return false, code
 }
 
-func materializeFiltersList(ctx context.Context, filters []cfgbroker.Filter) []eventfilter.Filter {
+func materializeFiltersList(filters []cfgbroker.Filter) ([]eventfilter.Filter, error) {
 	materializedFilters := make([]eventfilter.Filter, 0, len(filters))
-	for _, f := range filters {
-		f := materializeSubscriptionsAPIFilter(ctx, f)
-		if f == nil {
-			logging.FromContext(ctx).Warnw("Failed to parse filter. Skipping filter.", zap.Any("filter", f))
-			continue
+	for i, f := range filters {
+		mf, err := materializeSubscriptionsAPIFilter(f)
+		if err != nil {
+			return nil, fmt.Errorf("filter at position %d: %w", i, err)
 		}
-		materializedFilters = append(materializedFilters, f)
+		materializedFilters = append(materializedFilters, mf)
 	}
-	return materializedFilters
+	return materializedFilters, nil
 }
 
-func materializeSubscriptionsAPIFilter(ctx context.Context, filter cfgbroker.Filter) eventfilter.Filter {
-	var materializedFilter eventfilter.Filter
-	var err error
+func materializeSubscriptionsAPIFilter(filter cfgbroker.Filter) (eventfilter.Filter, error) {
 	switch {
 	case len(filter.Exact) > 0:
 		// The webhook validates that this map has only a single key:value pair.
-		materializedFilter, err = subscriptionsapi.NewExactFilter(filter.Exact)
+		f, err := subscriptionsapi.NewExactFilter(filter.Exact)
 		if err != nil {
-			logging.FromContext(ctx).Debugw("Invalid exact expression", zap.Any("filters", filter.Exact), zap.Error(err))
-			return nil
+			return nil, fmt.Errorf("invalid exact expression %v: %w", filter.Exact, err)
 		}
+		return f, nil
+
 	case len(filter.Prefix) > 0:
 		// The webhook validates that this map has only a single key:value pair.
-		materializedFilter, err = subscriptionsapi.NewPrefixFilter(filter.Prefix)
+		f, err := subscriptionsapi.NewPrefixFilter(filter.Prefix)
 		if err != nil {
-			logging.FromContext(ctx).Debugw("Invalid prefix expression", zap.Any("filters", filter.Exact), zap.Error(err))
-			return nil
+			return nil, fmt.Errorf("invalid prefix expression %v: %w", filter.Prefix, err)
 		}
+		return f, nil
+
 	case len(filter.Suffix) > 0:
 		// The webhook validates that this map has only a single key:value pair.
-		materializedFilter, err = subscriptionsapi.NewSuffixFilter(filter.Suffix)
+		f, err := subscriptionsapi.NewSuffixFilter(filter.Suffix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid suffix expression %v: %w", filter.Suffix, err)
+		}
+		return f, nil
+
+	case len(filter.Regex) > 0:
+		// The webhook validates that this map has only a single key:value pair.
+		f, err := newRegexFilter(filter.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex expression %v: %w", filter.Regex, err)
+		}
+		return f, nil
+
+	case len(filter.Range) > 0:
+		f, err := newRangeFilter(filter.Range)
 		if err != nil {
-			logging.FromContext(ctx).Debugw("Invalid suffix expression", zap.Any("filters", filter.Exact), zap.Error(err))
-			return nil
+			return nil, fmt.Errorf("invalid range expression: %w", err)
 		}
+		return f, nil
+
 	case len(filter.All) > 0:
-		materializedFilter = subscriptionsapi.NewAllFilter(materializeFiltersList(ctx, filter.All)...)
+		fs, err := materializeFiltersList(filter.All)
+		if err != nil {
+			return nil, fmt.Errorf("all: %w", err)
+		}
+		return subscriptionsapi.NewAllFilter(fs...), nil
+
 	case len(filter.Any) > 0:
-		materializedFilter = subscriptionsapi.NewAnyFilter(materializeFiltersList(ctx, filter.Any)...)
+		fs, err := materializeFiltersList(filter.Any)
+		if err != nil {
+			return nil, fmt.Errorf("any: %w", err)
+		}
+		return subscriptionsapi.NewAnyFilter(fs...), nil
+
 	case filter.Not != nil:
-		materializedFilter = subscriptionsapi.NewNotFilter(materializeSubscriptionsAPIFilter(ctx, *filter.Not))
+		f, err := materializeSubscriptionsAPIFilter(*filter.Not)
+		if err != nil {
+			return nil, fmt.Errorf("not: %w", err)
+		}
+		return subscriptionsapi.NewNotFilter(f), nil
 	}
-	return materializedFilter
+
+	return nil, errors.New("filter does not contain any dialect")
 }
@@ -5,6 +5,9 @@ package subscriptions
 
 import (
 	"context"
+	"errors"
+	"reflect"
+	"sync"
 	"testing"
 	"time"
 
@@ -14,8 +17,12 @@ import (
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap/zaptest"
 
+	"knative.dev/eventing/pkg/eventfilter/subscriptionsapi"
+
+	"github.com/triggermesh/brokers/pkg/backend"
 	"github.com/triggermesh/brokers/pkg/backend/impl/memory"
 	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/status"
 	"github.com/triggermesh/brokers/test/lib"
 )
 
@@ -47,6 +54,23 @@ var (
 			lib.CloudEventWithSourceOption("source2"),
 			lib.CloudEventWithExtensionOption("ext2", "val2")),
 	}
+
+	severityEventPool = []cloudevents.Event{
+		lib.NewCloudEvent(
+			lib.CloudEventWithIDOption("sev1"),
+			lib.CloudEventWithExtensionOption("severity", "1")),
+		lib.NewCloudEvent(
+			lib.CloudEventWithIDOption("sev3"),
+			lib.CloudEventWithExtensionOption("severity", "3")),
+		lib.NewCloudEvent(
+			lib.CloudEventWithIDOption("sev5"),
+			lib.CloudEventWithExtensionOption("severity", "5")),
+		lib.NewCloudEvent(
+			lib.CloudEventWithIDOption("sevnan"),
+			lib.CloudEventWithExtensionOption("severity", "high")),
+		lib.NewCloudEvent(
+			lib.CloudEventWithIDOption("nosev")),
+	}
 )
 
 func TestSubscriberFilter(t *testing.T) {
@@ -150,6 +174,92 @@ func TestSubscriberFilter(t *testing.T) {
 			events:      eventPool,
 			expectedIds: []string{"t1s1", "t1s1ex2", "t2s1ex1", "t2s2ex1"},
 		},
+
+		"regex type": {
+			trigger: cfgbroker.Trigger{
+				Filters: []cfgbroker.Filter{
+					{
+						Regex: map[string]string{
+							"type": `^type(1|3)$`,
+						},
+					},
+				},
+			},
+			events:      eventPool,
+			expectedIds: []string{"t1s1", "t1s1ex2"},
+		},
+
+		"regex extension": {
+			trigger: cfgbroker.Trigger{
+				Filters: []cfgbroker.Filter{
+					{
+						Regex: map[string]string{
+							"ext1": `^v.l\d$`,
+						},
+					},
+				},
+			},
+			events:      eventPool,
+			expectedIds: []string{"t2s1ex1", "t2s2ex1"},
+		},
+
+		"range greater or equal": {
+			trigger: cfgbroker.Trigger{
+				Filters: []cfgbroker.Filter{
+					{
+						Range: map[string]cfgbroker.Range{
+							"severity": {
+								GreaterThanOrEqual: rangeValue("3"),
+							},
+						},
+					},
+				},
+			},
+			events:      severityEventPool,
+			expectedIds: []string{"sev3", "sev5"},
+		},
+
+		"range between": {
+			trigger: cfgbroker.Trigger{
+				Filters: []cfgbroker.Filter{
+					{
+						Range: map[string]cfgbroker.Range{
+							"severity": {
+								GreaterThan: rangeValue("1"),
+								LessThan:    rangeValue("5"),
+							},
+						},
+					},
+				},
+			},
+			events:      severityEventPool,
+			expectedIds: []string{"sev3"},
+		},
+
+		"range time": {
+			trigger: cfgbroker.Trigger{
+				Filters: []cfgbroker.Filter{
+					{
+						Range: map[string]cfgbroker.Range{
+							"time": {
+								GreaterThan: rangeValue("2020-01-01T00:00:00Z"),
+							},
+						},
+					},
+				},
+			},
+			events: []cloudevents.Event{
+				lib.NewCloudEvent(
+					lib.CloudEventWithIDOption("old"),
+					lib.CloudEventWithTimeOption(time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC))),
+				lib.NewCloudEvent(
+					lib.CloudEventWithIDOption("new"),
+					lib.CloudEventWithTimeOption(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))),
+				lib.NewCloudEvent(
+					lib.CloudEventWithIDOption("notime")),
+			},
+			expectedIds: []string{"new"},
+		},
 	}
 
 	logger := zaptest.NewLogger(t).Sugar()
@@ -210,6 +320,458 @@ func TestSubscriberFilter(t *testing.T) {
 	}
 }
 
+func rangeValue(v string) *cfgbroker.RangeValue {
+	rv := cfgbroker.RangeValue(v)
+	return &rv
+}
+
 func testReceiver(inMessage cloudevents.Event) (*cloudevents.Event, cloudevents.Result) {
 	return nil, cloudevents.ResultACK
 }
+
+func TestSubscriberTransform(t *testing.T) {
+	dls := "http://dls"
+
+	testCases := map[string]struct {
+		transform *cfgbroker.Transform
+		event     cloudevents.Event
+
+		expectedAttributes map[string]interface{}
+		expectedMissing    []string
+		expectedData       string
+	}{
+		"set templated attribute": {
+			transform: &cfgbroker.Transform{
+				Set: map[string]string{
+					"subject": "{{.source}}/{{.ext1}}",
+					"ext3":    "fixed",
+				},
+			},
+			event: lib.NewCloudEvent(
+				lib.CloudEventWithSourceOption("source1"),
+				lib.CloudEventWithExtensionOption("ext1", "val1")),
+			expectedAttributes: map[string]interface{}{
+				"subject": "source1/val1",
+				"ext3":    "fixed",
+			},
+		},
+		"rename and delete extensions": {
+			transform: &cfgbroker.Transform{
+				Rename: map[string]string{
+					"ext1": "ext3",
+				},
+				Delete: []string{"ext2"},
+			},
+			event: lib.NewCloudEvent(
+				lib.CloudEventWithExtensionOption("ext1", "val1"),
+				lib.CloudEventWithExtensionOption("ext2", "val2")),
+			expectedAttributes: map[string]interface{}{
+				"ext3": "val1",
+			},
+			expectedMissing: []string{"ext1", "ext2"},
+		},
+		"data mapping": {
+			transform: &cfgbroker.Transform{
+				Data: &cfgbroker.DataTransform{
+					Mapping: map[string]string{
+						"order.id":  "id",
+						"customer":  "details.customer.name",
+						"not.found": "missing",
+					},
+				},
+			},
+			event: lib.NewCloudEvent(
+				lib.CloudEventWithDataOption(`{"id":"o1","details":{"customer":{"name":"acme"}},"other":1}`)),
+			expectedData: `{"customer":"acme","order":{"id":"o1"}}`,
+		},
+		"data mapping not JSON goes to DLS": {
+			transform: &cfgbroker.Transform{
+				Data: &cfgbroker.DataTransform{
+					Mapping: map[string]string{
+						"id": "id",
+					},
+				},
+				Set: map[string]string{
+					"ext3": "transformed",
+				},
+			},
+			event: lib.NewCloudEvent(
+				lib.CloudEventWithIDOption("not-json"),
+				lib.CloudEventWithDataOption(`not json`)),
+			expectedMissing: []string{"ext3"},
+			expectedData:    `not json`,
+		},
+	}
+
+	logger := zaptest.NewLogger(t).Sugar()
+	ctx := context.Background()
+
+	for n, tc := range testCases {
+		t.Run(n, func(t *testing.T) {
+			client, rcv := cetest.NewMockRequesterClient(t, 1, testReceiver)
+			s := subscriber{
+				name:      "test-subscriber",
+				ceClient:  client,
+				parentCtx: ctx,
+				logger:    logger,
+			}
+
+			url := "http://test"
+			err := s.updateTrigger(cfgbroker.Trigger{
+				Target:    cfgbroker.Target{URL: &url},
+				Transform: tc.transform,
+				DeliveryOptions: &cfgbroker.DeliveryOptions{
+					DeadLetterURL: &dls,
+				},
+			})
+			require.NoError(t, err, "Could not set trigger for subscription")
+
+			original := tc.event.Clone()
+			s.dispatchCloudEvent(&tc.event)
+
+			var e cloudevents.Event
+			select {
+			case e = <-rcv:
+			case <-time.After(time.Second):
+				require.Fail(t, "Event was not received")
+			}
+
+			assert.Equal(t, original, tc.event, "Incoming event must not be modified")
+
+			for k, v := range tc.expectedAttributes {
+				if k == "subject" {
+					assert.Equal(t, v, e.Subject())
+					continue
+				}
+				assert.Equal(t, v, e.Extensions()[k])
+			}
+
+			for _, k := range tc.expectedMissing {
+				_, ok := e.Extensions()[k]
+				assert.False(t, ok, "Extension %q should not exist", k)
+			}
+
+			if tc.expectedData != "" {
+				assert.Equal(t, tc.expectedData, string(e.Data()))
+			}
+		})
+	}
+}
+
+func TestSubscriberFilterMaterializationError(t *testing.T) {
+	testCases := map[string]struct {
+		filters []cfgbroker.Filter
+	}{
+		"empty filter": {
+			filters: []cfgbroker.Filter{{}},
+		},
+		"empty nested filter": {
+			filters: []cfgbroker.Filter{
+				{
+					All: []cfgbroker.Filter{
+						{
+							Exact: map[string]string{
+								"type": "type1",
+							},
+						},
+						{},
+					},
+				},
+			},
+		},
+		"empty not filter": {
+			filters: []cfgbroker.Filter{
+				{
+					Not: &cfgbroker.Filter{},
+				},
+			},
+		},
+	}
+
+	for n, tc := range testCases {
+		t.Run(n, func(t *testing.T) {
+			s := subscriber{
+				name:      "test-subscriber",
+				parentCtx: context.Background(),
+				logger:    zaptest.NewLogger(t).Sugar(),
+			}
+
+			err := s.updateTrigger(cfgbroker.Trigger{Filters: tc.filters})
+			assert.ErrorContains(t, err, "filter does not contain any dialect")
+			assert.Nil(t, s.filter, "Filter should not be set when materialization fails")
+		})
+	}
+}
+
+var benchmarkFilters = []cfgbroker.Filter{
+	{
+		Any: []cfgbroker.Filter{
+			{
+				Exact: map[string]string{
+					"source": "source1",
+				},
+			}, {
+				All: []cfgbroker.Filter{
+					{
+						Prefix: map[string]string{
+							"type": "type",
+						},
+					}, {
+						Not: &cfgbroker.Filter{
+							Suffix: map[string]string{
+								"ext2": "2",
+							},
+						},
+					},
+				},
+			},
+		},
+	},
+}
+
+func BenchmarkSubscriberFilter(b *testing.B) {
+	ctx := context.Background()
+
+	// Materializing the filter tree for each event, which is how
+	// the subscriber used to work.
+	b.Run("materialize per event", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			filters, err := materializeFiltersList(benchmarkFilters)
+			if err != nil {
+				b.Fatal(err)
+			}
+			f := subscriptionsapi.NewAllFilter(filters...)
+			_ = f.Filter(ctx, eventPool[n%len(eventPool)])
+		}
+	})
+
+	// Materializing the filter tree once and using it for each event,
+	// which is what the subscriber does when the trigger is updated.
+	b.Run("precompiled", func(b *testing.B) {
+		filters, err := materializeFiltersList(benchmarkFilters)
+		if err != nil {
+			b.Fatal(err)
+		}
+		f := subscriptionsapi.NewAllFilter(filters...)
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			_ = f.Filter(ctx, eventPool[n%len(eventPool)])
+		}
+	})
+}
+
+func TestManagerCheckStalled(t *testing.T) {
+	s := &subscriber{name: "trigger1"}
+	m := &Manager{
+		subscribers: map[string]*subscriber{"trigger1": s},
+		failures:    map[string]string{},
+	}
+
+	assert.NoError(t, m.CheckStalled(time.Minute), "idle subscriber should not be stalled")
+
+	done := s.trackDispatch()
+	assert.NoError(t, m.CheckStalled(time.Minute), "recent dispatch should not be stalled")
+
+	s.im.Lock()
+	for id := range s.inflight {
+		s.inflight[id] = time.Now().Add(-2 * time.Minute)
+	}
+	s.im.Unlock()
+
+	err := m.CheckStalled(time.Minute)
+	if assert.Error(t, err, "old dispatch should be stalled") {
+		assert.Contains(t, err.Error(), `trigger "trigger1" dispatch has not advanced`)
+	}
+
+	done()
+	assert.NoError(t, m.CheckStalled(time.Minute), "finished dispatch should not be stalled")
+}
+
+// managerBackend keeps track of the subscription operations
+// performed on the backend.
+type managerBackend struct {
+	backend.Interface
+
+	// subscribed contains the reset position option informed
+	// at each subscription.
+	subscribed   []bool
+	unsubscribed []string
+	// subscribeErr is returned when subscribing if set.
+	subscribeErr error
+	removed      []string
+	existing     []string
+
+	// m protects removed, which is also
+	// updated asynchronously.
+	m sync.Mutex
+}
+
+func (b *managerBackend) Info() *backend.Info {
+	return &backend.Info{Name: "test"}
+}
+
+func (b *managerBackend) Subscribe(_ string, _ *cfgbroker.TriggerBounds, _ backend.ConsumerDispatcher, _ backend.SubscriptionStatusChange, opts ...backend.SubscribeOption) error {
+	b.subscribed = append(b.subscribed, backend.NewSubscribeOptions(opts...).ResetPosition)
+	return b.subscribeErr
+}
+
+func (b *managerBackend) Unsubscribe(name string) {
+	b.unsubscribed = append(b.unsubscribed, name)
+}
+
+func (b *managerBackend) RemoveSubscription(name string) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	b.removed = append(b.removed, name)
+}
+
+func (b *managerBackend) RemoveOrphanedSubscriptions(_ context.Context, names []string) error {
+	for _, e := range b.existing {
+		found := false
+		for _, n := range names {
+			found = found || n == e
+		}
+		if !found {
+			b.RemoveSubscription(e)
+		}
+	}
+	return nil
+}
+
+func (b *managerBackend) removedSubscriptions() []string {
+	b.m.Lock()
+	defer b.m.Unlock()
+	return append([]string{}, b.removed...)
+}
+
+func TestManagerSubscriptionCleanup(t *testing.T) {
+	b := &managerBackend{existing: []string{"trigger1", "orphaned"}}
+	m, err := New(context.Background(), zaptest.NewLogger(t).Sugar(), b, nil)
+	require.NoError(t, err)
+
+	m.UpdateFromConfig(&cfgbroker.Config{Triggers: map[string]cfgbroker.Trigger{
+		"trigger1": {},
+		"trigger2": {},
+	}})
+	assert.Eventually(t, func() bool {
+		return reflect.DeepEqual([]string{"orphaned"}, b.removedSubscriptions())
+	}, time.Second, 10*time.Millisecond, "Orphaned subscriptions are not removed at startup")
+
+	m.UpdateFromConfig(&cfgbroker.Config{Triggers: map[string]cfgbroker.Trigger{
+		"trigger1": {},
+	}})
+	assert.Equal(t, []string{"orphaned", "trigger2"}, b.removedSubscriptions(), "Deleted subscriptions are not removed")
+}
+
+func TestManagerBoundsChange(t *testing.T) {
+	b := &managerBackend{}
+	m, err := New(context.Background(), zaptest.NewLogger(t).Sugar(), b, nil)
+	require.NoError(t, err)
+
+	start, end, newEnd := "1-0", "5-0", "9-0"
+	update := func(bounds *cfgbroker.TriggerBounds) {
+		m.UpdateFromConfig(&cfgbroker.Config{Triggers: map[string]cfgbroker.Trigger{
+			"trigger1": {Bounds: bounds},
+		}})
+	}
+
+	update(nil)
+	assert.Equal(t, []bool{false}, b.subscribed)
+
+	update(&cfgbroker.TriggerBounds{ByID: &cfgbroker.Bounds{Start: &start, End: &end}})
+	assert.Equal(t, []string{"trigger1"}, b.unsubscribed, "Subscription is not removed upon bounds change")
+	assert.Equal(t, []bool{false, true}, b.subscribed, "Subscription is not re-created resetting its position")
+
+	update(&cfgbroker.TriggerBounds{ByID: &cfgbroker.Bounds{Start: &start, End: &newEnd}})
+	assert.Equal(t, []bool{false, true, false}, b.subscribed, "Position is reset upon end bound change")
+
+	update(&cfgbroker.TriggerBounds{ByID: &cfgbroker.Bounds{Start: &start, End: &newEnd}})
+	assert.Equal(t, []bool{false, true, false}, b.subscribed, "Subscription is re-created without bounds change")
+}
+
+func TestManagerResubscribeFailure(t *testing.T) {
+	b := &managerBackend{}
+	m, err := New(context.Background(), zaptest.NewLogger(t).Sugar(), b, nil)
+	require.NoError(t, err)
+
+	start, end := "1-0", "5-0"
+	m.UpdateFromConfig(&cfgbroker.Config{Triggers: map[string]cfgbroker.Trigger{
+		"trigger1": {},
+	}})
+
+	b.subscribeErr = errors.New("backend not available")
+	m.UpdateFromConfig(&cfgbroker.Config{Triggers: map[string]cfgbroker.Trigger{
+		"trigger1": {Bounds: &cfgbroker.TriggerBounds{ByID: &cfgbroker.Bounds{Start: &start}}},
+	}})
+	assert.Contains(t, m.subscribers, "trigger1", "Subscriber is removed after failing to re-subscribe")
+	assert.Contains(t, m.failures, "trigger1", "Re-subscription failure is not reported")
+
+	// The end bound change must keep the pending position reset.
+	b.subscribeErr = nil
+	m.UpdateFromConfig(&cfgbroker.Config{Triggers: map[string]cfgbroker.Trigger{
+		"trigger1": {Bounds: &cfgbroker.TriggerBounds{ByID: &cfgbroker.Bounds{Start: &start, End: &end}}},
+	}})
+	assert.Equal(t, []bool{false, true, true}, b.subscribed, "Position reset is lost after re-subscription failure")
+	assert.NotContains(t, m.failures, "trigger1")
+	assert.NotContains(t, m.resubscriptions, "trigger1", "Re-subscription retry is not cancelled")
+}
+
+// statusRecorder keeps the last status informed for each subscription.
+type statusRecorder struct {
+	status.Manager
+
+	subscriptions map[string]status.SubscriptionStatus
+	m             sync.Mutex
+}
+
+func (r *statusRecorder) EnsureSubscription(name string, ss *status.SubscriptionStatus) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.subscriptions[name] = *ss
+}
+
+func (r *statusRecorder) subscription(name string) status.SubscriptionStatus {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return r.subscriptions[name]
+}
+
+func TestManagerUpdateFailureStatus(t *testing.T) {
+	sr := &statusRecorder{subscriptions: map[string]status.SubscriptionStatus{}}
+	m, err := New(context.Background(), zaptest.NewLogger(t).Sugar(), &managerBackend{}, sr)
+	require.NoError(t, err)
+
+	m.UpdateFromConfig(&cfgbroker.Config{Triggers: map[string]cfgbroker.Trigger{
+		"trigger1": {},
+	}})
+
+	m.UpdateFromConfig(&cfgbroker.Config{Triggers: map[string]cfgbroker.Trigger{
+		"trigger1": {Transform: &cfgbroker.Transform{Set: map[string]string{"ext1": "{{"}}},
+	}})
+	assert.Equal(t, status.SubscriptionStatusFailed, sr.subscription("trigger1").Status)
+
+	// Events are still dispatched with the previous configuration.
+	event := lib.NewCloudEvent()
+	m.subscribers["trigger1"].dispatchCloudEvent(&event)
+
+	ss := sr.subscription("trigger1")
+	assert.Equal(t, status.SubscriptionStatusFailed, ss.Status, "Dispatch overwrote the failed status")
+	if assert.NotNil(t, ss.Message) {
+		assert.Contains(t, *ss.Message, "Could not setup trigger")
+	}
+	assert.NotNil(t, ss.LastProcessed)
+
+	// Going back to the configuration in effect clears the failure.
+	m.UpdateFromConfig(&cfgbroker.Config{Triggers: map[string]cfgbroker.Trigger{
+		"trigger1": {},
+	}})
+	ss = sr.subscription("trigger1")
+	assert.Equal(t, status.SubscriptionStatusRunning, ss.Status)
+	assert.Nil(t, ss.Message)
+
+	m.subscribers["trigger1"].dispatchCloudEvent(&event)
+	assert.Equal(t, status.SubscriptionStatusRunning, sr.subscription("trigger1").Status)
+}
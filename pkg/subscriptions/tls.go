@@ -0,0 +1,261 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// watchedFile caches the contents of a file, reading it again
+// when its modification time changes.
+type watchedFile struct {
+	path string
+
+	content []byte
+	modTime time.Time
+	m       sync.Mutex
+}
+
+// read returns the file contents and whether they changed since
+// the last call.
+func (f *watchedFile) read() ([]byte, bool, error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if fi.ModTime().Equal(f.modTime) && f.content != nil {
+		return f.content, false, nil
+	}
+
+	b, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, false, err
+	}
+	f.content = b
+	f.modTime = fi.ModTime()
+
+	return b, true, nil
+}
+
+// targetTLS builds TLS configurations for the target that pick up
+// rotated certificate files at each handshake.
+type targetTLS struct {
+	cfg *cfgbroker.TargetTLS
+
+	ca   *watchedFile
+	cert *watchedFile
+	key  *watchedFile
+
+	pool      *x509.CertPool
+	clientCrt *tls.Certificate
+	m         sync.Mutex
+}
+
+func newTargetTLS(cfg *cfgbroker.TargetTLS) (*targetTLS, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	t := &targetTLS{cfg: cfg}
+	if cfg.CAFile != "" {
+		t.ca = &watchedFile{path: cfg.CAFile}
+	}
+	if cfg.CertFile != "" {
+		t.cert = &watchedFile{path: cfg.CertFile}
+		t.key = &watchedFile{path: cfg.KeyFile}
+	}
+
+	// Fail early when files cannot be loaded.
+	if _, err := t.caPool(); err != nil {
+		return nil, err
+	}
+	if _, err := t.clientCertificate(nil); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (t *targetTLS) tlsConfig(serverName string) *tls.Config {
+	c := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         serverName,
+		InsecureSkipVerify: t.cfg.InsecureSkipVerify, //nolint:gosec
+	}
+
+	if t.cfg.ServerName != "" {
+		c.ServerName = t.cfg.ServerName
+	}
+
+	if t.cert != nil {
+		c.GetClientCertificate = t.clientCertificate
+	}
+
+	if t.ca != nil && !t.cfg.InsecureSkipVerify {
+		// Verification is done at the callback using the latest
+		// CA bundle, which is not possible using the RootCAs field.
+		c.InsecureSkipVerify = true //nolint:gosec
+		c.VerifyConnection = t.verifyConnection
+	}
+
+	return c
+}
+
+func (t *targetTLS) caPool() (*x509.CertPool, error) {
+	if t.ca == nil {
+		return nil, nil
+	}
+
+	b, changed, err := t.ca.read()
+	if err != nil {
+		return nil, fmt.Errorf("could not read CA file: %w", err)
+	}
+
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	if changed || t.pool == nil {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, errors.New("CA file does not contain any valid PEM certificate")
+		}
+		t.pool = pool
+	}
+
+	return t.pool, nil
+}
+
+func (t *targetTLS) clientCertificate(_ *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	if t.cert == nil {
+		return &tls.Certificate{}, nil
+	}
+
+	cb, certChanged, err := t.cert.read()
+	if err != nil {
+		return nil, fmt.Errorf("could not read client certificate file: %w", err)
+	}
+
+	kb, keyChanged, err := t.key.read()
+	if err != nil {
+		return nil, fmt.Errorf("could not read client key file: %w", err)
+	}
+
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	if certChanged || keyChanged || t.clientCrt == nil {
+		crt, err := tls.X509KeyPair(cb, kb)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate: %w", err)
+		}
+		t.clientCrt = &crt
+	}
+
+	return t.clientCrt, nil
+}
+
+func (t *targetTLS) verifyConnection(cs tls.ConnectionState) error {
+	pool, err := t.caPool()
+	if err != nil {
+		return err
+	}
+
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("target did not present any certificate")
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         pool,
+		DNSName:       cs.ServerName,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, c := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+
+	_, err = cs.PeerCertificates[0].Verify(opts)
+	return err
+}
+
+// tlsDialer establishes TLS connections for the subscriber's HTTP transport.
+// Connections to the target address use the target TLS settings, any other
+// destination like the dead letter sink uses the default settings.
+type tlsDialer struct {
+	dialer    *net.Dialer
+	transport *http.Transport
+
+	targetAddr string
+	targetTLS  *targetTLS
+	m          sync.RWMutex
+}
+
+func newTLSDialer() *tlsDialer {
+	d := &tlsDialer{
+		dialer: &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		},
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialTLSContext = d.dialTLSContext
+	d.transport = t
+
+	return d
+}
+
+// update sets the target TLS settings, closing idle connections
+// so that new requests use them.
+func (d *tlsDialer) update(target string, tt *targetTLS) {
+	addr := ""
+	if u, err := url.Parse(target); err == nil && u.Scheme == "https" {
+		addr = u.Host
+		if u.Port() == "" {
+			addr = net.JoinHostPort(u.Hostname(), "443")
+		}
+	}
+
+	d.m.Lock()
+	d.targetAddr = addr
+	d.targetTLS = tt
+	d.m.Unlock()
+
+	d.transport.CloseIdleConnections()
+}
+
+func (d *tlsDialer) dialTLSContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	d.m.RLock()
+	cfg := &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+	if d.targetTLS != nil && addr == d.targetAddr {
+		cfg = d.targetTLS.tlsConfig(host)
+	}
+	d.m.RUnlock()
+
+	td := &tls.Dialer{
+		NetDialer: d.dialer,
+		Config:    cfg,
+	}
+	return td.DialContext(ctx, network, addr)
+}
@@ -0,0 +1,273 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/types"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// transformer applies the trigger's transform operations to events.
+// It is built once every time the trigger configuration changes.
+type transformer struct {
+	set    map[string]*template.Template
+	rename map[string]string
+	delete []string
+
+	// data mapping from outgoing path to incoming path.
+	data map[string][]string
+}
+
+func newTransformer(t *cfgbroker.Transform) (*transformer, error) {
+	if t == nil {
+		return nil, nil
+	}
+
+	tr := &transformer{
+		set:    make(map[string]*template.Template, len(t.Set)),
+		rename: t.Rename,
+		delete: t.Delete,
+	}
+
+	for name, value := range t.Set {
+		tpl, err := template.New(name).Option("missingkey=zero").Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template for attribute %q: %w", name, err)
+		}
+		tr.set[name] = tpl
+	}
+
+	if t.Data != nil {
+		tr.data = make(map[string][]string, len(t.Data.Mapping))
+		for to, from := range t.Data.Mapping {
+			if to == "" || from == "" {
+				return nil, errors.New("data mapping paths cannot be empty")
+			}
+			tr.data[to] = strings.Split(from, ".")
+		}
+	}
+
+	return tr, nil
+}
+
+// transform returns a transformed copy of the event. The incoming
+// event is not modified.
+func (t *transformer) transform(event *cloudevents.Event) (*cloudevents.Event, error) {
+	// Render templates before modifying the event, so that they
+	// always refer to the incoming attributes.
+	values := make(map[string]string, len(t.set))
+	if len(t.set) != 0 {
+		attrs := eventAttributes(event)
+		for name, tpl := range t.set {
+			var b bytes.Buffer
+			if err := tpl.Execute(&b, attrs); err != nil {
+				return nil, fmt.Errorf("could not render value for attribute %q: %w", name, err)
+			}
+			values[name] = b.String()
+		}
+	}
+
+	out := event.Clone()
+
+	for _, name := range t.delete {
+		if err := setAttribute(&out, name, nil); err != nil {
+			return nil, fmt.Errorf("could not delete attribute %q: %w", name, err)
+		}
+	}
+
+	for from, to := range t.rename {
+		v, ok := attributeValue(&out, from)
+		if !ok {
+			continue
+		}
+		if err := setAttribute(&out, from, nil); err != nil {
+			return nil, fmt.Errorf("could not rename attribute %q: %w", from, err)
+		}
+		if err := setAttribute(&out, to, v); err != nil {
+			return nil, fmt.Errorf("could not rename attribute %q to %q: %w", from, to, err)
+		}
+	}
+
+	for name, v := range values {
+		if err := setAttribute(&out, name, v); err != nil {
+			return nil, fmt.Errorf("could not set attribute %q: %w", name, err)
+		}
+	}
+
+	if t.data != nil {
+		if err := t.transformData(&out); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := out.Validate(); err != nil {
+		return nil, fmt.Errorf("transformed event is not valid: %w", err)
+	}
+
+	return &out, nil
+}
+
+func (t *transformer) transformData(event *cloudevents.Event) error {
+	in := map[string]interface{}{}
+	if len(event.Data()) != 0 {
+		if err := json.Unmarshal(event.Data(), &in); err != nil {
+			return fmt.Errorf("data mapping requires a JSON object at the event data: %w", err)
+		}
+	}
+
+	out := map[string]interface{}{}
+	for to, from := range t.data {
+		v, ok := lookupPath(in, from)
+		if !ok {
+			continue
+		}
+		if err := writePath(out, strings.Split(to, "."), v); err != nil {
+			return fmt.Errorf("could not write data path %q: %w", to, err)
+		}
+	}
+
+	if err := event.SetData(cloudevents.ApplicationJSON, out); err != nil {
+		return fmt.Errorf("could not set transformed data: %w", err)
+	}
+
+	return nil
+}
+
+func lookupPath(in map[string]interface{}, path []string) (interface{}, bool) {
+	var current interface{} = in
+	for _, p := range path {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[p]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func writePath(out map[string]interface{}, path []string, v interface{}) error {
+	current := out
+	for _, p := range path[:len(path)-1] {
+		next, ok := current[p]
+		if !ok {
+			m := map[string]interface{}{}
+			current[p] = m
+			current = m
+			continue
+		}
+
+		m, ok := next.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("element %q is not an object", p)
+		}
+		current = m
+	}
+
+	current[path[len(path)-1]] = v
+	return nil
+}
+
+// eventAttributes returns the event attributes and extensions as strings,
+// to be used when rendering templates.
+func eventAttributes(event *cloudevents.Event) map[string]string {
+	attrs := map[string]string{
+		"specversion":     event.SpecVersion(),
+		"id":              event.ID(),
+		"source":          event.Source(),
+		"type":            event.Type(),
+		"subject":         event.Subject(),
+		"dataschema":      event.DataSchema(),
+		"datacontenttype": event.DataContentType(),
+	}
+
+	if t := event.Time(); !t.IsZero() {
+		attrs["time"] = t.Format(time.RFC3339Nano)
+	}
+
+	for k, v := range event.Extensions() {
+		if s, err := types.Format(v); err == nil {
+			attrs[k] = s
+		}
+	}
+
+	return attrs
+}
+
+func attributeValue(event *cloudevents.Event, name string) (interface{}, bool) {
+	switch name {
+	case "subject":
+		return event.Subject(), event.Subject() != ""
+	case "dataschema":
+		return event.DataSchema(), event.DataSchema() != ""
+	case "datacontenttype":
+		return event.DataContentType(), event.DataContentType() != ""
+	case "time":
+		return event.Time(), !event.Time().IsZero()
+	}
+
+	v, ok := event.Extensions()[name]
+	return v, ok
+}
+
+// setAttribute sets the value for an attribute or extension. When the
+// value is nil the attribute is removed.
+func setAttribute(event *cloudevents.Event, name string, value interface{}) error {
+	s := ""
+	if value != nil {
+		var err error
+		if s, err = types.Format(value); err != nil {
+			return err
+		}
+	}
+
+	switch name {
+	case "specversion":
+		return errors.New("specversion cannot be modified")
+	case "id", "source", "type":
+		if s == "" {
+			return fmt.Errorf("required attribute %q cannot be empty", name)
+		}
+	}
+
+	switch name {
+	case "id":
+		event.SetID(s)
+	case "source":
+		event.SetSource(s)
+	case "type":
+		event.SetType(s)
+	case "subject":
+		event.SetSubject(s)
+	case "dataschema":
+		event.SetDataSchema(s)
+	case "datacontenttype":
+		event.SetDataContentType(s)
+	case "time":
+		if s == "" {
+			event.SetTime(time.Time{})
+			break
+		}
+		t, err := types.ToTime(s)
+		if err != nil {
+			return err
+		}
+		event.SetTime(t)
+	default:
+		return event.Context.SetExtension(name, value)
+	}
+
+	return nil
+}
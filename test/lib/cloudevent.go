@@ -4,6 +4,8 @@
 package lib
 
 import (
+	"time"
+
 	cloudevents "github.com/cloudevents/sdk-go/v2"
 	"github.com/google/uuid"
 )
@@ -51,3 +53,15 @@ func CloudEventWithExtensionOption(key, value string) CloudEventOption {
 		e.SetExtension(key, value)
 	}
 }
+
+func CloudEventWithTimeOption(t time.Time) CloudEventOption {
+	return func(e *cloudevents.Event) {
+		e.SetTime(t)
+	}
+}
+
+func CloudEventWithDataOption(data string) CloudEventOption {
+	return func(e *cloudevents.Event) {
+		e.DataEncoded = []byte(data)
+	}
+}